@@ -1,12 +1,19 @@
 package main
 
 import (
+	"bufio"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/snipq/core/pkg/core"
+	"github.com/snipq/core/pkg/parser"
 	"github.com/snipq/core/pkg/types"
 )
 
@@ -20,21 +27,19 @@ func main() {
 
 	switch command {
 	case "expand":
-		if len(os.Args) < 3 {
-			fmt.Println("Usage: snipq expand <trigger>")
-			os.Exit(1)
-		}
-		handleExpand(os.Args[2])
+		handleExpand(os.Args[2:])
 	case "preview":
-		if len(os.Args) < 3 {
-			fmt.Println("Usage: snipq preview <trigger>")
-			os.Exit(1)
-		}
-		handlePreview(os.Args[2])
+		handlePreview(os.Args[2:])
 	case "list":
-		handleList()
+		handleList(os.Args[2:])
 	case "init":
 		handleInit()
+	case "migrate-prefix":
+		handleMigratePrefix(os.Args[2:])
+	case "group":
+		handleGroup(os.Args[2:])
+	case "watch":
+		handleWatch(os.Args[2:])
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
 		printUsage()
@@ -46,16 +51,68 @@ func printUsage() {
 	fmt.Println("SnipQ CLI Tool")
 	fmt.Println("")
 	fmt.Println("Usage:")
-	fmt.Println("  snipq expand <trigger>   - Expand a trigger")
-	fmt.Println("  snipq preview <trigger>  - Preview expansion")
-	fmt.Println("  snipq list              - List all snippets")
+	fmt.Println("  snipq expand [flags] <trigger>   - Expand a trigger")
+	fmt.Println("  snipq preview [flags] <trigger>  - Preview expansion")
+	fmt.Println("  snipq list [--all]      - List snippets (--all includes disabled groups)")
 	fmt.Println("  snipq init              - Initialize sample vault")
+	fmt.Println("  snipq migrate-prefix [--prefix <p>] - Store snippet triggers without the prefix")
+	fmt.Println("  snipq group enable|disable <id>     - Enable or disable a group")
+	fmt.Println("  snipq watch [--poll]    - Print vault changes as files are edited")
+	fmt.Println("")
+	fmt.Println("Expand/preview flags:")
+	fmt.Println("  --clipboard <text>   - Clipboard text for the clipboard function")
+	fmt.Println("  --clipboard-stdin    - Read clipboard text from stdin")
 	fmt.Println("")
 	fmt.Println("Examples:")
 	fmt.Println("  snipq expand ':ty'")
 	fmt.Println("  snipq expand ':ty?lang=vi&tone=casual'")
 	fmt.Println("  snipq expand ':date?format=Mon, 02 Jan 2006'")
 	fmt.Println("  snipq preview ':uuid?upper=true'")
+	fmt.Println("  echo 'pasted' | snipq expand --clipboard-stdin ':quote'")
+	fmt.Println("")
+	fmt.Println("Snippets with fill-in fields prompt for missing values when run in a terminal.")
+}
+
+// parseTriggerArgs parses the flags shared by expand and preview and
+// returns the trigger argument
+func parseTriggerArgs(command string, args []string) (string, core.ClipboardProvider) {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	clipboardText := flags.String("clipboard", "", "clipboard text for the clipboard function")
+	clipboardStdin := flags.Bool("clipboard-stdin", false, "read clipboard text from stdin")
+	flags.Usage = func() {
+		fmt.Printf("Usage: snipq %s [flags] <trigger>\n", command)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil || flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	var clipboard core.ClipboardProvider
+	switch {
+	case *clipboardStdin:
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Printf("Error reading clipboard from stdin: %v\n", err)
+			os.Exit(1)
+		}
+		clipboard = core.NewMemoryClipboard(string(data))
+	case isFlagSet(flags, "clipboard"):
+		clipboard = core.NewMemoryClipboard(*clipboardText)
+	}
+
+	return flags.Arg(0), clipboard
+}
+
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	found := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
 }
 
 func getVaultPath() string {
@@ -80,12 +137,15 @@ func initEngine() (*core.Engine, error) {
 	return engine, nil
 }
 
-func handleExpand(trigger string) {
+func handleExpand(args []string) {
+	trigger, clipboard := parseTriggerArgs("expand", args)
+
 	engine, err := initEngine()
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
+	engine.SetClipboardProvider(clipboard)
 
 	input := types.TriggerInput{
 		RawTrigger: trigger,
@@ -93,14 +153,27 @@ func handleExpand(trigger string) {
 	}
 
 	result, err := engine.Expand(input)
+	if trigger, ok := promptForInput(trigger, err); ok {
+		input.RawTrigger = trigger
+		result, err = engine.Expand(input)
+	}
 	if err != nil {
 		fmt.Printf("Error expanding '%s': %v\n", trigger, err)
+		printSuggestions(err)
 		os.Exit(1)
 	}
 
 	fmt.Printf("Input: %s\n", trigger)
 	fmt.Printf("Output: %s\n", result.Output)
 	fmt.Printf("Snippet: %s\n", result.UsedSnippet)
+	if len(result.NestedSnippets) > 0 {
+		fmt.Printf("Included: %s\n", strings.Join(result.NestedSnippets, ", "))
+	}
+	fmt.Printf("Cursor: %d\n", result.CursorOffset)
+
+	for _, stop := range result.TabStops {
+		fmt.Printf("Tab stop $%d at %d (%q)\n", stop.Index, stop.Offset, stop.Placeholder)
+	}
 
 	if len(result.UsedParams) > 0 {
 		fmt.Println("Parameters:")
@@ -110,12 +183,15 @@ func handleExpand(trigger string) {
 	}
 }
 
-func handlePreview(trigger string) {
+func handlePreview(args []string) {
+	trigger, clipboard := parseTriggerArgs("preview", args)
+
 	engine, err := initEngine()
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
+	engine.SetClipboardProvider(clipboard)
 
 	input := types.TriggerInput{
 		RawTrigger: trigger,
@@ -123,22 +199,34 @@ func handlePreview(trigger string) {
 	}
 
 	result, err := engine.Preview(input)
+	if trigger, ok := promptForInput(trigger, err); ok {
+		input.RawTrigger = trigger
+		result, err = engine.Preview(input)
+	}
 	if err != nil {
 		fmt.Printf("Error previewing '%s': %v\n", trigger, err)
+		printSuggestions(err)
 		os.Exit(1)
 	}
 
 	fmt.Printf("Preview: %s\n", result)
 }
 
-func handleList() {
+func handleList(args []string) {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	all := flags.Bool("all", false, "include disabled groups")
+	if err := flags.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	opts := types.ListOptions{IncludeDisabled: *all}
+
 	engine, err := initEngine()
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	groups, err := engine.ListGroups()
+	groups, err := engine.ListGroupsWith(opts)
 	if err != nil {
 		fmt.Printf("Error listing groups: %v\n", err)
 		os.Exit(1)
@@ -148,16 +236,20 @@ func handleList() {
 	fmt.Println("")
 
 	for _, group := range groups {
-		fmt.Printf("📁 %s (%s)\n", group.Name, group.ID)
+		if group.Enabled {
+			fmt.Printf("📁 %s (%s)\n", group.Name, group.ID)
+		} else {
+			fmt.Printf("📁 %s (%s) [disabled]\n", group.Name, group.ID)
+		}
 
-		snippets, err := engine.ListSnippets(group.ID)
+		snippets, err := engine.ListSnippetsWith(group.ID, opts)
 		if err != nil {
 			fmt.Printf("  Error listing snippets: %v\n", err)
 			continue
 		}
 
 		for _, snippet := range snippets {
-			fmt.Printf("  ✨ %s - %s\n", snippet.Trigger, snippet.Name)
+			fmt.Printf("  ✨ %s - %s\n", engine.FullTrigger(snippet), snippet.Name)
 			if snippet.Description != "" {
 				fmt.Printf("     %s\n", snippet.Description)
 			}
@@ -182,7 +274,7 @@ func handleInit() {
 	snippet := types.Snippet{
 		ID:          "snp_hello",
 		Name:        "Hello World",
-		Trigger:     ":hello",
+		Trigger:     "hello",
 		Description: "Simple hello world snippet",
 		Template:    "Hello, World! Generated at {{ date \"15:04:05\" \"Local\" }}",
 		GroupID:     "10-personal",
@@ -200,3 +292,195 @@ func handleInit() {
 	fmt.Printf("  export SNIPQ_VAULT=%s\n", vaultPath)
 	fmt.Println("  snipq expand ':hello'")
 }
+
+func handleMigratePrefix(args []string) {
+	flags := flag.NewFlagSet("migrate-prefix", flag.ExitOnError)
+	prefix := flags.String("prefix", "", "prefix to strip (defaults to the configured prefix)")
+	if err := flags.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	engine, err := initEngine()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	migrated, err := engine.MigrateTriggerPrefix(*prefix)
+	if err != nil {
+		fmt.Printf("Error migrating triggers: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Migrated %d snippet trigger(s)\n", migrated)
+}
+
+func handleGroup(args []string) {
+	if len(args) != 2 || (args[0] != "enable" && args[0] != "disable") {
+		fmt.Println("Usage: snipq group enable|disable <id>")
+		os.Exit(1)
+	}
+	enabled := args[0] == "enable"
+
+	engine, err := initEngine()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := engine.SetGroupEnabled(args[1], enabled); err != nil {
+		fmt.Printf("Error updating group: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Group %s %sd\n", args[1], args[0])
+}
+
+func handleWatch(args []string) {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	poll := flags.Bool("poll", false, "poll for changes instead of using native notifications")
+	if err := flags.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	engine, err := initEngine()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	engine.Subscribe(func(event types.ChangeEvent) {
+		if event.Err != nil {
+			fmt.Printf("Error reloading %s: %v\n", strings.Join(event.Paths, ", "), event.Err)
+		}
+		diff := event.Diff
+		for _, change := range []struct {
+			label string
+			ids   []string
+		}{
+			{"added snippet", diff.AddedSnippets}, {"changed snippet", diff.ChangedSnippets}, {"removed snippet", diff.RemovedSnippets},
+			{"added group", diff.AddedGroups}, {"changed group", diff.ChangedGroups}, {"removed group", diff.RemovedGroups},
+		} {
+			for _, id := range change.ids {
+				fmt.Printf("%s %s\n", change.label, id)
+			}
+		}
+		if diff.SettingsChanged {
+			fmt.Println("changed settings")
+		}
+		if diff.CountersChanged {
+			fmt.Println("changed counters")
+		}
+	})
+	if err := engine.Watch(types.WatchOptions{Poll: *poll}); err != nil {
+		fmt.Printf("Error watching vault: %v\n", err)
+		os.Exit(1)
+	}
+	defer engine.StopWatching()
+
+	fmt.Println("Watching vault for changes (Ctrl+C to stop)")
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	<-interrupt
+}
+
+// printSuggestions prints the "did you mean" triggers of a not-found error
+func printSuggestions(err error) {
+	var notFound *core.NotFoundError
+	if !errors.As(err, &notFound) || len(notFound.Suggestions) == 0 {
+		return
+	}
+
+	fmt.Println("Did you mean:")
+	for _, suggestion := range notFound.Suggestions {
+		fmt.Printf("  %s - %s\n", suggestion.Trigger, suggestion.Name)
+	}
+}
+
+// promptForInput asks for the fill-in fields listed by an input-required
+// error and returns the trigger with the answers appended. It only prompts
+// when stdin is a terminal.
+func promptForInput(trigger string, err error) (string, bool) {
+	var inputErr *core.InputRequiredError
+	if !errors.As(err, &inputErr) || !isTerminal(os.Stdin) {
+		return trigger, false
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	values := make(map[string]string, len(inputErr.Fields))
+	for _, field := range inputErr.Fields {
+		value, err := promptField(reader, field)
+		if err != nil {
+			fmt.Printf("Error reading %s: %v\n", field.Name, err)
+			os.Exit(1)
+		}
+		values[field.Name] = value
+	}
+
+	return parser.AppendParams(trigger, values), true
+}
+
+// promptField reads one field value, re-asking until it is valid
+func promptField(reader *bufio.Reader, field types.Field) (string, error) {
+	label := field.Label
+	if label == "" {
+		label = field.Name
+	}
+	def := ""
+	if field.Default != nil {
+		def = fmt.Sprint(field.Default)
+	}
+
+	for {
+		switch field.Type {
+		case types.FieldChoice:
+			fmt.Printf("%s:\n", label)
+			for i, option := range field.Options {
+				fmt.Printf("  %d) %s\n", i+1, option)
+			}
+			fmt.Printf("Choose 1-%d", len(field.Options))
+		case types.FieldCheckbox:
+			fmt.Printf("%s (y/n)", label)
+		case types.FieldDate:
+			fmt.Printf("%s (YYYY-MM-DD)", label)
+		default:
+			fmt.Print(label)
+		}
+		if def != "" {
+			fmt.Printf(" [%s]", def)
+		}
+		fmt.Print(": ")
+
+		line, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = def
+		}
+
+		if field.Type == types.FieldChoice {
+			for i, option := range field.Options {
+				if answer == fmt.Sprint(i+1) {
+					answer = option
+				}
+			}
+		}
+
+		if err := core.ValidateField(field, answer); err != nil {
+			fmt.Printf("%v\n", err)
+			continue
+		}
+		return answer, nil
+	}
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
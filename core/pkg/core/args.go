@@ -0,0 +1,157 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/snipq/core/pkg/parser"
+	"github.com/snipq/core/pkg/types"
+)
+
+// Shorthand trigger errors
+var (
+	ErrAmbiguousTrigger = errors.New("ambiguous trigger")
+	ErrAmbiguousParam   = errors.New("ambiguous param")
+	ErrTooManyArgs      = errors.New("too many positional args")
+)
+
+// triggerMatch is a snippet resolved from a typed trigger, with the
+// params the trigger itself supplies
+type triggerMatch struct {
+	snippet  *types.Snippet
+	args     []string          // positional args of a shorthand trigger
+	captures map[string]string // named groups of a regex trigger
+	casing   caseStyle         // case of a trigger typed with capitals
+}
+
+// query maps the trigger's positional args, regex captures and the query
+// string onto the snippet's param names
+func (m *triggerMatch) query(values map[string][]string) (map[string][]string, error) {
+	query, err := resolveQuery(m.snippet, m.args, values)
+	if err != nil {
+		return nil, err
+	}
+	return withCaptures(m.snippet, m.captures, query)
+}
+
+// resolveTrigger finds the snippet for a parsed trigger using find. A
+// shorthand trigger such as :ty/vi is tried literally and by its base
+// trigger; matching both is ambiguous. The positional args are returned
+// when the base trigger matched. The snippet is nil when nothing matches.
+func resolveTrigger(parsed *parser.ParsedTrigger, find func(string) *types.Snippet) (*types.Snippet, []string, error) {
+	literal := find(parsed.Trigger)
+	if parsed.Base == "" {
+		return literal, nil, nil
+	}
+
+	base := find(parsed.Base)
+	switch {
+	case literal != nil && base != nil && literal.ID != base.ID:
+		return nil, nil, fmt.Errorf("%w: %s matches snippet %s and %s with args %s",
+			ErrAmbiguousTrigger, parsed.Trigger, literal.ID, base.ID, strings.Join(parsed.Args, ", "))
+	case literal != nil:
+		return literal, nil, nil
+	case base != nil:
+		return base, parsed.Args, nil
+	}
+	return nil, nil, nil
+}
+
+// positionalParams returns the param names positional args map to: the
+// snippet's args, or its declared params in order
+func positionalParams(snippet *types.Snippet) []string {
+	if len(snippet.Args) > 0 {
+		return snippet.Args
+	}
+
+	names := make([]string, len(snippet.Params))
+	for i, param := range snippet.Params {
+		names[i] = param.Name
+	}
+	return names
+}
+
+// resolveQuery maps positional args and param aliases onto the snippet's
+// param names. A param given twice, positionally and by name or by name
+// and alias, is ambiguous. Empty positional args are skipped.
+func resolveQuery(snippet *types.Snippet, args []string, query map[string][]string) (map[string][]string, error) {
+	if len(args) == 0 && len(snippet.Aliases) == 0 {
+		return query, nil
+	}
+
+	resolved := make(map[string][]string, len(query)+len(args))
+	source := make(map[string]string, len(query)+len(args))
+
+	for key, values := range query {
+		name := key
+		root := parser.ParamRoot(key)
+		if target, ok := snippet.Aliases[root]; ok {
+			name = target + strings.TrimPrefix(key, root)
+		}
+
+		if previous, ok := source[name]; ok {
+			return nil, &ParamError{SnippetID: snippet.ID, Param: name, Reason: fmt.Sprintf("is given as both %s and %s", previous, key), Err: ErrAmbiguousParam}
+		}
+		resolved[name] = values
+		source[name] = key
+	}
+
+	names := positionalParams(snippet)
+	for i, arg := range args {
+		if i >= len(names) {
+			return nil, &ParamError{SnippetID: snippet.ID, Param: "args", Value: arg, Reason: fmt.Sprintf("takes at most %d", len(names)), Allowed: names, Err: ErrTooManyArgs}
+		}
+		if arg == "" {
+			continue
+		}
+
+		name := names[i]
+		if previous, ok := source[name]; ok {
+			return nil, &ParamError{SnippetID: snippet.ID, Param: name, Value: arg, Reason: fmt.Sprintf("is given both positionally and as %s", previous), Err: ErrAmbiguousParam}
+		}
+		resolved[name] = []string{arg}
+		source[name] = fmt.Sprintf("arg %d", i+1)
+	}
+
+	return resolved, nil
+}
+
+// withCaptures adds the named groups of a regex trigger to query. Like
+// positional args, captures rank with query params above snippet defaults;
+// a param given both ways is ambiguous. Groups that did not take part in
+// the match are skipped.
+func withCaptures(snippet *types.Snippet, captures map[string]string, query map[string][]string) (map[string][]string, error) {
+	if len(captures) == 0 {
+		return query, nil
+	}
+
+	given := make(map[string]string, len(query))
+	for key := range query {
+		given[parser.ParamRoot(key)] = key
+	}
+
+	names := make([]string, 0, len(captures))
+	for name := range captures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	merged := make(map[string][]string, len(query)+len(captures))
+	for key, values := range query {
+		merged[key] = values
+	}
+	for _, name := range names {
+		value := captures[name]
+		if value == "" {
+			continue
+		}
+		if key, ok := given[name]; ok {
+			return nil, &ParamError{SnippetID: snippet.ID, Param: name, Value: value, Reason: fmt.Sprintf("is given both by the trigger and as %s", key), Err: ErrAmbiguousParam}
+		}
+		merged[name] = []string{value}
+	}
+
+	return merged, nil
+}
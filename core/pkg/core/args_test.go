@@ -0,0 +1,163 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/snipq/core/pkg/types"
+)
+
+func TestEngine_ExpandShorthand(t *testing.T) {
+	greet := types.Snippet{
+		ID:       "snp_greet",
+		Name:     "Greet",
+		Trigger:  ":greet",
+		Defaults: map[string]any{"lang": "en", "tone": "neutral", "name": "there"},
+		Args:     []string{"lang", "tone", "name"},
+		Aliases:  map[string]string{"l": "lang", "t": "tone"},
+		Template: "{{ .lang }}/{{ .tone }}/{{ .name }}",
+	}
+	ref := strictSnippet()
+	literal := types.Snippet{
+		ID:       "snp_literal",
+		Name:     "Literal",
+		Trigger:  ":greet/vi",
+		Template: "literal",
+	}
+	wrap := types.Snippet{
+		ID:       "snp_wrap",
+		Name:     "Wrap",
+		Trigger:  ":wrap",
+		Template: `[{{ snippet ":greet(ja,casual)" }}]`,
+	}
+	engine, _ := newTestEngine(t, greet, ref, wrap)
+
+	tests := []struct {
+		name    string
+		trigger string
+		want    string
+		wantErr error
+	}{
+		{"named params still work", ":greet?lang=vi&tone=casual", "vi/casual/there", nil},
+		{"slash args", ":greet/vi/casual", "vi/casual/there", nil},
+		{"paren args", ":greet(vi, casual, An)", "vi/casual/An", nil},
+		{"skipped arg keeps default", ":greet/vi//An", "vi/neutral/An", nil},
+		{"args with named params", ":greet/vi?name=An", "vi/neutral/An", nil},
+		{"aliases", ":greet?l=vi&t=casual", "vi/casual/there", nil},
+		{"include with args", ":wrap", "[ja/casual/there]", nil},
+		{"declared params give the order", ":ref/vi/4?code=ABC", "vi-ABC-0007", nil},
+		{"too many args", ":greet/vi/casual/An/extra", "", ErrTooManyArgs},
+		{"arg and named param", ":greet/vi?lang=ja", "", ErrAmbiguousParam},
+		{"alias and name", ":greet?l=vi&lang=ja", "", ErrAmbiguousParam},
+		{"strict args are validated", ":ref/xx?code=ABC", "", ErrInvalidParam},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.Expand(types.TriggerInput{RawTrigger: tt.trigger})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expand() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expand() error = %v", err)
+			}
+			if got.Output != tt.want {
+				t.Errorf("Expand() = %q, want %q", got.Output, tt.want)
+			}
+		})
+	}
+
+	t.Run("literal trigger with a slash", func(t *testing.T) {
+		engine, _ := newTestEngine(t, literal)
+		got, err := engine.Expand(types.TriggerInput{RawTrigger: ":greet/vi"})
+		if err != nil {
+			t.Fatalf("Expand() error = %v", err)
+		}
+		if got.Output != "literal" {
+			t.Errorf("Expand() = %q, want %q", got.Output, "literal")
+		}
+	})
+
+	t.Run("literal and shorthand both match", func(t *testing.T) {
+		engine, _ := newTestEngine(t, greet, literal)
+		_, err := engine.Expand(types.TriggerInput{RawTrigger: ":greet/vi"})
+		if !errors.Is(err, ErrAmbiguousTrigger) {
+			t.Fatalf("Expand() error = %v, want ErrAmbiguousTrigger", err)
+		}
+	})
+}
+
+func TestEngine_ExpandRegexTrigger(t *testing.T) {
+	invoice := types.Snippet{
+		ID:       "snp_inv",
+		Name:     "Invoice",
+		Regex:    `inv(?P<number>\d+)`,
+		Defaults: map[string]any{"number": 0, "currency": "USD"},
+		Template: "Invoice {{ .number }} ({{ .currency }})",
+	}
+	jira := types.Snippet{
+		ID:       "snp_jira",
+		Name:     "Jira",
+		Regex:    `jira-(?P<project>[A-Z]+)-(?P<issue>\d+)`,
+		Template: "https://jira.example.com/browse/{{ .project }}-{{ .issue }}",
+	}
+	literal := types.Snippet{ID: "snp_inv1", Name: "First invoice", Trigger: "inv1", Template: "literal"}
+	wrap := types.Snippet{ID: "snp_wrap", Name: "Wrap", Trigger: "wrap", Template: `[{{ snippet ":inv7" }}]`}
+	engine, _ := newTestEngine(t, invoice, jira, literal, wrap)
+
+	tests := []struct {
+		name    string
+		trigger string
+		want    string
+		wantErr error
+	}{
+		{name: "capture", trigger: ":inv123", want: "Invoice 123 (USD)"},
+		{name: "capture with query", trigger: ":inv123?currency=VND", want: "Invoice 123 (VND)"},
+		{name: "several captures", trigger: ":jira-ABC-42", want: "https://jira.example.com/browse/ABC-42"},
+		{name: "literal wins", trigger: ":inv1", want: "literal"},
+		{name: "include", trigger: ":wrap", want: "[Invoice 7 (USD)]"},
+		{name: "capture and query clash", trigger: ":inv5?number=6", wantErr: ErrAmbiguousParam},
+		{name: "whole trigger only", trigger: ":inv12x", wantErr: ErrSnippetNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.Expand(types.TriggerInput{RawTrigger: tt.trigger})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expand(%q) error = %v, want %v", tt.trigger, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expand(%q) error = %v", tt.trigger, err)
+			}
+			if got.Output != tt.want {
+				t.Errorf("Expand(%q) = %q, want %q", tt.trigger, got.Output, tt.want)
+			}
+		})
+	}
+
+	candidates, err := engine.Candidates(":inv1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 2 || candidates[0].ID != "snp_inv1" || candidates[1].ID != "snp_inv" {
+		t.Errorf("Candidates(:inv1) = %+v, want snp_inv1 then snp_inv", candidates)
+	}
+
+	m, err := engine.NewMatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	match, ok := m.FeedString("see :jira-ABC-42?x=1\t")
+	if !ok || match.RawTrigger != ":jira-ABC-42?x=1" || match.Trigger != ":jira-ABC-42" {
+		t.Errorf("matcher = %+v, %v; want :jira-ABC-42 with its query", match, ok)
+	}
+	if _, ok := m.FeedString("see:inv12\t"); ok {
+		t.Error("matcher should respect boundaries for regex triggers")
+	}
+}
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/snipq/core/pkg/parser"
+	"github.com/snipq/core/pkg/template"
+	"github.com/snipq/core/pkg/types"
+)
+
+// caseStyle is the case pattern of a typed trigger applied to the output
+type caseStyle int
+
+const (
+	caseAsIs  caseStyle = iota
+	caseFirst           // :Ty -> "Thank you"
+	caseUpper           // :TY -> "THANK YOU"
+)
+
+// propagatesCase reports whether a snippet's output follows the case of
+// the typed trigger: the snippet's propagateCase, or the vault setting
+func (e *Engine) propagatesCase(snippet *types.Snippet) bool {
+	if snippet.PropagateCase != nil {
+		return *snippet.PropagateCase
+	}
+	return e.vault.GetSettings().PropagateCase
+}
+
+// matchFoldedTrigger resolves a trigger typed with capitals, such as :Ty
+// or :TY, to a case-propagating snippet with the lower-case trigger. The
+// match carries the case style to apply; it is nil when nothing matches.
+func (e *Engine) matchFoldedTrigger(parsed *parser.ParsedTrigger, snippetID string, opts types.ListOptions) (*triggerMatch, error) {
+	folded := *parsed
+	folded.Trigger = strings.ToLower(parsed.Trigger)
+	folded.Base = strings.ToLower(parsed.Base)
+	if folded.Trigger == parsed.Trigger {
+		return nil, nil
+	}
+
+	snippet, args, err := resolveTrigger(&folded, e.triggerFinder(snippetID, opts))
+	if err != nil || snippet == nil || !e.propagatesCase(snippet) {
+		return nil, err
+	}
+
+	typed := parsed.Trigger
+	if args != nil {
+		typed = parsed.Base
+	}
+	return &triggerMatch{snippet: snippet, args: args, casing: typedCase(typed)}, nil
+}
+
+// typedCase returns the case style of a typed trigger's letters: upper
+// when there are several and all are upper case, first-letter when the
+// first one is
+func typedCase(trigger string) caseStyle {
+	var letters []rune
+	for _, r := range trigger {
+		if unicode.IsLetter(r) {
+			letters = append(letters, r)
+		}
+	}
+	if len(letters) == 0 || !unicode.IsUpper(letters[0]) {
+		return caseAsIs
+	}
+	if len(letters) == 1 {
+		return caseFirst
+	}
+	for _, r := range letters[1:] {
+		if !unicode.IsUpper(r) {
+			return caseFirst
+		}
+	}
+	return caseUpper
+}
+
+// applyCase changes the case of rendered output. Runes are mapped one to
+// one, so cursor and tab stop offsets stay valid.
+func applyCase(result *template.Result, style caseStyle) {
+	switch style {
+	case caseUpper:
+		result.Text = upperRunes(result.Text)
+		for i := range result.TabStops {
+			result.TabStops[i].Placeholder = upperRunes(result.TabStops[i].Placeholder)
+		}
+	case caseFirst:
+		runes := []rune(result.Text)
+		for i, r := range runes {
+			if !unicode.IsLetter(r) {
+				continue
+			}
+			runes[i] = upperRune(r)
+			result.Text = string(runes)
+
+			for j := range result.TabStops {
+				stop := &result.TabStops[j]
+				if i >= stop.Offset && i < stop.Offset+stop.Length {
+					placeholder := []rune(stop.Placeholder)
+					placeholder[i-stop.Offset] = upperRune(placeholder[i-stop.Offset])
+					stop.Placeholder = string(placeholder)
+				}
+			}
+			return
+		}
+	}
+}
+
+func upperRunes(s string) string {
+	return strings.Map(upperRune, s)
+}
+
+// upperRune upper-cases r unless that would change its UTF-16 length
+func upperRune(r rune) rune {
+	upper := unicode.ToUpper(r)
+	if (upper > 0xFFFF) != (r > 0xFFFF) {
+		return r
+	}
+	return upper
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/snipq/core/pkg/template"
+	"github.com/snipq/core/pkg/types"
+)
+
+func TestTypedCase(t *testing.T) {
+	tests := []struct {
+		trigger string
+		want    caseStyle
+	}{
+		{":ty", caseAsIs},
+		{":Ty", caseFirst},
+		{":TY", caseUpper},
+		{":T", caseFirst},
+		{":tY", caseAsIs},
+		{":TY2", caseUpper},
+		{":Ăn", caseFirst},
+		{":42", caseAsIs},
+	}
+
+	for _, tt := range tests {
+		if got := typedCase(tt.trigger); got != tt.want {
+			t.Errorf("typedCase(%q) = %v, want %v", tt.trigger, got, tt.want)
+		}
+	}
+}
+
+func TestApplyCase(t *testing.T) {
+	result := &template.Result{
+		Text:     "  hello wörld ß",
+		Cursor:   8,
+		TabStops: []types.TabStop{{Index: 1, Offset: 2, Length: 5, Placeholder: "hello"}},
+	}
+	applyCase(result, caseFirst)
+	if result.Text != "  Hello wörld ß" || result.TabStops[0].Placeholder != "Hello" {
+		t.Errorf("applyCase(first) = %q, %q", result.Text, result.TabStops[0].Placeholder)
+	}
+
+	applyCase(result, caseUpper)
+	// ß has no single-rune upper case, so offsets stay valid
+	if result.Text != "  HELLO WÖRLD ß" || result.TabStops[0].Placeholder != "HELLO" || result.Cursor != 8 {
+		t.Errorf("applyCase(upper) = %q, %q, cursor %d", result.Text, result.TabStops[0].Placeholder, result.Cursor)
+	}
+}
+
+func TestEngine_PropagateCase(t *testing.T) {
+	on, off := true, false
+	thanks := types.Snippet{ID: "snp_ty", Name: "Thanks", Trigger: "ty", PropagateCase: &on, Template: "thank you, ${1:friend}"}
+	plain := types.Snippet{ID: "snp_sig", Name: "Signature", Trigger: "sig", Template: "-- an"}
+	optOut := types.Snippet{ID: "snp_addr", Name: "Address", Trigger: "addr", PropagateCase: &off, Template: "hanoi"}
+	engine, _ := newTestEngine(t, thanks, plain, optOut)
+
+	tests := []struct {
+		trigger string
+		want    string
+		wantErr error
+	}{
+		{":ty", "thank you, friend", nil},
+		{":Ty", "Thank you, friend", nil},
+		{":TY", "THANK YOU, FRIEND", nil},
+		{":Ty?x=1", "Thank you, friend", nil},
+		{":Sig", "", ErrSnippetNotFound},
+	}
+	for _, tt := range tests {
+		got, err := engine.Expand(types.TriggerInput{RawTrigger: tt.trigger})
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expand(%q) error = %v, want %v", tt.trigger, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got.Output != tt.want {
+			t.Errorf("Expand(%q) = %q, %v; want %q", tt.trigger, got.Output, err, tt.want)
+		}
+	}
+
+	got, _ := engine.Expand(types.TriggerInput{RawTrigger: ":TY"})
+	if len(got.TabStops) != 1 || got.TabStops[0].Placeholder != "FRIEND" {
+		t.Errorf("Expand(:TY) tab stops = %+v", got.TabStops)
+	}
+
+	// The vault setting turns it on for snippets without their own choice
+	settings, _ := engine.GetSettings()
+	settings.PropagateCase = true
+	if err := engine.SaveSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := engine.Preview(types.TriggerInput{RawTrigger: ":SIG"}); err != nil || got != "-- AN" {
+		t.Errorf("Preview(:SIG) = %q, %v; want %q", got, err, "-- AN")
+	}
+	if _, err := engine.Preview(types.TriggerInput{RawTrigger: ":Addr"}); !errors.Is(err, ErrSnippetNotFound) {
+		t.Errorf("Preview(:Addr) error = %v, want ErrSnippetNotFound", err)
+	}
+
+	m, err := engine.NewMatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	match, ok := m.FeedString("ok :TY\t")
+	if !ok || match.RawTrigger != ":TY" || match.Delete != 3 {
+		t.Errorf("matcher = %+v, %v; want :TY", match, ok)
+	}
+	if _, ok := m.FeedString("ok :ADDR\t"); ok {
+		t.Error("matcher should not fold triggers of snippets that opt out")
+	}
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrClipboardUnavailable is returned by providers that cannot read the clipboard
+var ErrClipboardUnavailable = errors.New("clipboard unavailable")
+
+// ClipboardProvider gives the engine access to the host clipboard.
+// Host apps (tray app, browser extension, mobile keyboard) implement it
+// on top of their platform clipboard API.
+type ClipboardProvider interface {
+	ReadText() (string, error)
+}
+
+// MemoryClipboard is an in-memory ClipboardProvider for tests and hosts
+// that receive clipboard text from elsewhere (e.g. the CLI's --clipboard flag)
+type MemoryClipboard struct {
+	mu   sync.Mutex
+	text string
+	set  bool
+}
+
+// NewMemoryClipboard creates an in-memory clipboard holding text
+func NewMemoryClipboard(text string) *MemoryClipboard {
+	return &MemoryClipboard{text: text, set: true}
+}
+
+// ReadText returns the stored text, or ErrClipboardUnavailable if none was set
+func (c *MemoryClipboard) ReadText() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.set {
+		return "", ErrClipboardUnavailable
+	}
+	return c.text, nil
+}
+
+// SetText replaces the stored text
+func (c *MemoryClipboard) SetText(text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.text = text
+	c.set = true
+}
+
+// Clear empties the clipboard so reads report it as unavailable
+func (c *MemoryClipboard) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.text = ""
+	c.set = false
+}
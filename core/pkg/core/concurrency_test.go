@@ -0,0 +1,228 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/snipq/core/pkg/types"
+)
+
+// TestEngine_Concurrent hammers Expand, UpsertSnippet, Reload and
+// SetClipboardProvider in parallel. Run it with -race.
+func TestEngine_Concurrent(t *testing.T) {
+	engine, _ := newTestEngine(t,
+		types.Snippet{ID: "snp_inv", Name: "Invoice", Trigger: "inv", Template: `{{ counter "inv" }}`},
+		types.Snippet{ID: "snp_sig", Name: "Signature", Trigger: "sig", Template: "-- v0"},
+		types.Snippet{ID: "snp_clip", Name: "Clipboard", Trigger: "clip", Template: "{{ clipboard }}"},
+	)
+
+	const (
+		expanders  = 8
+		expansions = 40
+		edits      = 40
+	)
+
+	var wg sync.WaitGroup
+	values := make(chan int, expanders*expansions+edits)
+	errs := make(chan error, expanders*expansions+4*edits)
+
+	for i := 0; i < expanders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < expansions; j++ {
+				got, err := engine.Expand(types.TriggerInput{RawTrigger: ":inv"})
+				if err != nil {
+					errs <- err
+					continue
+				}
+				value, err := strconv.Atoi(got.Output)
+				if err != nil {
+					errs <- err
+					continue
+				}
+				values <- value
+
+				if _, err := engine.Expand(types.TriggerInput{RawTrigger: ":clip"}); err != nil {
+					errs <- err
+				}
+				if _, err := engine.Expand(types.TriggerInput{RawTrigger: ":sig"}); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < edits; j++ {
+			sig := types.Snippet{ID: "snp_sig", GroupID: "test-group", Name: "Signature", Trigger: "sig", Template: fmt.Sprintf("-- v%d", j)}
+			if err := engine.UpsertSnippet(sig); err != nil {
+				errs <- err
+			}
+			engine.SetClipboardProvider(NewMemoryClipboard(sig.Template))
+			next, err := engine.NextCounter("inv", types.CounterOpts{})
+			if err != nil {
+				errs <- err
+				continue
+			}
+			value, _ := strconv.Atoi(next)
+			values <- value
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < edits; j++ {
+			if _, err := engine.Reload(); err != nil {
+				errs <- err
+			}
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < edits; j++ {
+			if _, err := engine.ListSnippets("test-group"); err != nil {
+				errs <- err
+			}
+			engine.Suggest(":si", 3)
+			if _, err := engine.Candidates(":sig"); err != nil {
+				errs <- err
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(values)
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// Every expansion got its own counter value
+	seen := make(map[int]bool)
+	for value := range values {
+		if seen[value] {
+			t.Errorf("counter value %d handed out twice", value)
+		}
+		seen[value] = true
+	}
+	for value := 1; value <= len(seen); value++ {
+		if !seen[value] {
+			t.Errorf("counter value %d skipped", value)
+		}
+	}
+
+	if got, err := engine.Preview(types.TriggerInput{RawTrigger: ":sig"}); err != nil || got != fmt.Sprintf("-- v%d", edits-1) {
+		t.Errorf("Preview(:sig) = %q, %v; want the last edit", got, err)
+	}
+}
+
+// TestEngine_CrossProcess expands a counter from several processes
+// sharing a vault, as the CLI, a tray app and a sync client would
+func TestEngine_CrossProcess(t *testing.T) {
+	const (
+		processes  = 4
+		expansions = 25
+	)
+	engine, vaultDir := newTestEngine(t,
+		types.Snippet{ID: "snp_inv", Name: "Invoice", Trigger: "inv", Template: `{{ counter "inv" }}`},
+	)
+
+	var wg sync.WaitGroup
+	outputs := make([][]byte, processes)
+	for i := range outputs {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcessExpand$")
+			cmd.Env = append(os.Environ(), "SNIPQ_HELPER_VAULT="+vaultDir, fmt.Sprintf("SNIPQ_HELPER_EXPANSIONS=%d", expansions))
+			out, err := cmd.Output()
+			if err != nil {
+				t.Errorf("helper process: %v\n%s", err, out)
+			}
+			outputs[i] = out
+		}()
+	}
+
+	// This process expands too
+	var values []int
+	for j := 0; j < expansions; j++ {
+		got, err := engine.Expand(types.TriggerInput{RawTrigger: ":inv"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, _ := strconv.Atoi(got.Output)
+		values = append(values, value)
+	}
+	wg.Wait()
+
+	for _, out := range outputs {
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		for scanner.Scan() {
+			if value, err := strconv.Atoi(scanner.Text()); err == nil {
+				values = append(values, value)
+			}
+		}
+	}
+	if len(values) != (processes+1)*expansions {
+		t.Fatalf("got %d counter values, want %d", len(values), (processes+1)*expansions)
+	}
+
+	seen := make(map[int]bool)
+	for _, value := range values {
+		if seen[value] {
+			t.Errorf("counter value %d handed out twice", value)
+		}
+		seen[value] = true
+	}
+	for value := 1; value <= len(values); value++ {
+		if !seen[value] {
+			t.Errorf("counter value %d skipped", value)
+		}
+	}
+
+	// No process overwrote the history of another
+	if _, err := engine.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(engine.vault.GetHistory()); got != len(values) {
+		t.Errorf("history has %d entries, want %d", got, len(values))
+	}
+}
+
+// TestHelperProcessExpand is run by TestEngine_CrossProcess in separate
+// processes and prints the counter values it expanded
+func TestHelperProcessExpand(t *testing.T) {
+	vaultDir := os.Getenv("SNIPQ_HELPER_VAULT")
+	if vaultDir == "" {
+		t.Skip("helper process for TestEngine_CrossProcess")
+	}
+	expansions, err := strconv.Atoi(os.Getenv("SNIPQ_HELPER_EXPANSIONS"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := NewEngine()
+	if err := engine.OpenVault(vaultDir); err != nil {
+		t.Fatal(err)
+	}
+	for j := 0; j < expansions; j++ {
+		got, err := engine.Expand(types.TriggerInput{RawTrigger: ":inv"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Println(got.Output)
+	}
+}
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snipq/core/pkg/types"
+	"github.com/snipq/core/pkg/vault"
+)
+
+// counterSession hands out counter values for a single expansion.
+// Each counter advances at most once per session, so a template that
+// references the same counter twice renders the same value. Nothing is
+// written to the vault until commit is called.
+type counterSession struct {
+	vault   *vault.Vault
+	now     time.Time
+	read    map[string]*types.Counter // counters as read from the vault
+	pending map[string]*types.Counter
+}
+
+func newCounterSession(v *vault.Vault, now time.Time) *counterSession {
+	return &counterSession{
+		vault:   v,
+		now:     now,
+		read:    make(map[string]*types.Counter),
+		pending: make(map[string]*types.Counter),
+	}
+}
+
+// Next returns the value the named counter takes in this session
+func (s *counterSession) Next(name string) (int, error) {
+	if counter, ok := s.pending[name]; ok {
+		return counter.Value, nil
+	}
+
+	current := s.vault.GetCounter(name)
+	counter, err := advanceCounter(current, 0, s.now)
+	if err != nil {
+		return 0, err
+	}
+
+	s.read[name] = current
+	s.pending[name] = counter
+	return counter.Value, nil
+}
+
+// commit persists every counter advanced during the session. It fails
+// with vault.ErrCounterConflict, storing nothing, if another expansion
+// advanced one of them since it was read.
+func (s *counterSession) commit() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	if err := s.vault.SwapCounters(s.read, s.pending); err != nil {
+		return fmt.Errorf("failed to update counters: %w", err)
+	}
+	return nil
+}
+
+// newCounter returns the initial state of a counter that has never been used
+func newCounter() *types.Counter {
+	return &types.Counter{
+		Value: 0,
+		Step:  1,
+		Start: 1,
+	}
+}
+
+// advanceCounter returns a copy of current moved to its next value.
+// A step of 0 uses the counter's own step. A nil counter starts at 1.
+func advanceCounter(current *types.Counter, step int, now time.Time) (*types.Counter, error) {
+	fresh := current == nil
+	if fresh {
+		current = newCounter()
+	}
+
+	next := *current
+	if next.Step == 0 {
+		next.Step = 1
+	}
+	if step == 0 {
+		step = next.Step
+	}
+
+	expired, err := counterExpired(&next, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if fresh || expired {
+		next.Value = next.Start
+	} else {
+		next.Value += step
+	}
+	next.UpdatedAt = now
+
+	return &next, nil
+}
+
+// resetCounter returns a copy of current whose next value is Start
+func resetCounter(current *types.Counter, now time.Time) *types.Counter {
+	if current == nil {
+		current = newCounter()
+	}
+
+	next := *current
+	if next.Step == 0 {
+		next.Step = 1
+	}
+	next.Value = next.Start - next.Step
+	next.UpdatedAt = now
+
+	return &next
+}
+
+// counterExpired reports whether the counter's reset period has rolled over
+func counterExpired(counter *types.Counter, now time.Time) (bool, error) {
+	if counter.UpdatedAt.IsZero() {
+		return false, nil
+	}
+
+	last := counter.UpdatedAt.In(now.Location())
+	switch counter.Reset {
+	case "":
+		return false, nil
+	case types.CounterResetDaily:
+		return last.YearDay() != now.YearDay() || last.Year() != now.Year(), nil
+	case types.CounterResetMonthly:
+		return last.Month() != now.Month() || last.Year() != now.Year(), nil
+	case types.CounterResetYearly:
+		return last.Year() != now.Year(), nil
+	}
+
+	return false, fmt.Errorf("unknown counter reset period: %s", counter.Reset)
+}
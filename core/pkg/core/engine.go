@@ -1,20 +1,39 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/snipq/core/pkg/parser"
 	"github.com/snipq/core/pkg/template"
 	"github.com/snipq/core/pkg/types"
 	"github.com/snipq/core/pkg/vault"
+	"github.com/snipq/core/pkg/watch"
 )
 
-// Engine implements the Core interface
+// ErrGroupDisabled is returned when a trigger only exists in disabled groups
+var ErrGroupDisabled = errors.New("group disabled")
+
+// Engine implements the Core interface. It is safe for concurrent use.
+// Expansions read snippets and settings from a snapshot without locking,
+// and edits and reloads replace it atomically, so an expansion sees a
+// snippet either before or after an edit. Committing counters and history takes the vault lock, so
+// expansions that use them wait for edits, reloads and other processes
+// sharing the vault, and never hand out the same counter value.
 type Engine struct {
-	vault    *vault.Vault
-	template *template.Engine
+	vault     *vault.Vault
+	template  *template.Engine
+	clipboard atomic.Pointer[ClipboardProvider]
+	counterMu sync.RWMutex // see lockCounters
+
+	watchMu     sync.Mutex
+	watcher     *watch.Watcher
+	subscribers map[int]func(types.ChangeEvent)
+	nextSubID   int
 }
 
 // NewEngine creates a new core engine
@@ -30,13 +49,28 @@ func (e *Engine) OpenVault(path string) error {
 	return e.vault.Load(path)
 }
 
-// Reload reloads the vault from disk
-func (e *Engine) Reload() error {
-	// For now, just reload the vault
-	// In the future, we might need to track the vault path
+// SetClipboardProvider sets the clipboard used by the clipboard template function.
+// Passing nil makes the clipboard render as empty. It may be called while
+// expansions run.
+func (e *Engine) SetClipboardProvider(provider ClipboardProvider) {
+	e.clipboard.Store(&provider)
+}
+
+// clipboardProvider returns the provider set by SetClipboardProvider
+func (e *Engine) clipboardProvider() ClipboardProvider {
+	if provider := e.clipboard.Load(); provider != nil {
+		return *provider
+	}
 	return nil
 }
 
+// Reload re-reads the vault from disk after external edits and reports
+// which snippets, groups and settings changed. If any file fails to load
+// the engine keeps its current state.
+func (e *Engine) Reload() (types.VaultDiff, error) {
+	return e.vault.Reload()
+}
+
 // Save saves the vault to disk
 func (e *Engine) Save() error {
 	return e.vault.Save()
@@ -56,10 +90,11 @@ func (e *Engine) Expand(input types.TriggerInput) (types.Rendered, error) {
 	}
 
 	// Find the snippet
-	snippet := e.vault.FindSnippetByTrigger(parsed.Trigger)
-	if snippet == nil {
-		return types.Rendered{}, fmt.Errorf("snippet not found: %s", parsed.Trigger)
+	match, err := e.lookupTrigger(parsed, input.SnippetID)
+	if err != nil {
+		return types.Rendered{}, err
 	}
+	snippet := match.snippet
 
 	// Check if app is excluded
 	settings := e.vault.GetSettings()
@@ -67,32 +102,35 @@ func (e *Engine) Expand(input types.TriggerInput) (types.Rendered, error) {
 		return types.Rendered{}, fmt.Errorf("app excluded: %s", input.AppID)
 	}
 
-	// Merge parameters
-	globalDefaults := e.getGlobalDefaults(settings)
-	mergedParams := parser.MergeParams(parsed.Params, snippet.Defaults, globalDefaults)
-
-	// Add special variables
-	mergedParams["now"] = input.Now
-	mergedParams["timestamp"] = input.Now.Unix()
-
-	// Handle counters if the template uses them
-	err = e.handleCounters(snippet.Template, mergedParams)
+	// Map positional args, regex captures and aliases onto param names
+	query, err := match.query(parsed.Values)
 	if err != nil {
-		return types.Rendered{}, fmt.Errorf("failed to handle counters: %w", err)
+		return types.Rendered{}, err
 	}
 
-	// Render the template
-	output, err := e.template.Render(snippet.Template, mergedParams)
+	// Ask for fill-in fields the query does not supply
+	if err := missingFields(snippet, query); err != nil {
+		return types.Rendered{}, err
+	}
+
+	// Merge parameters, render the template and persist its counters
+	x, mergedParams, result, err := e.renderCommitted(snippet, query, input.Now)
 	if err != nil {
-		return types.Rendered{}, fmt.Errorf("failed to render template: %w", err)
+		return types.Rendered{}, err
 	}
 
+	// Follow the case of the typed trigger
+	applyCase(result, match.casing)
+
 	// Create rendered result
 	rendered := types.Rendered{
-		Output:       output,
-		CursorOffset: 0, // TODO: Calculate cursor position from template
-		UsedSnippet:  snippet.ID,
-		UsedParams:   mergedParams,
+		Output:            result.Text,
+		CursorOffset:      result.Cursor,
+		CursorOffsetUTF16: result.CursorUTF16,
+		TabStops:          result.TabStops,
+		UsedSnippet:       snippet.ID,
+		NestedSnippets:    x.used,
+		UsedParams:        mergedParams,
 	}
 
 	// Add to history
@@ -100,7 +138,7 @@ func (e *Engine) Expand(input types.TriggerInput) (types.Rendered, error) {
 		historyEntry := &types.HistoryEntry{
 			Timestamp:  input.Now,
 			SnippetID:  snippet.ID,
-			Output:     output,
+			Output:     result.Text,
 			UsedParams: mergedParams,
 			AppID:      input.AppID,
 		}
@@ -111,6 +149,37 @@ func (e *Engine) Expand(input types.TriggerInput) (types.Rendered, error) {
 	return rendered, nil
 }
 
+// Candidates returns every snippet a raw trigger may expand, in
+// resolution order, so hosts can offer a picker when groups share a
+// trigger. Expanding with TriggerInput.SnippetID selects a candidate.
+// Literal triggers come before regex triggers, and snippets in disabled
+// groups are not candidates.
+func (e *Engine) Candidates(rawTrigger string) ([]types.Snippet, error) {
+	parsed, err := parser.ParseTrigger(rawTrigger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse trigger: %w", err)
+	}
+
+	found := e.vault.FindSnippetsByTrigger(parsed.Trigger, types.ListOptions{})
+	if parsed.Base != "" {
+		found = append(found, e.vault.FindSnippetsByTrigger(parsed.Base, types.ListOptions{})...)
+	}
+	for _, match := range e.vault.FindSnippetsByPattern(parsed.Trigger, types.ListOptions{}) {
+		found = append(found, match.Snippet)
+	}
+
+	seen := make(map[string]bool, len(found))
+	candidates := make([]types.Snippet, 0, len(found))
+	for _, snippet := range found {
+		if !seen[snippet.ID] {
+			seen[snippet.ID] = true
+			candidates = append(candidates, *snippet)
+		}
+	}
+
+	return candidates, nil
+}
+
 // Preview previews a trigger expansion without side effects
 func (e *Engine) Preview(input types.TriggerInput) (string, error) {
 	// Set default timestamp if not provided
@@ -125,27 +194,47 @@ func (e *Engine) Preview(input types.TriggerInput) (string, error) {
 	}
 
 	// Find the snippet
-	snippet := e.vault.FindSnippetByTrigger(parsed.Trigger)
-	if snippet == nil {
-		return "", fmt.Errorf("snippet not found: %s", parsed.Trigger)
+	match, err := e.lookupTrigger(parsed, input.SnippetID)
+	if err != nil {
+		return "", err
 	}
+	snippet := match.snippet
 
-	// Merge parameters
-	settings := e.vault.GetSettings()
-	globalDefaults := e.getGlobalDefaults(settings)
-	mergedParams := parser.MergeParams(parsed.Params, snippet.Defaults, globalDefaults)
+	// Map positional args, regex captures and aliases onto param names
+	query, err := match.query(parsed.Values)
+	if err != nil {
+		return "", err
+	}
 
-	// Add special variables
-	mergedParams["now"] = input.Now
-	mergedParams["timestamp"] = input.Now.Unix()
+	// Ask for fill-in fields the query does not supply
+	if err := missingFields(snippet, query); err != nil {
+		return "", err
+	}
 
-	// Render the template (without side effects like updating counters)
-	return e.template.Render(snippet.Template, mergedParams)
+	// Merge parameters
+	x := e.newExpansion(input.Now)
+	mergedParams, err := x.params(snippet, query)
+	if err != nil {
+		return "", err
+	}
+
+	// Render the template (counters show their next value but are never committed)
+	result, err := x.render(snippet, mergedParams)
+	if err != nil {
+		return "", err
+	}
+	applyCase(result, match.casing)
+	return result.Text, nil
 }
 
-// ListGroups returns all groups
+// ListGroups returns the enabled groups
 func (e *Engine) ListGroups() ([]types.Group, error) {
-	vaultGroups := e.vault.ListGroups()
+	return e.ListGroupsWith(types.ListOptions{})
+}
+
+// ListGroupsWith returns the groups selected by opts
+func (e *Engine) ListGroupsWith(opts types.ListOptions) ([]types.Group, error) {
+	vaultGroups := e.vault.ListGroups(opts)
 	groups := make([]types.Group, len(vaultGroups))
 
 	for i, vg := range vaultGroups {
@@ -155,16 +244,33 @@ func (e *Engine) ListGroups() ([]types.Group, error) {
 	return groups, nil
 }
 
-// ListSnippets returns all snippets for a group
+// ListSnippets returns all snippets for an enabled group
 func (e *Engine) ListSnippets(groupID string) ([]types.Snippet, error) {
-	vaultSnippets := e.vault.ListSnippets(groupID)
-	snippets := make([]types.Snippet, len(vaultSnippets))
+	return e.ListSnippetsWith(groupID, types.ListOptions{})
+}
 
-	for i, vs := range vaultSnippets {
-		snippets[i] = *vs
+// ListSnippetsWith returns all snippets for a group, including those of a
+// disabled group when opts.IncludeDisabled is set
+func (e *Engine) ListSnippetsWith(groupID string, opts types.ListOptions) ([]types.Snippet, error) {
+	return copySnippets(e.vault.ListSnippets(groupID, opts)), nil
+}
+
+// SearchSnippets searches snippets by name, trigger or tags
+func (e *Engine) SearchSnippets(query string, opts types.ListOptions) ([]types.Snippet, error) {
+	return copySnippets(e.vault.SearchSnippets(query, opts)), nil
+}
+
+// SetGroupEnabled enables or disables a group. Snippets in a disabled
+// group do not expand.
+func (e *Engine) SetGroupEnabled(groupID string, enabled bool) error {
+	group, err := e.vault.GetGroup(groupID)
+	if err != nil {
+		return err
 	}
 
-	return snippets, nil
+	updated := *group
+	updated.Enabled = enabled
+	return e.vault.UpsertGroup(&updated)
 }
 
 // UpsertSnippet adds or updates a snippet
@@ -172,6 +278,21 @@ func (e *Engine) UpsertSnippet(s types.Snippet) error {
 	return e.vault.UpsertSnippet(&s)
 }
 
+// FullTrigger returns what a user types to expand a snippet: the
+// configured prefix followed by the snippet's trigger name
+func (e *Engine) FullTrigger(s types.Snippet) string {
+	return e.vault.GetSettings().Prefix + e.vault.TriggerName(s.Trigger)
+}
+
+// MigrateTriggerPrefix strips prefix (the configured prefix when empty)
+// from stored snippet triggers and returns how many snippets changed
+func (e *Engine) MigrateTriggerPrefix(prefix string) (int, error) {
+	if prefix == "" {
+		prefix = e.vault.GetSettings().Prefix
+	}
+	return e.vault.MigrateTriggerPrefix(prefix)
+}
+
 // DeleteSnippet deletes a snippet
 func (e *Engine) DeleteSnippet(id string) error {
 	return e.vault.DeleteSnippet(id)
@@ -190,31 +311,25 @@ func (e *Engine) SaveSettings(settings types.Settings) error {
 
 // NextCounter increments and returns the next counter value
 func (e *Engine) NextCounter(name string, opts types.CounterOpts) (string, error) {
-	counter := e.vault.GetCounter(name)
-	if counter == nil {
-		// Create new counter
-		counter = &types.Counter{
-			Value:     1,
-			Step:      1,
-			Start:     1,
-			UpdatedAt: time.Now(),
+	var counter *types.Counter
+	for attempt := 1; ; attempt++ {
+		unlock := e.lockCounters(attempt > 1)
+		current := e.vault.GetCounter(name)
+		var err error
+		if counter, err = advanceCounter(current, opts.Step, time.Now()); err != nil {
+			unlock()
+			return "", err
 		}
-	}
 
-	// Apply options
-	step := counter.Step
-	if opts.Step > 0 {
-		step = opts.Step
-	}
-
-	// Update counter
-	counter.Value += step
-	counter.UpdatedAt = time.Now()
-
-	// Save counter
-	err := e.vault.UpdateCounter(name, counter)
-	if err != nil {
-		return "", err
+		// Save counter, unless another expansion advanced it meanwhile
+		err = e.vault.SwapCounters(map[string]*types.Counter{name: current}, map[string]*types.Counter{name: counter})
+		unlock()
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, vault.ErrCounterConflict) || attempt == maxCounterAttempts {
+			return "", err
+		}
 	}
 
 	// Format with padding if specified
@@ -225,6 +340,11 @@ func (e *Engine) NextCounter(name string, opts types.CounterOpts) (string, error
 	return strconv.Itoa(counter.Value), nil
 }
 
+// ResetCounter resets a counter so its next value is its start value
+func (e *Engine) ResetCounter(name string) error {
+	return e.vault.UpdateCounter(name, resetCounter(e.vault.GetCounter(name), time.Now()))
+}
+
 // Private helper methods
 
 func (e *Engine) isAppExcluded(appID string, excludedApps []string) bool {
@@ -236,6 +356,88 @@ func (e *Engine) isAppExcluded(appID string, excludedApps []string) bool {
 	return false
 }
 
+// lookupTrigger resolves a parsed trigger to a snippet in an enabled
+// group. Literal triggers are tried before regex triggers. A trigger that
+// only exists in disabled groups returns ErrGroupDisabled; an unknown one
+// returns a *NotFoundError with suggestions.
+func (e *Engine) lookupTrigger(parsed *parser.ParsedTrigger, snippetID string) (*triggerMatch, error) {
+	match, err := e.matchTrigger(parsed, snippetID, types.ListOptions{})
+	if err != nil || match != nil {
+		return match, err
+	}
+
+	all := types.ListOptions{IncludeDisabled: true}
+	if disabled, _ := e.matchTrigger(parsed, snippetID, all); disabled != nil {
+		return nil, fmt.Errorf("%w: %s is in disabled group %s", ErrGroupDisabled, parsed.Trigger, disabled.snippet.GroupID)
+	}
+
+	typed := parsed.Trigger
+	if parsed.Base != "" {
+		typed = parsed.Base
+	}
+	return nil, &NotFoundError{Trigger: parsed.Trigger, Suggestions: e.Suggest(typed, MaxSuggestions)}
+}
+
+// matchTrigger resolves a parsed trigger by literal trigger, then by the
+// lower-case literal trigger of a case-propagating snippet, then by regex
+// trigger. The match is nil when nothing matches.
+func (e *Engine) matchTrigger(parsed *parser.ParsedTrigger, snippetID string, opts types.ListOptions) (*triggerMatch, error) {
+	snippet, args, err := resolveTrigger(parsed, e.triggerFinder(snippetID, opts))
+	if err != nil {
+		return nil, err
+	}
+	if snippet != nil {
+		return &triggerMatch{snippet: snippet, args: args}, nil
+	}
+
+	if match, err := e.matchFoldedTrigger(parsed, snippetID, opts); err != nil || match != nil {
+		return match, err
+	}
+
+	for _, match := range e.vault.FindSnippetsByPattern(parsed.Trigger, opts) {
+		if snippetID == "" || match.Snippet.ID == snippetID {
+			return &triggerMatch{snippet: match.Snippet, captures: match.Captures}, nil
+		}
+	}
+	return nil, nil
+}
+
+// triggerFinder returns the trigger lookup used by Expand and Preview:
+// the first candidate, or only the candidate with snippetID when set
+func (e *Engine) triggerFinder(snippetID string, opts types.ListOptions) func(string) *types.Snippet {
+	return func(trigger string) *types.Snippet {
+		for _, snippet := range e.vault.FindSnippetsByTrigger(trigger, opts) {
+			if snippetID == "" || snippet.ID == snippetID {
+				return snippet
+			}
+		}
+		return nil
+	}
+}
+
+func copySnippets(vaultSnippets []*types.Snippet) []types.Snippet {
+	snippets := make([]types.Snippet, len(vaultSnippets))
+	for i, vs := range vaultSnippets {
+		snippets[i] = *vs
+	}
+	return snippets
+}
+
+// findSnippet finds a snippet by typed trigger or trigger name, falling
+// back to its ID
+func (e *Engine) findSnippet(ref string) *types.Snippet {
+	if snippet := e.vault.FindSnippetByTrigger(ref); snippet != nil {
+		return snippet
+	}
+	if snippet := e.vault.FindSnippetByName(ref); snippet != nil {
+		return snippet
+	}
+	if snippet, err := e.vault.GetSnippet(ref); err == nil {
+		return snippet
+	}
+	return nil
+}
+
 func (e *Engine) getGlobalDefaults(settings *types.Settings) map[string]any {
 	return map[string]any{
 		"dateFormat": settings.DefaultDateFormat,
@@ -243,10 +445,3 @@ func (e *Engine) getGlobalDefaults(settings *types.Settings) map[string]any {
 		"locale":     settings.Locale,
 	}
 }
-
-func (e *Engine) handleCounters(templateText string, params map[string]any) error {
-	// This is a simplified implementation
-	// In a real implementation, we would parse the template to find counter calls
-	// and pre-populate their values to avoid multiple increments during rendering
-	return nil
-}
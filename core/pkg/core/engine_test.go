@@ -0,0 +1,492 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/snipq/core/pkg/types"
+)
+
+// newTestEngine creates an engine over a temporary vault containing the given snippets
+func newTestEngine(t *testing.T, snippets ...types.Snippet) (*Engine, string) {
+	t.Helper()
+
+	vaultDir := t.TempDir()
+	groupDir := filepath.Join(vaultDir, "groups", "test-group")
+	if err := os.MkdirAll(filepath.Join(groupDir, "snippets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	group := types.Group{ID: "test-group", Name: "Test Group", Enabled: true}
+	writeYAML(t, filepath.Join(groupDir, "group.yaml"), group)
+
+	for _, snippet := range snippets {
+		writeYAML(t, filepath.Join(groupDir, "snippets", snippet.ID+".yaml"), snippet)
+	}
+
+	engine := NewEngine()
+	if err := engine.OpenVault(vaultDir); err != nil {
+		t.Fatal(err)
+	}
+
+	return engine, vaultDir
+}
+
+func writeYAML(t *testing.T, path string, value any) {
+	t.Helper()
+
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var invoiceSnippet = types.Snippet{
+	ID:       "snp_inv",
+	Name:     "Invoice",
+	Trigger:  ":inv",
+	Defaults: map[string]any{"pad": 5},
+	Template: "Invoice #{{ counter \"inv\" .pad }} (ref {{ counter \"inv\" }})",
+}
+
+func TestEngine_ExpandCounter(t *testing.T) {
+	engine, _ := newTestEngine(t, invoiceSnippet)
+	now := time.Date(2025, 8, 28, 10, 0, 0, 0, time.UTC)
+
+	want := []string{
+		"Invoice #00001 (ref 1)",
+		"Invoice #00002 (ref 2)",
+		"Invoice #00003 (ref 3)",
+	}
+
+	for i, w := range want {
+		got, err := engine.Expand(types.TriggerInput{RawTrigger: ":inv", Now: now})
+		if err != nil {
+			t.Fatalf("Expand() #%d error = %v", i, err)
+		}
+		if got.Output != w {
+			t.Errorf("Expand() #%d = %q, want %q", i, got.Output, w)
+		}
+	}
+}
+
+func TestEngine_PreviewCounterHasNoSideEffects(t *testing.T) {
+	engine, vaultDir := newTestEngine(t, invoiceSnippet)
+	now := time.Date(2025, 8, 28, 10, 0, 0, 0, time.UTC)
+
+	if _, err := engine.Expand(types.TriggerInput{RawTrigger: ":inv", Now: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	countersPath := filepath.Join(vaultDir, "counters.json")
+	before, err := os.ReadFile(countersPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		got, err := engine.Preview(types.TriggerInput{RawTrigger: ":inv", Now: now})
+		if err != nil {
+			t.Fatalf("Preview() error = %v", err)
+		}
+		if want := "Invoice #00002 (ref 2)"; got != want {
+			t.Errorf("Preview() = %q, want %q", got, want)
+		}
+	}
+
+	after, err := os.ReadFile(countersPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(before) != string(after) {
+		t.Errorf("Preview() modified counters.json:\nbefore: %s\nafter: %s", before, after)
+	}
+
+	if counter := engine.vault.GetCounter("inv"); counter.Value != 1 {
+		t.Errorf("Preview() changed counter value to %d, want 1", counter.Value)
+	}
+
+	got, err := engine.Expand(types.TriggerInput{RawTrigger: ":inv", Now: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Invoice #00002 (ref 2)"; got.Output != want {
+		t.Errorf("Expand() after Preview = %q, want %q", got.Output, want)
+	}
+}
+
+func TestEngine_CounterPersists(t *testing.T) {
+	engine, vaultDir := newTestEngine(t, invoiceSnippet)
+	now := time.Date(2025, 8, 28, 10, 0, 0, 0, time.UTC)
+
+	if _, err := engine.Expand(types.TriggerInput{RawTrigger: ":inv", Now: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := NewEngine()
+	if err := reopened.OpenVault(vaultDir); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := reopened.Expand(types.TriggerInput{RawTrigger: ":inv", Now: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Invoice #00002 (ref 2)"; got.Output != want {
+		t.Errorf("Expand() after reopen = %q, want %q", got.Output, want)
+	}
+}
+
+func TestEngine_CounterFailedRenderDoesNotCommit(t *testing.T) {
+	broken := types.Snippet{
+		ID:       "snp_broken",
+		Name:     "Broken",
+		Trigger:  ":broken",
+		Template: "{{ counter \"inv\" }}{{ counter \"inv\" \"wide\" }}",
+	}
+	engine, _ := newTestEngine(t, broken)
+
+	if _, err := engine.Expand(types.TriggerInput{RawTrigger: ":broken"}); err == nil {
+		t.Fatal("Expand() should fail on invalid pad")
+	}
+	if counter := engine.vault.GetCounter("inv"); counter != nil {
+		t.Errorf("failed Expand() committed counter %+v", counter)
+	}
+}
+
+func TestAdvanceCounter(t *testing.T) {
+	jan := time.Date(2025, 1, 31, 23, 0, 0, 0, time.UTC)
+	feb := time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		counter *types.Counter
+		step    int
+		now     time.Time
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "new counter starts at 1",
+			now:  jan,
+			want: 1,
+		},
+		{
+			name:    "custom start and step",
+			counter: &types.Counter{Value: 100, Step: 10, Start: 100, UpdatedAt: jan},
+			now:     jan,
+			want:    110,
+		},
+		{
+			name:    "step override",
+			counter: &types.Counter{Value: 5, Step: 1, Start: 1, UpdatedAt: jan},
+			step:    3,
+			now:     jan,
+			want:    8,
+		},
+		{
+			name:    "zero step defaults to 1",
+			counter: &types.Counter{Value: 5, Start: 1},
+			now:     jan,
+			want:    6,
+		},
+		{
+			name:    "monthly reset rolls over",
+			counter: &types.Counter{Value: 42, Step: 1, Start: 1, Reset: types.CounterResetMonthly, UpdatedAt: jan},
+			now:     feb,
+			want:    1,
+		},
+		{
+			name:    "monthly reset within month",
+			counter: &types.Counter{Value: 42, Step: 1, Start: 1, Reset: types.CounterResetMonthly, UpdatedAt: feb},
+			now:     feb.Add(time.Hour),
+			want:    43,
+		},
+		{
+			name:    "daily reset rolls over",
+			counter: &types.Counter{Value: 7, Step: 1, Start: 1, Reset: types.CounterResetDaily, UpdatedAt: jan},
+			now:     feb,
+			want:    1,
+		},
+		{
+			name:    "yearly reset within year",
+			counter: &types.Counter{Value: 7, Step: 1, Start: 1, Reset: types.CounterResetYearly, UpdatedAt: jan},
+			now:     feb,
+			want:    8,
+		},
+		{
+			name:    "unknown reset period",
+			counter: &types.Counter{Value: 7, Step: 1, Start: 1, Reset: "hourly", UpdatedAt: jan},
+			now:     feb,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := advanceCounter(tt.counter, tt.step, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("advanceCounter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Value != tt.want {
+				t.Errorf("advanceCounter() value = %d, want %d", got.Value, tt.want)
+			}
+			if tt.counter != nil && tt.counter.Value == got.Value {
+				t.Error("advanceCounter() modified the input counter")
+			}
+		})
+	}
+}
+
+func TestEngine_ResetCounter(t *testing.T) {
+	engine, _ := newTestEngine(t)
+
+	for i := 0; i < 3; i++ {
+		if _, err := engine.NextCounter("ticket", types.CounterOpts{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := engine.ResetCounter("ticket"); err != nil {
+		t.Fatalf("ResetCounter() error = %v", err)
+	}
+
+	got, err := engine.NextCounter("ticket", types.CounterOpts{Pad: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "001" {
+		t.Errorf("NextCounter() after reset = %q, want %q", got, "001")
+	}
+}
+
+func TestEngine_ExpandClipboard(t *testing.T) {
+	quote := types.Snippet{
+		ID:       "snp_quote",
+		Name:     "Quote",
+		Trigger:  ":quote",
+		Template: "> {{ clipboard | trim | default \"(empty)\" }}",
+	}
+	engine, _ := newTestEngine(t, quote)
+
+	got, err := engine.Expand(types.TriggerInput{RawTrigger: ":quote"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "> (empty)"; got.Output != want {
+		t.Errorf("Expand() without provider = %q, want %q", got.Output, want)
+	}
+
+	clipboard := NewMemoryClipboard(" copied text \n")
+	engine.SetClipboardProvider(clipboard)
+
+	got, err = engine.Expand(types.TriggerInput{RawTrigger: ":quote"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "> copied text"; got.Output != want {
+		t.Errorf("Expand() = %q, want %q", got.Output, want)
+	}
+
+	clipboard.Clear()
+	preview, err := engine.Preview(types.TriggerInput{RawTrigger: ":quote"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "> (empty)"; preview != want {
+		t.Errorf("Preview() with cleared clipboard = %q, want %q", preview, want)
+	}
+}
+
+func TestEngine_ExpandCursorOffset(t *testing.T) {
+	letter := types.Snippet{
+		ID:       "snp_letter",
+		Name:     "Letter",
+		Trigger:  ":letter",
+		Defaults: map[string]any{"name": "Đức"},
+		Template: "Chào {{ .name }} 👋,\n$|$\n${1:Regards}",
+	}
+	engine, _ := newTestEngine(t, letter)
+
+	got, err := engine.Expand(types.TriggerInput{RawTrigger: ":letter"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "Chào Đức 👋,\n\nRegards"; got.Output != want {
+		t.Errorf("Expand() output = %q, want %q", got.Output, want)
+	}
+	if got.CursorOffset != 12 || got.CursorOffsetUTF16 != 13 {
+		t.Errorf("Expand() cursor = %d/%d, want 12/13", got.CursorOffset, got.CursorOffsetUTF16)
+	}
+	if len(got.TabStops) != 1 || got.TabStops[0].Offset != 13 || got.TabStops[0].Placeholder != "Regards" {
+		t.Errorf("Expand() tab stops = %+v", got.TabStops)
+	}
+}
+
+func TestEngine_TriggerPrefix(t *testing.T) {
+	sig := types.Snippet{ID: "snp_sig", Name: "Signature", Trigger: "sig", Template: "-- An"}
+	mail := types.Snippet{ID: "snp_mail", Name: "Mail", Trigger: ":mail", Template: `Hi{{ "\n" }}{{ snippet "sig" }}`}
+	engine, _ := newTestEngine(t, sig, mail)
+
+	expand := func(trigger string) (string, error) {
+		got, err := engine.Expand(types.TriggerInput{RawTrigger: trigger})
+		return got.Output, err
+	}
+
+	if got, err := expand(":mail"); err != nil || got != "Hi\n-- An" {
+		t.Fatalf("Expand(:mail) = %q, %v", got, err)
+	}
+
+	migrated, err := engine.MigrateTriggerPrefix("")
+	if err != nil || migrated != 1 {
+		t.Fatalf("MigrateTriggerPrefix() = %d, %v; want 1", migrated, err)
+	}
+
+	settings, _ := engine.GetSettings()
+	settings.Prefix = ";;"
+	if err := engine.SaveSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := expand(";;mail"); err != nil || got != "Hi\n-- An" {
+		t.Errorf("Expand(;;mail) = %q, %v", got, err)
+	}
+	if _, err := expand(":mail"); err == nil {
+		t.Error("Expand(:mail) succeeded after switching the prefix")
+	}
+	snippets, _ := engine.ListSnippets("test-group")
+	if got := engine.FullTrigger(snippets[0]); got != ";;mail" {
+		t.Errorf("FullTrigger() = %q, want %q", got, ";;mail")
+	}
+}
+
+func TestEngine_MigrateTriggerPrefixFileNames(t *testing.T) {
+	engine, vaultDir := newTestEngine(t)
+
+	// Snippet files need not be named after their IDs
+	snippetsDir := filepath.Join(vaultDir, "groups", "test-group", "snippets")
+	writeYAML(t, filepath.Join(snippetsDir, "ty.yaml"), types.Snippet{ID: "snp_ty", Name: "Thanks", Trigger: ":ty", Template: "Thank you."})
+	if _, err := engine.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, err := engine.MigrateTriggerPrefix("")
+	if err != nil || migrated != 1 {
+		t.Fatalf("MigrateTriggerPrefix() = %d, %v; want 1", migrated, err)
+	}
+	settings, _ := engine.GetSettings()
+	settings.Prefix = ";;"
+	if err := engine.SaveSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(snippetsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "ty.yaml" {
+		t.Errorf("snippet files after migration = %v, want only ty.yaml", entries)
+	}
+	if got, err := engine.Expand(types.TriggerInput{RawTrigger: ";;ty"}); err != nil || got.Output != "Thank you." {
+		t.Errorf("Expand(;;ty) after reload = %q, %v", got.Output, err)
+	}
+}
+
+func TestEngine_Candidates(t *testing.T) {
+	engine, _ := newTestEngine(t, types.Snippet{ID: "snp_sig_test", Name: "Signature", Trigger: "sig", Template: "-- Test"})
+	if err := engine.vault.UpsertGroup(&types.Group{ID: "work", Name: "Work", Order: -1, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.UpsertSnippet(types.Snippet{ID: "snp_sig_work", Name: "Signature", Trigger: "sig", Template: "-- Work", GroupID: "work"}); err != nil {
+		t.Fatal(err)
+	}
+
+	candidates, err := engine.Candidates(":sig?x=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 2 || candidates[0].ID != "snp_sig_work" || candidates[1].ID != "snp_sig_test" {
+		t.Fatalf("Candidates(:sig) = %+v, want snp_sig_work then snp_sig_test", candidates)
+	}
+
+	got, err := engine.Expand(types.TriggerInput{RawTrigger: ":sig"})
+	if err != nil || got.Output != "-- Work" {
+		t.Errorf("Expand(:sig) = %q, %v; want %q", got.Output, err, "-- Work")
+	}
+
+	got, err = engine.Expand(types.TriggerInput{RawTrigger: ":sig", SnippetID: "snp_sig_test"})
+	if err != nil || got.Output != "-- Test" {
+		t.Errorf("Expand(:sig, snp_sig_test) = %q, %v; want %q", got.Output, err, "-- Test")
+	}
+
+	if _, err := engine.Expand(types.TriggerInput{RawTrigger: ":sig", SnippetID: "snp_other"}); err == nil {
+		t.Error("Expand with a SnippetID that is not a candidate should fail")
+	}
+}
+
+func TestEngine_DisabledGroup(t *testing.T) {
+	engine, _ := newTestEngine(t, types.Snippet{ID: "snp_sig", Name: "Signature", Trigger: "sig", Template: "-- An"})
+
+	if err := engine.SetGroupEnabled("test-group", false); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := engine.Expand(types.TriggerInput{RawTrigger: ":sig"})
+	if !errors.Is(err, ErrGroupDisabled) {
+		t.Errorf("Expand(:sig) error = %v, want ErrGroupDisabled", err)
+	}
+	if _, err := engine.Preview(types.TriggerInput{RawTrigger: ":sig"}); !errors.Is(err, ErrGroupDisabled) {
+		t.Errorf("Preview(:sig) error = %v, want ErrGroupDisabled", err)
+	}
+	if _, err := engine.Expand(types.TriggerInput{RawTrigger: ":nope"}); err == nil || errors.Is(err, ErrGroupDisabled) {
+		t.Errorf("Expand(:nope) error = %v, want not found", err)
+	}
+
+	m, err := engine.NewMatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.FeedString(":sig\t"); ok {
+		t.Error("matcher should ignore triggers of disabled groups")
+	}
+
+	if err := engine.SetGroupEnabled("test-group", true); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := engine.Expand(types.TriggerInput{RawTrigger: ":sig"}); err != nil || got.Output != "-- An" {
+		t.Errorf("Expand(:sig) after enabling = %q, %v", got.Output, err)
+	}
+}
+
+func TestEngine_Reload(t *testing.T) {
+	engine, vaultDir := newTestEngine(t, types.Snippet{ID: "snp_sig", Name: "Signature", Trigger: "sig", Template: "-- An"})
+
+	edited := types.Snippet{ID: "snp_sig", Name: "Signature", Trigger: "sig", Template: "-- Binh"}
+	writeYAML(t, filepath.Join(vaultDir, "groups", "test-group", "snippets", "snp_sig.yaml"), edited)
+
+	diff, err := engine.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.ChangedSnippets) != 1 || diff.ChangedSnippets[0] != "snp_sig" {
+		t.Errorf("Reload() = %+v, want snp_sig changed", diff)
+	}
+	if got, err := engine.Expand(types.TriggerInput{RawTrigger: ":sig"}); err != nil || got.Output != "-- Binh" {
+		t.Errorf("Expand(:sig) after reload = %q, %v", got.Output, err)
+	}
+}
@@ -0,0 +1,265 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/snipq/core/pkg/parser"
+	"github.com/snipq/core/pkg/template"
+	"github.com/snipq/core/pkg/types"
+	"github.com/snipq/core/pkg/vault"
+)
+
+// MaxSnippetDepth limits how deeply snippets may include other snippets
+const MaxSnippetDepth = 8
+
+// Limits on the total work of the includes of one expansion, so snippets
+// that each include the next several times cannot multiply into millions
+// of renders
+const (
+	MaxSnippetIncludes = 256     // snippets included, counting repeats
+	MaxIncludeOutput   = 1 << 20 // bytes rendered by included snippets
+)
+
+// maxCounterAttempts bounds how often an expansion starts over after
+// another one advanced the same counter
+const maxCounterAttempts = 16
+
+// Snippet composition errors
+var (
+	ErrSnippetCycle    = errors.New("snippet include cycle")
+	ErrSnippetTooDeep  = errors.New("snippet include depth exceeded")
+	ErrIncludeNotFound = errors.New("included snippet not found")
+	ErrIncludeLimit    = errors.New("snippet include limit exceeded")
+)
+
+// IncludeLimitError is returned, matching ErrIncludeLimit, when the
+// includes of an expansion exceed MaxSnippetIncludes or MaxIncludeOutput.
+// Chain is the include stack at the point the limit was hit.
+type IncludeLimitError struct {
+	Limit string // "includes" or "output bytes"
+	Max   int
+	Chain []string
+}
+
+func (e *IncludeLimitError) Error() string {
+	return fmt.Sprintf("%s: more than %d %s (at %s)", ErrIncludeLimit, e.Max, e.Limit, strings.Join(e.Chain, " -> "))
+}
+
+// Is reports whether target is ErrIncludeLimit
+func (e *IncludeLimitError) Is(target error) bool {
+	return target == ErrIncludeLimit
+}
+
+// expansion carries the state shared by a top-level snippet render and
+// every snippet it includes: the clock, the counter session and the
+// include stack used for cycle detection.
+type expansion struct {
+	engine   *Engine
+	now      time.Time
+	counters *counterSession
+	stack    []string // IDs of the snippets being rendered, outermost first
+	used     []string // IDs of included snippets, in first-use order
+	includes int      // snippets included so far, counting repeats
+	output   int      // bytes rendered by included snippets so far
+}
+
+func (e *Engine) newExpansion(now time.Time) *expansion {
+	return &expansion{
+		engine:   e,
+		now:      now,
+		counters: newCounterSession(e.vault, now),
+	}
+}
+
+// params merges query params, snippet defaults and global defaults for a
+// snippet, checks strict params, converts fill-in field values and adds
+// the special variables
+func (x *expansion) params(snippet *types.Snippet, query map[string][]string) (map[string]any, error) {
+	globalDefaults := x.engine.getGlobalDefaults(x.engine.vault.GetSettings())
+	var checked map[string]any
+	if snippet.Strict {
+		var err error
+		if checked, err = x.engine.checkParams(snippet, query); err != nil {
+			return nil, err
+		}
+	}
+
+	params, err := parser.MergeValues(query, snippet.Defaults, globalDefaults)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range checked {
+		params[key] = value
+	}
+
+	if err := applyFields(snippet, params, x.now); err != nil {
+		return nil, err
+	}
+
+	params["now"] = x.now
+	params["timestamp"] = x.now.Unix()
+
+	return params, nil
+}
+
+// renderCommitted merges params for a snippet, renders it and persists
+// the counters it advanced. When another expansion advanced one of those
+// counters first, it renders again with fresh values, so concurrent
+// expansions never hand out the same counter value. A retry runs alone
+// in this process, so only another process sharing the vault can make it
+// start over again.
+func (e *Engine) renderCommitted(snippet *types.Snippet, query map[string][]string, now time.Time) (*expansion, map[string]any, *template.Result, error) {
+	for attempt := 1; ; attempt++ {
+		x, params, result, err := e.renderAttempt(snippet, query, now, attempt > 1)
+		if errors.Is(err, vault.ErrCounterConflict) && attempt < maxCounterAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return x, params, result, nil
+	}
+}
+
+// renderAttempt renders a snippet once and commits its counters
+func (e *Engine) renderAttempt(snippet *types.Snippet, query map[string][]string, now time.Time, retry bool) (*expansion, map[string]any, *template.Result, error) {
+	defer e.lockCounters(retry)()
+
+	x := e.newExpansion(now)
+	params, err := x.params(snippet, query)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Render the template, collecting counter increments
+	result, err := x.render(snippet, params)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to render template: %w", err)
+	}
+
+	// Persist counters only once the whole template rendered
+	if err := x.counters.commit(); err != nil {
+		return nil, nil, nil, err
+	}
+	return x, params, result, nil
+}
+
+// lockCounters holds e.counterMu while counters are read and committed:
+// shared for a first attempt, exclusively for a retry after a conflict.
+// It returns the function releasing it.
+func (e *Engine) lockCounters(retry bool) (unlock func()) {
+	if retry {
+		e.counterMu.Lock()
+		return e.counterMu.Unlock
+	}
+	e.counterMu.RLock()
+	return e.counterMu.RUnlock
+}
+
+// render renders the top-level snippet of the expansion
+func (x *expansion) render(snippet *types.Snippet, params map[string]any) (*template.Result, error) {
+	x.stack = append(x.stack[:0], snippet.ID)
+	return x.engine.template.RenderResult(snippet.Template, params, x.context(params))
+}
+
+// IncludeSnippet renders a snippet referenced from another snippet's
+// template. ref is a trigger or snippet ID and may carry its own query.
+func (x *expansion) IncludeSnippet(ref string, args []string) (string, error) {
+	raw := ref
+	if len(args) > 0 {
+		separator := "?"
+		if strings.Contains(ref, "?") {
+			separator = "&"
+		}
+		raw += separator + strings.Join(args, "&")
+	}
+
+	parsed, err := parser.ParseTrigger(raw)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse snippet reference %q: %w", raw, err)
+	}
+
+	snippet, positional, err := resolveTrigger(parsed, x.engine.findSnippet)
+	if err != nil {
+		return "", err
+	}
+	match := &triggerMatch{snippet: snippet, args: positional}
+	if snippet == nil {
+		if match, err = x.engine.matchTrigger(parsed, "", types.ListOptions{}); err != nil {
+			return "", err
+		}
+		if match == nil {
+			return "", fmt.Errorf("%w: %s", ErrIncludeNotFound, parsed.Trigger)
+		}
+		snippet = match.snippet
+	}
+
+	query, err := match.query(parsed.Values)
+	if err != nil {
+		return "", err
+	}
+
+	for _, id := range x.stack {
+		if id == snippet.ID {
+			return "", fmt.Errorf("%w: %s -> %s", ErrSnippetCycle, strings.Join(x.stack, " -> "), snippet.ID)
+		}
+	}
+	if len(x.stack) > MaxSnippetDepth {
+		return "", fmt.Errorf("%w: %s (max %d)", ErrSnippetTooDeep, strings.Join(x.stack, " -> "), MaxSnippetDepth)
+	}
+
+	x.stack = append(x.stack, snippet.ID)
+	defer func() { x.stack = x.stack[:len(x.stack)-1] }()
+	x.markUsed(snippet.ID)
+
+	x.includes++
+	if x.includes > MaxSnippetIncludes {
+		return "", x.limitError("includes", MaxSnippetIncludes)
+	}
+
+	params, err := x.params(snippet, query)
+	if err != nil {
+		return "", err
+	}
+	out, err := x.engine.template.RenderMarked(snippet.Template, params, x.context(params))
+	if err != nil {
+		return "", err
+	}
+
+	x.output += len(out)
+	if x.output > MaxIncludeOutput {
+		return "", x.limitError("output bytes", MaxIncludeOutput)
+	}
+	return out, nil
+}
+
+func (x *expansion) limitError(limit string, max int) error {
+	return &IncludeLimitError{Limit: limit, Max: max, Chain: append([]string(nil), x.stack...)}
+}
+
+func (x *expansion) markUsed(id string) {
+	for _, used := range x.used {
+		if used == id {
+			return
+		}
+	}
+	x.used = append(x.used, id)
+}
+
+// context builds the per-render template context for a snippet's params
+func (x *expansion) context(params map[string]any) template.Context {
+	style, _ := params["fmtStyle"].(string)
+	locale, _ := params["locale"].(string)
+
+	return template.Context{
+		Now:       x.now,
+		DateStyle: style,
+		Locale:    locale,
+		Counters:  x.counters,
+		Clipboard: x.engine.clipboardProvider(),
+		Snippets:  x,
+	}
+}
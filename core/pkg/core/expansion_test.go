@@ -0,0 +1,283 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snipq/core/pkg/types"
+)
+
+func TestEngine_ExpandIncludesSnippets(t *testing.T) {
+	sig := types.Snippet{
+		ID:       "snp_sig",
+		Name:     "Signature",
+		Trigger:  ":sig",
+		Defaults: map[string]any{"lang": "en"},
+		Template: "{{ if eq .lang \"vi\" }}Trân trọng{{ else }}Best regards{{ end }},\nAn",
+	}
+	addr := types.Snippet{
+		ID:       "snp_addr",
+		Name:     "Address",
+		Trigger:  ":addr",
+		Template: "1 Trang Tien, Hanoi",
+	}
+	mail := types.Snippet{
+		ID:       "snp_mail",
+		Name:     "Mail",
+		Trigger:  ":mail",
+		Defaults: map[string]any{"lang": "en"},
+		Template: "Hi,\n$|$\n\n{{ snippet \":sig\" (printf \"lang=%s\" .lang) }}\n{{ snippet \"snp_addr\" }}",
+	}
+	engine, _ := newTestEngine(t, sig, addr, mail)
+
+	tests := []struct {
+		name    string
+		trigger string
+		want    string
+	}{
+		{
+			name:    "included snippets use their own defaults",
+			trigger: ":mail",
+			want:    "Hi,\n\n\nBest regards,\nAn\n1 Trang Tien, Hanoi",
+		},
+		{
+			name:    "params passed to included snippet",
+			trigger: ":mail?lang=vi",
+			want:    "Hi,\n\n\nTrân trọng,\nAn\n1 Trang Tien, Hanoi",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.Expand(types.TriggerInput{RawTrigger: tt.trigger})
+			if err != nil {
+				t.Fatalf("Expand() error = %v", err)
+			}
+			if got.Output != tt.want {
+				t.Errorf("Expand() = %q, want %q", got.Output, tt.want)
+			}
+			if got.UsedSnippet != "snp_mail" {
+				t.Errorf("Expand() used snippet = %q, want snp_mail", got.UsedSnippet)
+			}
+			if want := []string{"snp_sig", "snp_addr"}; !reflect.DeepEqual(got.NestedSnippets, want) {
+				t.Errorf("Expand() nested snippets = %v, want %v", got.NestedSnippets, want)
+			}
+			if got.CursorOffset != 4 {
+				t.Errorf("Expand() cursor = %d, want 4", got.CursorOffset)
+			}
+		})
+	}
+}
+
+func TestEngine_ExpandIncludeInlineQuery(t *testing.T) {
+	greet := types.Snippet{
+		ID:       "snp_greet",
+		Name:     "Greet",
+		Trigger:  ":greet",
+		Defaults: map[string]any{"name": "there", "punct": "."},
+		Template: "Hello {{ .name }}{{ .punct }}",
+	}
+	outer := types.Snippet{
+		ID:       "snp_outer",
+		Name:     "Outer",
+		Trigger:  ":outer",
+		Defaults: map[string]any{"name": "outer"},
+		Template: "{{ snippet \":greet?name=Mai\" \"punct=!\" }} / {{ snippet \":greet\" }}",
+	}
+	engine, _ := newTestEngine(t, greet, outer)
+
+	got, err := engine.Expand(types.TriggerInput{RawTrigger: ":outer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Hello Mai! / Hello there."; got.Output != want {
+		t.Errorf("Expand() = %q, want %q", got.Output, want)
+	}
+	if want := []string{"snp_greet"}; !reflect.DeepEqual(got.NestedSnippets, want) {
+		t.Errorf("Expand() nested snippets = %v, want %v", got.NestedSnippets, want)
+	}
+}
+
+func TestEngine_ExpandIncludeSharesCounters(t *testing.T) {
+	number := types.Snippet{
+		ID:       "snp_num",
+		Name:     "Number",
+		Trigger:  ":num",
+		Template: "{{ counter \"inv\" 4 }}",
+	}
+	invoice := types.Snippet{
+		ID:       "snp_invoice",
+		Name:     "Invoice",
+		Trigger:  ":invoice",
+		Template: "INV-{{ snippet \":num\" }} (#{{ counter \"inv\" }})",
+	}
+	engine, _ := newTestEngine(t, number, invoice)
+
+	preview, err := engine.Preview(types.TriggerInput{RawTrigger: ":invoice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "INV-0001 (#1)"; preview != want {
+		t.Errorf("Preview() = %q, want %q", preview, want)
+	}
+
+	for _, want := range []string{"INV-0001 (#1)", "INV-0002 (#2)"} {
+		got, err := engine.Expand(types.TriggerInput{RawTrigger: ":invoice"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Output != want {
+			t.Errorf("Expand() = %q, want %q", got.Output, want)
+		}
+	}
+}
+
+func TestEngine_ExpandIncludeErrors(t *testing.T) {
+	snippets := []types.Snippet{
+		{ID: "snp_self", Name: "Self", Trigger: ":self", Template: "x{{ snippet \":self\" }}"},
+		{ID: "snp_a", Name: "A", Trigger: ":a", Template: "a{{ snippet \":b\" }}"},
+		{ID: "snp_b", Name: "B", Trigger: ":b", Template: "b{{ snippet \"snp_a\" }}"},
+		{ID: "snp_missing", Name: "Missing", Trigger: ":missing", Template: "{{ snippet \":nope\" }}"},
+	}
+
+	// A chain one level deeper than MaxSnippetDepth, ending in a plain snippet
+	last := MaxSnippetDepth + 1
+	for i := 0; i <= last; i++ {
+		tmpl := fmt.Sprintf("%d{{ snippet \":deep%d\" }}", i, i+1)
+		if i == last {
+			tmpl = fmt.Sprintf("%d", i)
+		}
+		snippets = append(snippets, types.Snippet{
+			ID:       fmt.Sprintf("snp_deep%d", i),
+			Name:     fmt.Sprintf("Deep %d", i),
+			Trigger:  fmt.Sprintf(":deep%d", i),
+			Template: tmpl,
+		})
+	}
+	engine, _ := newTestEngine(t, snippets...)
+
+	tests := []struct {
+		name    string
+		trigger string
+		wantErr error
+		wantMsg string
+	}{
+		{
+			name:    "self include",
+			trigger: ":self",
+			wantErr: ErrSnippetCycle,
+			wantMsg: "snp_self -> snp_self",
+		},
+		{
+			name:    "indirect cycle",
+			trigger: ":a",
+			wantErr: ErrSnippetCycle,
+			wantMsg: "snp_a -> snp_b -> snp_a",
+		},
+		{
+			name:    "missing include",
+			trigger: ":missing",
+			wantErr: ErrIncludeNotFound,
+			wantMsg: ":nope",
+		},
+		{
+			name:    "too deep",
+			trigger: ":deep0",
+			wantErr: ErrSnippetTooDeep,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := engine.Expand(types.TriggerInput{RawTrigger: tt.trigger})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expand() error = %v, want %v", err, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("Expand() error = %q, should contain %q", err, tt.wantMsg)
+			}
+
+			if _, err := engine.Preview(types.TriggerInput{RawTrigger: tt.trigger}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Preview() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Exactly MaxSnippetDepth levels of nesting are allowed
+	got, err := engine.Expand(types.TriggerInput{RawTrigger: ":deep1"})
+	if err != nil {
+		t.Fatalf("Expand() at max depth error = %v", err)
+	}
+	if want := "123456789"; got.Output != want {
+		t.Errorf("Expand() at max depth = %q, want %q", got.Output, want)
+	}
+	if len(got.NestedSnippets) != MaxSnippetDepth {
+		t.Errorf("Expand() nested %d snippets, want %d", len(got.NestedSnippets), MaxSnippetDepth)
+	}
+}
+
+func TestEngine_ExpandIncludeLimits(t *testing.T) {
+	// Seven levels that each include the next ten times would render a
+	// million snippets
+	var snippets []types.Snippet
+	for i := 0; i < 7; i++ {
+		tmpl := "x"
+		if i < 6 {
+			tmpl = strings.Repeat(fmt.Sprintf("{{ snippet \":fan%d\" }}", i+1), 10)
+		}
+		snippets = append(snippets, types.Snippet{
+			ID:       fmt.Sprintf("snp_fan%d", i),
+			Name:     fmt.Sprintf("Fan %d", i),
+			Trigger:  fmt.Sprintf(":fan%d", i),
+			Template: tmpl,
+		})
+	}
+
+	// A few includes of a large snippet
+	snippets = append(snippets,
+		types.Snippet{ID: "snp_big", Name: "Big", Trigger: ":big", Template: strings.Repeat("y", MaxIncludeOutput/3)},
+		types.Snippet{ID: "snp_bigs", Name: "Bigs", Trigger: ":bigs", Template: strings.Repeat(`{{ snippet ":big" }}`, 4)},
+	)
+	engine, _ := newTestEngine(t, snippets...)
+
+	tests := []struct {
+		trigger string
+		limit   string
+	}{
+		{trigger: ":fan0", limit: "includes"},
+		{trigger: ":bigs", limit: "output bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.trigger, func(t *testing.T) {
+			done := make(chan error, 1)
+			go func() {
+				_, err := engine.Preview(types.TriggerInput{RawTrigger: tt.trigger})
+				done <- err
+			}()
+
+			select {
+			case err := <-done:
+				var limitErr *IncludeLimitError
+				if !errors.Is(err, ErrIncludeLimit) || !errors.As(err, &limitErr) {
+					t.Fatalf("Preview() error = %v, want an IncludeLimitError", err)
+				}
+				if limitErr.Limit != tt.limit {
+					t.Errorf("IncludeLimitError.Limit = %q, want %q", limitErr.Limit, tt.limit)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Preview() did not return")
+			}
+		})
+	}
+
+	// A fan-out within the limits still expands
+	got, err := engine.Expand(types.TriggerInput{RawTrigger: ":fan4"})
+	if err != nil || got.Output != strings.Repeat("x", 100) {
+		t.Errorf("Expand(:fan4) = %q, %v", got.Output, err)
+	}
+}
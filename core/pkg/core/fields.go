@@ -0,0 +1,146 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/snipq/core/pkg/parser"
+	"github.com/snipq/core/pkg/types"
+)
+
+// Fill-in field errors
+var (
+	ErrInputRequired     = errors.New("input required")
+	ErrInvalidFieldValue = errors.New("invalid field value")
+)
+
+// InputRequiredError is returned by Expand and Preview when a snippet
+// declares fill-in fields that the trigger's query does not supply.
+// Hosts show a form for Fields and expand again with the values appended
+// to the trigger as query params (see parser.AppendParams).
+type InputRequiredError struct {
+	SnippetID string
+	Fields    []types.Field
+}
+
+func (e *InputRequiredError) Error() string {
+	names := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		names[i] = field.Name
+	}
+	return fmt.Sprintf("%s: snippet %s needs %s", ErrInputRequired, e.SnippetID, strings.Join(names, ", "))
+}
+
+// Is reports whether target is ErrInputRequired
+func (e *InputRequiredError) Is(target error) bool {
+	return target == ErrInputRequired
+}
+
+// missingFields returns an error listing the fields not supplied by
+// query. Optional fields with a default are not asked for; they take it.
+func missingFields(snippet *types.Snippet, query map[string][]string) error {
+	supplied := make(map[string]bool, len(query))
+	for key := range query {
+		supplied[parser.ParamRoot(key)] = true
+	}
+
+	var missing []types.Field
+	for _, field := range snippet.Fields {
+		if !supplied[field.Name] && (field.Required || field.Default == nil) {
+			missing = append(missing, field)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+	return &InputRequiredError{SnippetID: snippet.ID, Fields: missing}
+}
+
+// applyFields converts field values in params to their declared types.
+// Fields without a value, or with an empty one, take their default, so
+// included snippets render without asking for input.
+func applyFields(snippet *types.Snippet, params map[string]any, now time.Time) error {
+	for _, field := range snippet.Fields {
+		value, ok := params[field.Name]
+		if !ok || value == nil || value == "" {
+			value = field.Default
+		}
+
+		converted, err := convertField(field, value, now)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidFieldValue, field.Name, err)
+		}
+		params[field.Name] = converted
+	}
+	return nil
+}
+
+// ValidateField checks a value entered for a fill-in field the way Expand
+// does once it is added to the query, so hosts can ask again before
+// expanding. An empty value stands for the field's default.
+func ValidateField(field types.Field, value string) error {
+	var raw any = value
+	if value == "" {
+		raw = field.Default
+	}
+	if _, err := convertField(field, raw, time.Now()); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidFieldValue, field.Name, err)
+	}
+	return nil
+}
+
+// convertField converts a raw value to the field's type
+func convertField(field types.Field, value any, now time.Time) (any, error) {
+	text := ""
+	if value != nil {
+		text = fmt.Sprint(value)
+	}
+
+	if field.Required && strings.TrimSpace(text) == "" {
+		return nil, errors.New("value is required")
+	}
+
+	switch field.Type {
+	case types.FieldCheckbox:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case nil:
+			return false, nil
+		}
+		if strings.TrimSpace(text) == "" {
+			return false, nil
+		}
+		if b, ok := parser.ParseBool(text); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("%q is not a checkbox value", text)
+
+	case types.FieldChoice:
+		if (text == "" && !field.Required) || containsString(field.Options, text) {
+			return text, nil
+		}
+		return nil, fmt.Errorf("%q is not one of %s", text, strings.Join(field.Options, ", "))
+
+	case types.FieldDate:
+		switch v := value.(type) {
+		case time.Time:
+			return v, nil
+		case nil:
+			return time.Time{}, nil
+		}
+		if strings.TrimSpace(text) == "" {
+			return time.Time{}, nil
+		}
+		date, err := time.ParseInLocation(types.FieldDateLayout, strings.TrimSpace(text), now.Location())
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a %s date", text, types.FieldDateLayout)
+		}
+		return date, nil
+	}
+
+	return text, nil
+}
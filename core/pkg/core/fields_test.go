@@ -0,0 +1,188 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/snipq/core/pkg/types"
+)
+
+func fieldsSnippet() types.Snippet {
+	return types.Snippet{
+		ID:      "snp_mail",
+		Name:    "Mail",
+		Trigger: ":mail",
+		Fields: []types.Field{
+			{Name: "to", Label: "Recipient", Required: true},
+			{Name: "tone", Type: types.FieldChoice, Options: []string{"formal", "casual"}, Default: "formal"},
+			{Name: "urgent", Type: types.FieldCheckbox},
+			{Name: "due", Type: types.FieldDate},
+		},
+		Template: `{{ if eq .tone "casual" }}Hi{{ else }}Dear{{ end }} {{ .to }}` +
+			`{{ if .urgent }} (urgent){{ end }}{{ if not .due.IsZero }} by {{ formatDate "Jan 2" .due }}{{ end }}`,
+	}
+}
+
+func TestEngine_ExpandNeedsInput(t *testing.T) {
+	engine, _ := newTestEngine(t, fieldsSnippet())
+
+	tests := []struct {
+		name    string
+		trigger string
+		missing []string
+	}{
+		{"no values", ":mail", []string{"to", "urgent", "due"}},
+		{"some values", ":mail?to=An&tone=casual", []string{"urgent", "due"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := engine.Expand(types.TriggerInput{RawTrigger: tt.trigger})
+			if !errors.Is(err, ErrInputRequired) {
+				t.Fatalf("Expand() error = %v, want ErrInputRequired", err)
+			}
+
+			var inputErr *InputRequiredError
+			if !errors.As(err, &inputErr) {
+				t.Fatalf("Expand() error %T is not *InputRequiredError", err)
+			}
+			if inputErr.SnippetID != "snp_mail" {
+				t.Errorf("SnippetID = %q, want snp_mail", inputErr.SnippetID)
+			}
+
+			var names []string
+			for _, field := range inputErr.Fields {
+				names = append(names, field.Name)
+			}
+			if !reflect.DeepEqual(names, tt.missing) {
+				t.Errorf("missing fields = %v, want %v", names, tt.missing)
+			}
+
+			if _, err := engine.Preview(types.TriggerInput{RawTrigger: tt.trigger}); !errors.Is(err, ErrInputRequired) {
+				t.Errorf("Preview() error = %v, want ErrInputRequired", err)
+			}
+		})
+	}
+}
+
+func TestEngine_ExpandFieldValues(t *testing.T) {
+	engine, _ := newTestEngine(t, fieldsSnippet())
+
+	tests := []struct {
+		name    string
+		trigger string
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "converted values",
+			trigger: ":mail?to=An&tone=casual&urgent=yes&due=2025-09-01",
+			want:    "Hi An (urgent) by Sep 1",
+		},
+		{
+			name:    "optional field with a default is not asked for",
+			trigger: ":mail?to=An&urgent=no&due=",
+			want:    "Dear An",
+		},
+		{
+			name:    "empty values use defaults",
+			trigger: ":mail?to=An&tone=&urgent=&due=",
+			want:    "Dear An",
+		},
+		{
+			name:    "required field left empty",
+			trigger: ":mail?to=&tone=formal&urgent=no&due=",
+			wantErr: ErrInvalidFieldValue,
+		},
+		{
+			name:    "unknown choice",
+			trigger: ":mail?to=An&tone=rude&urgent=no&due=",
+			wantErr: ErrInvalidFieldValue,
+		},
+		{
+			name:    "bad date",
+			trigger: ":mail?to=An&tone=formal&urgent=no&due=01/09/2025",
+			wantErr: ErrInvalidFieldValue,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.Expand(types.TriggerInput{RawTrigger: tt.trigger, Now: time.Date(2025, 8, 28, 9, 0, 0, 0, time.UTC)})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expand() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expand() error = %v", err)
+			}
+			if got.Output != tt.want {
+				t.Errorf("Expand() = %q, want %q", got.Output, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_IncludedSnippetFieldsUseDefaults(t *testing.T) {
+	sig := types.Snippet{
+		ID:       "snp_sig",
+		Name:     "Signature",
+		Trigger:  ":sig",
+		Fields:   []types.Field{{Name: "name", Default: "An"}},
+		Template: "-- {{ .name }}",
+	}
+	note := types.Snippet{
+		ID:       "snp_note",
+		Name:     "Note",
+		Trigger:  ":note",
+		Template: `Thanks{{ "\n" }}{{ snippet ":sig" }}`,
+	}
+	engine, _ := newTestEngine(t, sig, note)
+
+	got, err := engine.Expand(types.TriggerInput{RawTrigger: ":note"})
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+	if want := "Thanks\n-- An"; got.Output != want {
+		t.Errorf("Expand() = %q, want %q", got.Output, want)
+	}
+}
+
+func TestValidateField(t *testing.T) {
+	fields := fieldsSnippet().Fields
+	to, tone, urgent, due := fields[0], fields[1], fields[2], fields[3]
+
+	tests := []struct {
+		name    string
+		field   types.Field
+		value   string
+		wantErr bool
+	}{
+		{"text", to, "An", false},
+		{"required text left empty", to, "", true},
+		{"choice", tone, "casual", false},
+		{"choice left empty takes its default", tone, "", false},
+		{"unknown choice", tone, "3", true},
+		{"checkbox", urgent, "y", false},
+		{"checkbox left empty", urgent, "", false},
+		{"checkbox answer that is not yes or no", urgent, "maybe", true},
+		{"date", due, "2025-09-01", false},
+		{"malformed date", due, "01/09/2025", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateField(tt.field, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateField(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidFieldValue) {
+				t.Errorf("ValidateField(%q) error = %v, want ErrInvalidFieldValue", tt.value, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package core
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	_ "time/tzdata" // deterministic zone data for golden dates
+
+	"github.com/snipq/core/pkg/types"
+)
+
+// openTestdataVault opens a private copy of internal/testdata/vault so
+// expansions can write counters and history without touching the fixtures
+func openTestdataVault(t *testing.T) *Engine {
+	t.Helper()
+
+	src := filepath.Join("..", "..", "internal", "testdata", "vault")
+	dst := t.TempDir()
+
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0600)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := NewEngine()
+	if err := engine.OpenVault(dst); err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestEngine_GoldenTestdataVault(t *testing.T) {
+	// 23:30 UTC is already the next day in Asia and still the same day in the Americas
+	instant := time.Date(2025, 8, 28, 23, 30, 0, 0, time.UTC)
+	saigon := mustLoadLocation(t, "Asia/Ho_Chi_Minh")
+	tokyo := mustLoadLocation(t, "Asia/Tokyo")
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	tests := []struct {
+		name    string
+		trigger string
+		now     time.Time
+		want    string
+	}{
+		{
+			name:    "date in UTC",
+			trigger: ":date",
+			now:     instant,
+			want:    "2025-08-28",
+		},
+		{
+			name:    "date with local Now in Saigon",
+			trigger: ":date",
+			now:     instant.In(saigon),
+			want:    "2025-08-29",
+		},
+		{
+			name:    "date with tz param overrides Now location",
+			trigger: ":date?tz=America/New_York",
+			now:     instant.In(tokyo),
+			want:    "2025-08-28",
+		},
+		{
+			name:    "date with format and tz",
+			trigger: ":date?format=Mon, 02 Jan 2006 15:04 MST&tz=Asia/Tokyo",
+			now:     instant.In(newYork),
+			want:    "Fri, 29 Aug 2025 08:30 JST",
+		},
+		{
+			name:    "date in UTC from local Now",
+			trigger: ":date?tz=UTC&format=2006-01-02T15:04:05Z07:00",
+			now:     instant.In(saigon),
+			want:    "2025-08-28T23:30:00Z",
+		},
+		{
+			name:    "unknown tz falls back to Now location",
+			trigger: ":date?tz=Mars/Olympus&format=2006-01-02 15:04",
+			now:     instant.In(tokyo),
+			want:    "2025-08-29 08:30",
+		},
+		{
+			name:    "date with moment tokens",
+			trigger: ":date?format=YYYY-MM-DD",
+			now:     instant.In(saigon),
+			want:    "2025-08-29",
+		},
+		{
+			name:    "date with strftime tokens",
+			trigger: ":date?format=%25d/%25m/%25Y %25H:%25M&tz=UTC",
+			now:     instant,
+			want:    "28/08/2025 23:30",
+		},
+		{
+			name:    "date with ICU tokens",
+			trigger: ":date?format=EEEE, d MMMM yyyy&tz=Asia/Tokyo",
+			now:     instant,
+			want:    "Friday, 29 August 2025",
+		},
+		{
+			name:    "date with explicit fmtStyle",
+			trigger: ":date?format=dd/MM&fmtStyle=icu&tz=UTC",
+			now:     instant,
+			want:    "28/08",
+		},
+		{
+			name:    "date with Vietnamese locale",
+			trigger: ":date?format=dddd, D MMMM YYYY&locale=vi-VN&tz=UTC",
+			now:     instant,
+			want:    "Thứ Năm, 28 tháng 8 2025",
+		},
+		{
+			name:    "date with German locale and Go layout",
+			trigger: ":date?format=Monday, 2. January 2006&locale=de-DE&tz=Europe/Berlin",
+			now:     instant,
+			want:    "Freitag, 29. August 2025",
+		},
+		{
+			name:    "date with Japanese locale",
+			trigger: ":date?format=yyyy年M月d日(E)&locale=ja&tz=Asia/Tokyo",
+			now:     instant,
+			want:    "2025年8月29日(金)",
+		},
+		{
+			name:    "invoice combines counter and local date",
+			trigger: ":inv",
+			now:     instant.In(newYork),
+			want:    "Invoice #00001 — 2025-08-28",
+		},
+		{
+			name:    "due in default business days",
+			trigger: ":due",
+			now:     instant.In(saigon),
+			want:    "Due Wed, 03 Sep 2025",
+		},
+		{
+			name:    "due with days override",
+			trigger: ":due?days=+5",
+			now:     instant.In(saigon),
+			want:    "Due Fri, 05 Sep 2025",
+		},
+		{
+			name:    "due across timezone boundary",
+			trigger: ":due?days=1&tz=America/New_York",
+			now:     instant.In(saigon),
+			want:    "Due Fri, 29 Aug 2025",
+		},
+		{
+			name:    "thanks default",
+			trigger: ":ty",
+			now:     instant,
+			want:    "Thank you.\n",
+		},
+		{
+			name:    "thanks vi casual",
+			trigger: ":ty?lang=vi&tone=casual",
+			now:     instant,
+			want:    "Cảm ơn bạn nha!\n\n",
+		},
+		{
+			name:    "thanks shorthand",
+			trigger: ":ty/vi/casual",
+			now:     instant,
+			want:    "Cảm ơn bạn nha!\n\n",
+		},
+		{
+			name:    "thanks paren shorthand",
+			trigger: ":ty(ja)",
+			now:     instant,
+			want:    "ありがとうございます。\n\n",
+		},
+		{
+			name:    "thanks aliases",
+			trigger: ":ty?l=vi",
+			now:     instant,
+			want:    "Cảm ơn bạn.\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := openTestdataVault(t)
+			input := types.TriggerInput{RawTrigger: tt.trigger, Now: tt.now}
+
+			preview, err := engine.Preview(input)
+			if err != nil {
+				t.Fatalf("Preview() error = %v", err)
+			}
+			if preview != tt.want {
+				t.Errorf("Preview() = %q, want %q", preview, tt.want)
+			}
+
+			got, err := engine.Expand(input)
+			if err != nil {
+				t.Fatalf("Expand() error = %v", err)
+			}
+			if got.Output != tt.want {
+				t.Errorf("Expand() = %q, want %q", got.Output, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,397 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/snipq/core/pkg/types"
+	"github.com/snipq/core/pkg/vault"
+)
+
+// maxQueryTail bounds how many runes of ?query tail the matcher keeps
+const maxQueryTail = 256
+
+// expandKeys maps Settings.ExpandKey names to the rune hosts feed for them
+var expandKeys = map[string]rune{
+	"tab":    '\t',
+	"space":  ' ',
+	"enter":  '\n',
+	"return": '\n',
+}
+
+// Match is emitted by a Matcher when a trigger was typed.
+// Delete counts the typed trigger and query tail, in runes and in UTF-16
+// code units, that the host removes before inserting the expansion. The
+// expand key is not included: hosts swallow it rather than type it.
+type Match struct {
+	Trigger     string `json:"trigger"`     // typed trigger, e.g. ":inv"
+	RawTrigger  string `json:"rawTrigger"`  // trigger and query tail, for TriggerInput.RawTrigger
+	Delete      int    `json:"delete"`      // runes to delete
+	DeleteUTF16 int    `json:"deleteUtf16"` // UTF-16 code units to delete
+}
+
+// Matcher detects triggers at the end of a stream of typed characters so
+// host apps (keyboard hooks, content scripts, IMEs) share one
+// implementation. It keeps a rolling buffer of recent input and looks
+// triggers up in a trie, so matching cost depends on trigger length rather
+// than vault size.
+//
+// With an expand key ("Tab", "Space", "Enter" or a single character) a
+// trigger matches when the key is fed, and may be followed by a ?query
+// tail without whitespace (:inv?pad=3). An empty or "none" expand key
+// matches a trigger as soon as its last character is typed. With strict
+// boundaries a trigger must start the buffer or follow a character that
+// is not a letter or digit.
+//
+// Folded triggers (see SetFoldedTriggers) also match when typed with
+// capitals. Regex triggers (see SetPatterns) are tried after literal ones
+// and only match when an expand key is set.
+//
+// A Matcher is not safe for concurrent use.
+type Matcher struct {
+	trie      *triggerTrie
+	folded    *triggerTrie // lower-case triggers matched regardless of case
+	longest   [2]int       // rune length of the longest trigger in trie and folded
+	patterns  []*regexp.Regexp
+	expandKey rune // 0 expands immediately
+	strict    bool
+	buffer    []rune
+	limit     int
+}
+
+// NewMatcher creates a matcher for full typed triggers (prefix included)
+// using the expand key and boundary rules of settings
+func NewMatcher(triggers []string, settings types.Settings) (*Matcher, error) {
+	key, err := parseExpandKey(settings.ExpandKey)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Matcher{
+		trie:      newTriggerTrie(),
+		folded:    newTriggerTrie(),
+		expandKey: key,
+		strict:    settings.StrictBoundaries,
+	}
+	m.SetTriggers(triggers)
+	return m, nil
+}
+
+// NewMatcher creates a matcher over the literal and regex triggers of
+// every snippet in an enabled group
+func (e *Engine) NewMatcher() (*Matcher, error) {
+	m, err := NewMatcher(e.Triggers(), *e.vault.GetSettings())
+	if err != nil {
+		return nil, err
+	}
+	m.SetFoldedTriggers(e.FoldedTriggers())
+	m.SetPatterns(e.TriggerPatterns())
+	return m, nil
+}
+
+// Triggers returns the full typed trigger of every snippet in an enabled
+// group that has a literal trigger
+func (e *Engine) Triggers() []string {
+	return e.triggers(false)
+}
+
+// FoldedTriggers returns the lower-case full typed triggers of the
+// case-propagating snippets in enabled groups, which also expand when
+// typed with capitals
+func (e *Engine) FoldedTriggers() []string {
+	return e.triggers(true)
+}
+
+func (e *Engine) triggers(folded bool) []string {
+	snippets := e.vault.ListAllSnippets(types.ListOptions{})
+	triggers := make([]string, 0, len(snippets))
+	for _, snippet := range snippets {
+		if snippet.Trigger == "" {
+			continue
+		}
+		if !folded {
+			triggers = append(triggers, e.FullTrigger(*snippet))
+		} else if e.propagatesCase(snippet) {
+			triggers = append(triggers, strings.ToLower(e.FullTrigger(*snippet)))
+		}
+	}
+	return triggers
+}
+
+// TriggerPatterns returns the regex triggers of snippets in enabled
+// groups, compiled to match a whole typed trigger, prefix included
+func (e *Engine) TriggerPatterns() []*regexp.Regexp {
+	prefix := regexp.QuoteMeta(e.vault.GetSettings().Prefix)
+	var patterns []*regexp.Regexp
+	for _, snippet := range e.vault.ListAllSnippets(types.ListOptions{}) {
+		if snippet.Regex == "" {
+			continue
+		}
+		if re, err := vault.CompileRegexTrigger(prefix + "(?:" + snippet.Regex + ")"); err == nil {
+			patterns = append(patterns, re)
+		}
+	}
+	return patterns
+}
+
+// SetTriggers replaces the triggers the matcher recognises, e.g. after
+// the vault changed, and clears the buffer
+func (m *Matcher) SetTriggers(triggers []string) {
+	m.trie, m.longest[0] = buildTrie(triggers, false)
+	m.resize()
+}
+
+// SetFoldedTriggers replaces the triggers matched regardless of case,
+// used for case-propagating snippets, and clears the buffer
+func (m *Matcher) SetFoldedTriggers(triggers []string) {
+	m.folded, m.longest[1] = buildTrie(triggers, true)
+	m.resize()
+}
+
+// resize sets the buffer limit from the longest trigger and clears the
+// buffer
+func (m *Matcher) resize() {
+	// One extra rune keeps the character before the longest trigger
+	// around for the boundary check
+	m.limit = max(m.longest[0], m.longest[1]) + 1
+	if m.expandKey != 0 {
+		m.limit += maxQueryTail
+	}
+	m.Reset()
+}
+
+// buildTrie builds a trie of triggers, lower-cased when folded, and
+// returns it with the rune length of the longest trigger
+func buildTrie(triggers []string, folded bool) (*triggerTrie, int) {
+	trie := newTriggerTrie()
+	longest := 0
+	for _, trigger := range triggers {
+		if trigger == "" || strings.ContainsAny(trigger, "? \t\r\n") {
+			continue
+		}
+		if folded {
+			trigger = strings.ToLower(trigger)
+		}
+		trie.insert(trigger)
+		if n := utf8.RuneCountInString(trigger); n > longest {
+			longest = n
+		}
+	}
+	return trie, longest
+}
+
+// SetPatterns sets the regex triggers the matcher recognises. Each must
+// match a whole typed trigger, prefix included.
+func (m *Matcher) SetPatterns(patterns []*regexp.Regexp) {
+	m.patterns = patterns
+	m.Reset()
+}
+
+// Feed consumes one typed character and reports a match, if any.
+// '\r' is treated as '\n', and '\b' or DEL as Backspace. After a match
+// the buffer is cleared.
+func (m *Matcher) Feed(r rune) (Match, bool) {
+	switch r {
+	case '\b', 0x7f:
+		m.Backspace()
+		return Match{}, false
+	case '\r':
+		r = '\n'
+	}
+
+	if m.expandKey != 0 && r == m.expandKey {
+		match, ok := m.matchTail()
+		m.Reset()
+		return match, ok
+	}
+
+	m.buffer = append(m.buffer, r)
+	if len(m.buffer) > m.limit {
+		m.buffer = append(m.buffer[:0], m.buffer[len(m.buffer)-m.limit:]...)
+	}
+
+	if m.expandKey == 0 {
+		if match, ok := m.matchAt(len(m.buffer)); ok {
+			m.Reset()
+			return match, true
+		}
+	}
+	return Match{}, false
+}
+
+// FeedString feeds each character of s and returns the last match
+func (m *Matcher) FeedString(s string) (Match, bool) {
+	var (
+		last  Match
+		found bool
+	)
+	for _, r := range s {
+		if match, ok := m.Feed(r); ok {
+			last, found = match, true
+		}
+	}
+	return last, found
+}
+
+// Backspace removes the last buffered character
+func (m *Matcher) Backspace() {
+	if len(m.buffer) > 0 {
+		m.buffer = m.buffer[:len(m.buffer)-1]
+	}
+}
+
+// Reset clears the buffer. Hosts call it when the caret moves, focus
+// changes or text is pasted.
+func (m *Matcher) Reset() {
+	m.buffer = m.buffer[:0]
+}
+
+// matchTail finds a trigger ending at the end of the buffer or followed
+// by a ?query tail running to the end
+func (m *Matcher) matchTail() (Match, bool) {
+	if match, ok := m.matchAt(len(m.buffer)); ok {
+		return match, true
+	}
+
+	token := len(m.buffer)
+	for token > 0 && !unicode.IsSpace(m.buffer[token-1]) {
+		token--
+	}
+	for i := len(m.buffer) - 1; i >= token; i-- {
+		if m.buffer[i] == '?' {
+			if match, ok := m.matchAt(i); ok {
+				return match, true
+			}
+		}
+	}
+	return m.matchPattern(token)
+}
+
+// matchPattern finds the longest regex trigger, with an optional ?query
+// tail, running from token or later to the end of the buffer
+func (m *Matcher) matchPattern(token int) (Match, bool) {
+	for start := token; start < len(m.buffer) && len(m.patterns) > 0; start++ {
+		if !m.boundary(start) {
+			continue
+		}
+
+		end := len(m.buffer)
+		for i := start; i < len(m.buffer); i++ {
+			if m.buffer[i] == '?' {
+				end = i
+				break
+			}
+		}
+
+		trigger := string(m.buffer[start:end])
+		for _, pattern := range m.patterns {
+			if pattern.MatchString(trigger) {
+				typed := m.buffer[start:]
+				return Match{
+					Trigger:     trigger,
+					RawTrigger:  string(typed),
+					Delete:      len(typed),
+					DeleteUTF16: len(utf16.Encode(typed)),
+				}, true
+			}
+		}
+	}
+	return Match{}, false
+}
+
+// matchAt finds the longest trigger ending at buffer[end-1] that
+// satisfies the boundary rule; the match extends to the end of the buffer
+func (m *Matcher) matchAt(end int) (Match, bool) {
+	start := m.longestStart(m.trie, end, false)
+	if folded := m.longestStart(m.folded, end, true); folded >= 0 && (start < 0 || folded < start) {
+		start = folded
+	}
+	if start < 0 {
+		return Match{}, false
+	}
+
+	typed := m.buffer[start:]
+	return Match{
+		Trigger:     string(m.buffer[start:end]),
+		RawTrigger:  string(typed),
+		Delete:      len(typed),
+		DeleteUTF16: len(utf16.Encode(typed)),
+	}, true
+}
+
+// longestStart returns where the longest trigger of trie ending at
+// buffer[end-1] starts, or -1. Folded tries are walked in lower case.
+func (m *Matcher) longestStart(trie *triggerTrie, end int, folded bool) int {
+	node := trie.root
+	start := -1
+	for i := end - 1; i >= 0; i-- {
+		r := m.buffer[i]
+		if folded {
+			r = unicode.ToLower(r)
+		}
+		node = node.children[r]
+		if node == nil {
+			break
+		}
+		if node.terminal && m.boundary(i) {
+			start = i
+		}
+	}
+	return start
+}
+
+// boundary reports whether a trigger may start at buffer[start]
+func (m *Matcher) boundary(start int) bool {
+	if !m.strict || start == 0 {
+		return true
+	}
+	before := m.buffer[start-1]
+	return !unicode.IsLetter(before) && !unicode.IsDigit(before)
+}
+
+func parseExpandKey(name string) (rune, error) {
+	if name == "" || strings.EqualFold(name, "none") {
+		return 0, nil
+	}
+	if key, ok := expandKeys[strings.ToLower(name)]; ok {
+		return key, nil
+	}
+	if utf8.RuneCountInString(name) == 1 {
+		r, _ := utf8.DecodeRuneInString(name)
+		return r, nil
+	}
+	return 0, fmt.Errorf("unknown expand key: %s", name)
+}
+
+// triggerTrie stores triggers reversed, so walking back from the end of
+// the typed buffer finds every trigger ending there
+type triggerTrie struct {
+	root *trieNode
+}
+
+type trieNode struct {
+	children map[rune]*trieNode
+	terminal bool
+}
+
+func newTriggerTrie() *triggerTrie {
+	return &triggerTrie{root: &trieNode{children: make(map[rune]*trieNode)}}
+}
+
+func (t *triggerTrie) insert(trigger string) {
+	runes := []rune(trigger)
+	node := t.root
+	for i := len(runes) - 1; i >= 0; i-- {
+		child := node.children[runes[i]]
+		if child == nil {
+			child = &trieNode{children: make(map[rune]*trieNode)}
+			node.children[runes[i]] = child
+		}
+		node = child
+	}
+	node.terminal = true
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/snipq/core/pkg/types"
+)
+
+func newTestMatcher(t *testing.T, expandKey string, strict bool, triggers ...string) *Matcher {
+	t.Helper()
+
+	m, err := NewMatcher(triggers, types.Settings{ExpandKey: expandKey, StrictBoundaries: strict})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMatcher_ExpandKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		expandKey string
+		strict    bool
+		typed     string
+		want      Match
+		wantMatch bool
+	}{
+		{name: "tab", expandKey: "Tab", strict: true, typed: "hello :ty\t", want: Match{Trigger: ":ty", RawTrigger: ":ty", Delete: 3, DeleteUTF16: 3}, wantMatch: true},
+		{name: "no key yet", expandKey: "Tab", strict: true, typed: "hello :ty"},
+		{name: "query tail", expandKey: "Tab", strict: true, typed: ":inv?pad=3&customer=ACME\t", want: Match{Trigger: ":inv", RawTrigger: ":inv?pad=3&customer=ACME", Delete: 24, DeleteUTF16: 24}, wantMatch: true},
+		{name: "tail after whitespace", expandKey: "Tab", strict: true, typed: ":inv? x\t"},
+		{name: "space key", expandKey: "Space", strict: true, typed: "ok :ty ", want: Match{Trigger: ":ty", RawTrigger: ":ty", Delete: 3, DeleteUTF16: 3}, wantMatch: true},
+		{name: "single character key", expandKey: "/", strict: true, typed: ":ty/", want: Match{Trigger: ":ty", RawTrigger: ":ty", Delete: 3, DeleteUTF16: 3}, wantMatch: true},
+		{name: "strict boundary", expandKey: "Tab", strict: true, typed: "abc:ty\t"},
+		{name: "punctuation boundary", expandKey: "Tab", strict: true, typed: "(:ty\t", want: Match{Trigger: ":ty", RawTrigger: ":ty", Delete: 3, DeleteUTF16: 3}, wantMatch: true},
+		{name: "loose boundary", expandKey: "Tab", strict: false, typed: "abc:ty\t", want: Match{Trigger: ":ty", RawTrigger: ":ty", Delete: 3, DeleteUTF16: 3}, wantMatch: true},
+		{name: "longest trigger", expandKey: "Tab", strict: false, typed: "x:inv\t", want: Match{Trigger: "x:inv", RawTrigger: "x:inv", Delete: 5, DeleteUTF16: 5}, wantMatch: true},
+		{name: "utf16 delete", expandKey: "Tab", strict: true, typed: ":ty?emoji=😀\t", want: Match{Trigger: ":ty", RawTrigger: ":ty?emoji=😀", Delete: 11, DeleteUTF16: 12}, wantMatch: true},
+		{name: "backspace", expandKey: "Tab", strict: true, typed: ":tyx\b\t", want: Match{Trigger: ":ty", RawTrigger: ":ty", Delete: 3, DeleteUTF16: 3}, wantMatch: true},
+		{name: "immediate", expandKey: "", strict: true, typed: "so :ty", want: Match{Trigger: ":ty", RawTrigger: ":ty", Delete: 3, DeleteUTF16: 3}, wantMatch: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMatcher(t, tt.expandKey, tt.strict, ":ty", ":inv", "x:inv")
+			got, ok := m.FeedString(tt.typed)
+			if ok != tt.wantMatch {
+				t.Fatalf("FeedString(%q) matched = %v, want %v", tt.typed, ok, tt.wantMatch)
+			}
+			if got != tt.want {
+				t.Errorf("FeedString(%q) = %+v, want %+v", tt.typed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcher_ResetsAfterMatch(t *testing.T) {
+	m := newTestMatcher(t, "Tab", true, ":ty")
+
+	if _, ok := m.FeedString(":ty\t"); !ok {
+		t.Fatal("expected first match")
+	}
+	if _, ok := m.FeedString("\t"); ok {
+		t.Error("expand key alone should not match after the buffer was cleared")
+	}
+
+	m.FeedString(":t")
+	m.Reset()
+	if _, ok := m.FeedString("y\t"); ok {
+		t.Error("Reset should drop typed characters")
+	}
+}
+
+func TestMatcher_RollingBuffer(t *testing.T) {
+	m := newTestMatcher(t, "Tab", true, ":ty")
+
+	for i := 0; i < 10000; i++ {
+		m.Feed('a')
+	}
+	if len(m.buffer) > m.limit {
+		t.Errorf("buffer grew to %d runes, limit %d", len(m.buffer), m.limit)
+	}
+
+	if _, ok := m.FeedString(" :ty\t"); !ok {
+		t.Error("expected match after long input")
+	}
+}
+
+func TestMatcher_UnknownExpandKey(t *testing.T) {
+	if _, err := NewMatcher(nil, types.Settings{ExpandKey: "Hyper"}); err == nil {
+		t.Error("expected error for unknown expand key")
+	}
+}
+
+func TestEngine_NewMatcher(t *testing.T) {
+	engine, _ := newTestEngine(t,
+		types.Snippet{ID: "snp_ty", Name: "Thanks", Trigger: "ty", Template: "Thank you"},
+		types.Snippet{ID: "snp_inv", Name: "Invoice", Trigger: ":inv", Template: "INV-{{ .n }}"},
+	)
+
+	m, err := engine.NewMatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	match, ok := m.FeedString("see :inv?n=7\t")
+	if !ok {
+		t.Fatal("expected match for :inv")
+	}
+	rendered, err := engine.Expand(types.TriggerInput{RawTrigger: match.RawTrigger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered.Output != "INV-7" {
+		t.Errorf("Output = %q, want %q", rendered.Output, "INV-7")
+	}
+}
+
+func BenchmarkMatcher_Feed(b *testing.B) {
+	triggers := make([]string, 5000)
+	for i := range triggers {
+		triggers[i] = fmt.Sprintf(":snip%d", i)
+	}
+	m, err := NewMatcher(triggers, types.Settings{ExpandKey: "Tab", StrictBoundaries: true})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.FeedString("hello :snip4999\t")
+	}
+}
@@ -0,0 +1,196 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snipq/core/pkg/parser"
+	"github.com/snipq/core/pkg/types"
+)
+
+// Strict parameter errors
+var (
+	ErrUnknownParam = errors.New("unknown param")
+	ErrInvalidParam = errors.New("invalid param")
+	ErrMissingParam = errors.New("missing param")
+)
+
+// engineParams are query params every snippet accepts
+var engineParams = []string{"dateFormat", "timezone", "locale", "fmtStyle"}
+
+// ParamError describes a query param rejected by a strict snippet.
+// Err is ErrUnknownParam, ErrInvalidParam or ErrMissingParam.
+type ParamError struct {
+	SnippetID string
+	Param     string
+	Value     string
+	Reason    string
+	Allowed   []string // valid values, or valid param names for unknown params
+	Err       error
+}
+
+func (e *ParamError) Error() string {
+	msg := fmt.Sprintf("%s: snippet %s: %s", e.Err, e.SnippetID, e.Param)
+	if e.Reason != "" {
+		msg += " " + e.Reason
+	}
+	if len(e.Allowed) > 0 {
+		msg += " (valid: " + strings.Join(e.Allowed, ", ") + ")"
+	}
+	return msg
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// checkParams enforces a strict snippet's declared params. Unknown query
+// params are rejected and declared ones, from the query or the snippet
+// defaults, are validated and returned converted to their type.
+func (e *Engine) checkParams(snippet *types.Snippet, query map[string][]string) (map[string]any, error) {
+	declared := make(map[string]bool, len(snippet.Params))
+	scalar := make(map[string]bool, len(snippet.Params))
+	for _, param := range snippet.Params {
+		declared[param.Name] = true
+		scalar[param.Name] = true
+	}
+
+	var known []string
+	for _, param := range snippet.Params {
+		known = append(known, param.Name)
+	}
+	for _, field := range snippet.Fields {
+		if !declared[field.Name] {
+			known = append(known, field.Name)
+			declared[field.Name] = true
+		}
+	}
+	for key := range snippet.Defaults {
+		if !declared[key] {
+			known = append(known, key)
+			declared[key] = true
+		}
+	}
+	for _, key := range engineParams {
+		declared[key] = true
+	}
+	sort.Strings(known)
+
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		root := parser.ParamRoot(key)
+		if !declared[root] {
+			return nil, &ParamError{SnippetID: snippet.ID, Param: key, Value: firstValue(query[key]), Allowed: known, Err: ErrUnknownParam}
+		}
+		// Declared params hold a single value, so lang[]=xx or lang.a=xx
+		// would slip past their validation
+		if key != root && scalar[root] {
+			return nil, &ParamError{SnippetID: snippet.ID, Param: key, Value: firstValue(query[key]), Reason: "takes a single value", Err: ErrInvalidParam}
+		}
+	}
+
+	checked := make(map[string]any, len(snippet.Params))
+	for _, param := range snippet.Params {
+		values, ok := query[param.Name]
+		if len(values) > 1 {
+			return nil, &ParamError{SnippetID: snippet.ID, Param: param.Name, Value: values[0], Reason: "takes a single value", Err: ErrInvalidParam}
+		}
+		raw := firstValue(values)
+		if !ok {
+			if value, exists := snippet.Defaults[param.Name]; exists && value != nil {
+				raw = fmt.Sprint(value)
+			}
+		}
+
+		if raw == "" {
+			if param.Required {
+				return nil, &ParamError{SnippetID: snippet.ID, Param: param.Name, Reason: "is required", Allowed: param.Enum, Err: ErrMissingParam}
+			}
+			continue
+		}
+
+		value, err := e.checkParam(param, raw)
+		if err != nil {
+			return nil, &ParamError{SnippetID: snippet.ID, Param: param.Name, Value: raw, Reason: err.Error(), Allowed: param.Enum, Err: ErrInvalidParam}
+		}
+		checked[param.Name] = value
+	}
+
+	return checked, nil
+}
+
+// checkParam validates a raw value against a param declaration and
+// returns it converted to the declared type
+func (e *Engine) checkParam(param types.Param, raw string) (any, error) {
+	if len(param.Enum) > 0 && !containsString(param.Enum, raw) {
+		return nil, fmt.Errorf("%q is not allowed", raw)
+	}
+
+	if param.Pattern != "" {
+		re, err := e.vault.ParamPattern(param.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("has invalid pattern: %v", err)
+		}
+		if !re.MatchString(raw) {
+			return nil, fmt.Errorf("%q does not match %s", raw, param.Pattern)
+		}
+	}
+
+	var number float64
+	var value any
+	switch param.Type {
+	case types.ParamInt:
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", raw)
+		}
+		number, value = float64(n), n
+	case types.ParamFloat:
+		f, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", raw)
+		}
+		number, value = f, f
+	case types.ParamBool:
+		b, ok := parser.ParseBool(raw)
+		if !ok {
+			return nil, fmt.Errorf("%q is not a boolean", raw)
+		}
+		return b, nil
+	default:
+		return raw, nil
+	}
+
+	if param.Min != nil && number < *param.Min {
+		return nil, fmt.Errorf("%s is less than %s", raw, strconv.FormatFloat(*param.Min, 'f', -1, 64))
+	}
+	if param.Max != nil && number > *param.Max {
+		return nil, fmt.Errorf("%s is greater than %s", raw, strconv.FormatFloat(*param.Max, 'f', -1, 64))
+	}
+
+	return value, nil
+}
+
+func firstValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
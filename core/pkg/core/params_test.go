@@ -0,0 +1,248 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/snipq/core/pkg/parser"
+	"github.com/snipq/core/pkg/types"
+)
+
+func strictSnippet() types.Snippet {
+	pad, maxPad := 1.0, 8.0
+	return types.Snippet{
+		ID:       "snp_ref",
+		Name:     "Reference",
+		Trigger:  ":ref",
+		Strict:   true,
+		Defaults: map[string]any{"lang": "en", "pad": 3},
+		Params: []types.Param{
+			{Name: "lang", Enum: []string{"en", "vi", "ja"}},
+			{Name: "pad", Type: types.ParamInt, Min: &pad, Max: &maxPad},
+			{Name: "code", Pattern: "[A-Z]{3}", Required: true},
+			{Name: "upper", Type: types.ParamBool},
+		},
+		Template: `{{ .lang }}-{{ .code }}-{{ printf "%0*d" .pad 7 }}{{ if .upper }}!{{ end }}`,
+	}
+}
+
+func TestEngine_ExpandStrictParams(t *testing.T) {
+	engine, _ := newTestEngine(t, strictSnippet())
+
+	tests := []struct {
+		name        string
+		trigger     string
+		want        string
+		wantErr     error
+		wantParam   string
+		wantAllowed []string
+	}{
+		{
+			name:    "valid params",
+			trigger: ":ref?code=ABC&lang=vi&pad=4&upper=yes",
+			want:    "vi-ABC-0007!",
+		},
+		{
+			name:    "defaults are typed",
+			trigger: ":ref?code=XYZ",
+			want:    "en-XYZ-007",
+		},
+		{
+			name:    "engine params accepted",
+			trigger: ":ref?code=XYZ&locale=vi",
+			want:    "en-XYZ-007",
+		},
+		{
+			name:        "unknown param",
+			trigger:     ":ref?code=ABC&tone=casual",
+			wantErr:     ErrUnknownParam,
+			wantParam:   "tone",
+			wantAllowed: []string{"code", "lang", "pad", "upper"},
+		},
+		{
+			name:        "value outside enum",
+			trigger:     ":ref?code=ABC&lang=xx",
+			wantErr:     ErrInvalidParam,
+			wantParam:   "lang",
+			wantAllowed: []string{"en", "vi", "ja"},
+		},
+		{
+			name:      "pattern mismatch",
+			trigger:   ":ref?code=abc",
+			wantErr:   ErrInvalidParam,
+			wantParam: "code",
+		},
+		{
+			name:      "not an integer",
+			trigger:   ":ref?code=ABC&pad=two",
+			wantErr:   ErrInvalidParam,
+			wantParam: "pad",
+		},
+		{
+			name:      "above max",
+			trigger:   ":ref?code=ABC&pad=12",
+			wantErr:   ErrInvalidParam,
+			wantParam: "pad",
+		},
+		{
+			name:      "not a boolean",
+			trigger:   ":ref?code=ABC&upper=maybe",
+			wantErr:   ErrInvalidParam,
+			wantParam: "upper",
+		},
+		{
+			name:      "list form of a declared param",
+			trigger:   ":ref?code=ABC&lang[]=xx",
+			wantErr:   ErrInvalidParam,
+			wantParam: "lang[]",
+		},
+		{
+			name:      "nested form of a declared param",
+			trigger:   ":ref?code=ABC&lang.a=xx",
+			wantErr:   ErrInvalidParam,
+			wantParam: "lang.a",
+		},
+		{
+			name:      "missing required",
+			trigger:   ":ref?lang=ja",
+			wantErr:   ErrMissingParam,
+			wantParam: "code",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.Expand(types.TriggerInput{RawTrigger: tt.trigger})
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Expand() error = %v", err)
+				}
+				if got.Output != tt.want {
+					t.Errorf("Expand() = %q, want %q", got.Output, tt.want)
+				}
+				return
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expand() error = %v, want %v", err, tt.wantErr)
+			}
+			var paramErr *ParamError
+			if !errors.As(err, &paramErr) {
+				t.Fatalf("Expand() error %T is not *ParamError", err)
+			}
+			if paramErr.Param != tt.wantParam {
+				t.Errorf("ParamError.Param = %q, want %q", paramErr.Param, tt.wantParam)
+			}
+			if tt.wantAllowed != nil && !reflect.DeepEqual(paramErr.Allowed, tt.wantAllowed) {
+				t.Errorf("ParamError.Allowed = %v, want %v", paramErr.Allowed, tt.wantAllowed)
+			}
+		})
+	}
+}
+
+func TestEngine_ExpandStrictRejectsStructuredForms(t *testing.T) {
+	engine := openTestdataVault(t)
+
+	for _, trigger := range []string{":ty?lang[]=xx", ":ty?lang[0]=xx", ":ty?lang=vi&lang.a=xx"} {
+		_, err := engine.Expand(types.TriggerInput{RawTrigger: trigger})
+		var paramErr *ParamError
+		if !errors.As(err, &paramErr) || !errors.Is(err, ErrInvalidParam) {
+			t.Errorf("Expand(%s) error = %v, want an invalid ParamError", trigger, err)
+		}
+	}
+}
+
+func TestEngine_ExpandNonStrictIgnoresSchema(t *testing.T) {
+	snippet := strictSnippet()
+	snippet.Strict = false
+	snippet.Template = "{{ .lang }}"
+	engine, _ := newTestEngine(t, snippet)
+
+	got, err := engine.Expand(types.TriggerInput{RawTrigger: ":ref?lang=xx&tone=casual"})
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+	if got.Output != "xx" {
+		t.Errorf("Expand() = %q, want %q", got.Output, "xx")
+	}
+}
+
+func TestEngine_ExpandCoercesQueryParams(t *testing.T) {
+	snippet := types.Snippet{
+		ID:       "snp_size",
+		Name:     "Size",
+		Trigger:  ":size",
+		Defaults: map[string]any{"n": 3},
+		Template: `{{ if gt .n 9 }}big{{ else }}small{{ end }}`,
+	}
+	engine, _ := newTestEngine(t, snippet)
+
+	got, err := engine.Expand(types.TriggerInput{RawTrigger: ":size?n=10"})
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+	if got.Output != "big" {
+		t.Errorf("Expand() = %q, want %q", got.Output, "big")
+	}
+
+	_, err = engine.Expand(types.TriggerInput{RawTrigger: ":size?n=ten"})
+	var coercionErr *parser.CoercionError
+	if !errors.As(err, &coercionErr) || coercionErr.Param != "n" {
+		t.Fatalf("Expand() error = %v, want coercion error for n", err)
+	}
+}
+
+func TestEngine_ExpandStructuredParams(t *testing.T) {
+	snippet := types.Snippet{
+		ID:       "snp_cc",
+		Name:     "CC line",
+		Trigger:  ":cc",
+		Strict:   true,
+		Defaults: map[string]any{"to": []any{}, "items": []any{}, "addr": map[string]any{"city": "Hanoi"}},
+		Template: `CC: {{ range $i, $to := .to }}{{ if $i }}, {{ end }}{{ $to }}{{ end }}` +
+			`{{ range .items }}{{ "\n" }}- {{ . }}{{ end }}{{ "\n" }}{{ .addr.city }}`,
+	}
+	engine, _ := newTestEngine(t, snippet)
+
+	tests := []struct {
+		name    string
+		trigger string
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "repeated and nested params",
+			trigger: ":cc?to=an&to=binh&items[]=milk&items[]=tea&addr.city=Hue",
+			want:    "CC: an, binh\n- milk\n- tea\nHue",
+		},
+		{
+			name:    "single value for a list default",
+			trigger: ":cc?to=an",
+			want:    "CC: an\nHanoi",
+		},
+		{
+			name:    "unknown nested root in strict mode",
+			trigger: ":cc?to=an&office.city=Hue",
+			wantErr: ErrUnknownParam,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.Expand(types.TriggerInput{RawTrigger: tt.trigger})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expand() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expand() error = %v", err)
+			}
+			if got.Output != tt.want {
+				t.Errorf("Expand() = %q, want %q", got.Output, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/snipq/core/pkg/parser"
+	"github.com/snipq/core/pkg/types"
+	"github.com/snipq/core/pkg/vault"
+)
+
+// MaxSuggestions is how many suggestions a NotFoundError carries
+const MaxSuggestions = 5
+
+// ErrSnippetNotFound is returned, as a *NotFoundError, when no snippet
+// matches a trigger
+var ErrSnippetNotFound = vault.ErrSnippetNotFound
+
+// NotFoundError is returned by Expand and Preview when no snippet matches
+// a trigger. Suggestions lists close triggers, best first, for a
+// "did you mean" prompt.
+type NotFoundError struct {
+	Trigger     string
+	Suggestions []Suggestion
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrSnippetNotFound, e.Trigger)
+}
+
+// Is reports whether target is ErrSnippetNotFound
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrSnippetNotFound
+}
+
+// Suggestion is a snippet whose trigger is close to a typed one.
+// Distance is the edit distance between the trigger names and Uses the
+// number of expansions of the snippet in history.
+type Suggestion struct {
+	Trigger   string  `json:"trigger"` // full typed trigger, prefix included
+	SnippetID string  `json:"snippetId"`
+	Name      string  `json:"name"`
+	Distance  int     `json:"distance"`
+	Uses      int     `json:"uses"`
+	Score     float64 `json:"score"`
+}
+
+// Suggest returns up to limit snippets of enabled groups whose triggers
+// are close to trigger, best first. Candidates are within a few edits of
+// the typed name (more for longer names), start with it, or make up most
+// of its start. They
+// rank by edit distance, with a bonus for prefix matches and for
+// snippets used often.
+func (e *Engine) Suggest(trigger string, limit int) []Suggestion {
+	name, ok := parser.StripPrefix(trigger, e.vault.GetSettings().Prefix)
+	if !ok {
+		name = trigger
+	}
+	name = strings.ToLower(name)
+	if name == "" || limit <= 0 {
+		return nil
+	}
+
+	uses := make(map[string]int)
+	for _, entry := range e.vault.GetHistory() {
+		uses[entry.SnippetID]++
+	}
+
+	var suggestions []Suggestion
+	for _, snippet := range e.vault.ListAllSnippets(types.ListOptions{}) {
+		// Snippets matched only by a regex have no trigger to suggest
+		candidate := strings.ToLower(e.vault.TriggerName(snippet.Trigger))
+		if candidate == "" {
+			continue
+		}
+		distance := editDistance(name, candidate)
+		// A trigger the typed name starts with only counts when it covers
+		// most of it, so a short trigger such as "d" does not match every
+		// longer typo
+		prefix := strings.HasPrefix(candidate, name) ||
+			(strings.HasPrefix(name, candidate) && 2*len([]rune(candidate)) > len([]rune(name)))
+		if distance > maxEdits(name) && !prefix {
+			continue
+		}
+
+		score := -float64(distance) + math.Log1p(float64(uses[snippet.ID]))/2
+		if prefix {
+			score++
+		}
+		suggestions = append(suggestions, Suggestion{
+			Trigger:   e.FullTrigger(*snippet),
+			SnippetID: snippet.ID,
+			Name:      snippet.Name,
+			Distance:  distance,
+			Uses:      uses[snippet.ID],
+			Score:     score,
+		})
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		if suggestions[i].Trigger != suggestions[j].Trigger {
+			return suggestions[i].Trigger < suggestions[j].Trigger
+		}
+		return suggestions[i].SnippetID < suggestions[j].SnippetID
+	})
+
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// maxEdits is how many edits away a suggestion may be from a typed name
+func maxEdits(name string) int {
+	switch n := len([]rune(name)); {
+	case n <= 4:
+		return 1
+	case n <= 8:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// editDistance returns the optimal string alignment distance between a
+// and b: insertions, deletions, substitutions and transpositions of
+// adjacent runes each cost one
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	rows := make([][]int, len(s)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(t)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(s)][len(t)]
+}
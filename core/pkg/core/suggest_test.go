@@ -0,0 +1,110 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/snipq/core/pkg/types"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"ty", "ty", 0},
+		{"yt", "ty", 1},
+		{"tyy", "ty", 1},
+		{"dat", "date", 1},
+		{"sig", "sign", 1},
+		{"cảm", "cam", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestEngine_Suggest(t *testing.T) {
+	engine, _ := newTestEngine(t,
+		types.Snippet{ID: "snp_ty", Name: "Thanks", Trigger: "ty", Template: "Thanks"},
+		types.Snippet{ID: "snp_tx", Name: "Tax", Trigger: "tx", Template: "Tax"},
+		types.Snippet{ID: "snp_date", Name: "Date", Trigger: "date", Template: "today"},
+		types.Snippet{ID: "snp_datetime", Name: "Date time", Trigger: "datetime", Template: "now"},
+		types.Snippet{ID: "snp_addr", Name: "Address", Trigger: "addr", Template: "Hanoi"},
+		types.Snippet{ID: "snp_de", Name: "German", Trigger: "de", Template: "Deutsch"},
+		types.Snippet{ID: "snp_jira", Name: "Jira", Regex: `jira-(?P<issue>\d+)`, Template: "{{ .issue }}"},
+	)
+
+	triggers := func(suggestions []Suggestion) []string {
+		result := make([]string, len(suggestions))
+		for i, suggestion := range suggestions {
+			result[i] = suggestion.Trigger
+		}
+		return result
+	}
+
+	if got := triggers(engine.Suggest(":yt", 5)); len(got) != 1 || got[0] != ":ty" {
+		t.Errorf("Suggest(:yt) = %v, want [:ty]", got)
+	}
+	if got := triggers(engine.Suggest(":dat", 5)); len(got) != 2 || got[0] != ":date" || got[1] != ":datetime" {
+		t.Errorf("Suggest(:dat) = %v, want [:date :datetime]", got)
+	}
+	if got := engine.Suggest(":zzzzzz", 5); len(got) != 0 {
+		t.Errorf("Suggest(:zzzzzz) = %v, want none", triggers(got))
+	}
+	if got := triggers(engine.Suggest(":deploy", 5)); len(got) != 0 {
+		t.Errorf("Suggest(:deploy) = %v, want none", got)
+	}
+	if got := triggers(engine.Suggest(":addrs", 5)); len(got) != 1 || got[0] != ":addr" {
+		t.Errorf("Suggest(:addrs) = %v, want [:addr]", got)
+	}
+	if got := engine.Suggest(":j", 5); len(got) != 0 {
+		t.Errorf("Suggest(:j) = %v, want no regex-only snippets", triggers(got))
+	}
+	if got := engine.Suggest(":yt", 1); len(got) != 1 {
+		t.Errorf("Suggest(:yt, 1) returned %d suggestions", len(got))
+	}
+
+	// Frequently used snippets rank higher among equally close ones
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		if _, err := engine.Expand(types.TriggerInput{RawTrigger: ":tx", Now: now}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	suggestions := engine.Suggest(":t", 5)
+	if got := triggers(suggestions); len(got) != 2 || got[0] != ":tx" {
+		t.Errorf("Suggest(:t) = %v, want :tx first", got)
+	}
+	if suggestions[0].Uses != 5 {
+		t.Errorf("Suggest(:t)[0].Uses = %d, want 5", suggestions[0].Uses)
+	}
+}
+
+func TestEngine_ExpandNotFoundSuggests(t *testing.T) {
+	engine, _ := newTestEngine(t, types.Snippet{ID: "snp_ty", Name: "Thanks", Trigger: "ty", Template: "Thanks"})
+
+	_, err := engine.Expand(types.TriggerInput{RawTrigger: ":tyy?lang=vi"})
+	if !errors.Is(err, ErrSnippetNotFound) {
+		t.Fatalf("Expand(:tyy) error = %v, want ErrSnippetNotFound", err)
+	}
+
+	var notFound *NotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("Expand(:tyy) error = %T, want *NotFoundError", err)
+	}
+	if notFound.Trigger != ":tyy" || len(notFound.Suggestions) != 1 || notFound.Suggestions[0].SnippetID != "snp_ty" {
+		t.Errorf("NotFoundError = %+v, want a snp_ty suggestion", notFound)
+	}
+
+	_, err = engine.Preview(types.TriggerInput{RawTrigger: ":yt/vi"})
+	if !errors.As(err, &notFound) || len(notFound.Suggestions) != 1 {
+		t.Errorf("Preview(:yt/vi) error = %v, want a suggestion", err)
+	}
+}
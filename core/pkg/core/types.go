@@ -8,9 +8,14 @@ import (
 type Core interface {
 	// Vault management
 	OpenVault(path string) error
-	Reload() error
+	Reload() (VaultDiff, error)
 	Save() error
 
+	// Live reloading
+	Watch(opts WatchOptions) error
+	StopWatching() error
+	Subscribe(fn func(ChangeEvent)) (unsubscribe func())
+
 	// Snippet expansion
 	Expand(input TriggerInput) (Rendered, error)
 	Preview(input TriggerInput) (string, error)
@@ -31,15 +36,33 @@ type Core interface {
 type TriggerInput struct {
 	RawTrigger string    // ":ty?lang=vi&tone=casual"
 	AppID      string    // optional (per-app exclusions)
+	SnippetID  string    // optional: expand this candidate of a shared trigger
 	Now        time.Time // testability
 }
 
 // Rendered represents the result of snippet expansion
+// CursorOffset is counted in runes and CursorOffsetUTF16 in UTF-16 code
+// units; without a cursor marker both point at the end of Output.
+// NestedSnippets lists the IDs of snippets included while rendering.
 type Rendered struct {
-	Output       string         `json:"output"`
-	CursorOffset int            `json:"cursorOffset"`
-	UsedSnippet  string         `json:"usedSnippet"`
-	UsedParams   map[string]any `json:"usedParams"`
+	Output            string         `json:"output"`
+	CursorOffset      int            `json:"cursorOffset"`
+	CursorOffsetUTF16 int            `json:"cursorOffsetUtf16"`
+	TabStops          []TabStop      `json:"tabStops,omitempty"`
+	UsedSnippet       string         `json:"usedSnippet"`
+	NestedSnippets    []string       `json:"nestedSnippets,omitempty"`
+	UsedParams        map[string]any `json:"usedParams"`
+}
+
+// TabStop represents an IDE-style tab stop in rendered output
+// Offsets and lengths are given both in runes and in UTF-16 code units.
+type TabStop struct {
+	Index       int    `json:"index"`
+	Offset      int    `json:"offset"`
+	OffsetUTF16 int    `json:"offsetUtf16"`
+	Length      int    `json:"length"`
+	LengthUTF16 int    `json:"lengthUtf16"`
+	Placeholder string `json:"placeholder,omitempty"`
 }
 
 // Group represents a snippet group
@@ -52,16 +75,70 @@ type Group struct {
 	Enabled     bool   `yaml:"enabled" json:"enabled"`
 }
 
+// ListOptions controls which snippets and groups listing APIs return
+type ListOptions struct {
+	IncludeDisabled bool `json:"includeDisabled,omitempty"` // include disabled groups and their snippets
+}
+
 // Snippet represents a text snippet with template
 type Snippet struct {
-	ID          string         `yaml:"id" json:"id"`
-	Name        string         `yaml:"name" json:"name"`
-	Trigger     string         `yaml:"trigger" json:"trigger"`
-	Description string         `yaml:"description,omitempty" json:"description,omitempty"`
-	Strict      bool           `yaml:"strict,omitempty" json:"strict,omitempty"`
-	Defaults    map[string]any `yaml:"defaults,omitempty" json:"defaults,omitempty"`
-	Template    string         `yaml:"template" json:"template"`
-	GroupID     string         `yaml:"-" json:"groupId"`
+	ID            string            `yaml:"id" json:"id"`
+	Name          string            `yaml:"name" json:"name"`
+	Trigger       string            `yaml:"trigger" json:"trigger"`
+	Regex         string            `yaml:"regex,omitempty" json:"regex,omitempty"` // named groups become params
+	Description   string            `yaml:"description,omitempty" json:"description,omitempty"`
+	Priority      int               `yaml:"priority,omitempty" json:"priority,omitempty"` // breaks ties between groups sharing a trigger
+	Strict        bool              `yaml:"strict,omitempty" json:"strict,omitempty"`
+	PropagateCase *bool             `yaml:"propagateCase,omitempty" json:"propagateCase,omitempty"` // overrides Settings.PropagateCase
+	Defaults      map[string]any    `yaml:"defaults,omitempty" json:"defaults,omitempty"`
+	Fields        []Field           `yaml:"fields,omitempty" json:"fields,omitempty"`
+	Params        []Param           `yaml:"params,omitempty" json:"params,omitempty"`
+	Args          []string          `yaml:"args,omitempty" json:"args,omitempty"`       // positional param order
+	Aliases       map[string]string `yaml:"aliases,omitempty" json:"aliases,omitempty"` // short name -> param name
+	Template      string            `yaml:"template" json:"template"`
+	GroupID       string            `yaml:"-" json:"groupId"`
+}
+
+// Field types for snippet fill-in fields
+const (
+	FieldText     = "text"
+	FieldChoice   = "choice"
+	FieldCheckbox = "checkbox"
+	FieldDate     = "date"
+)
+
+// FieldDateLayout is the layout of date field values
+const FieldDateLayout = "2006-01-02"
+
+// Field represents a fill-in value a snippet asks for at expansion time
+type Field struct {
+	Name     string   `yaml:"name" json:"name"`
+	Label    string   `yaml:"label,omitempty" json:"label,omitempty"`
+	Type     string   `yaml:"type,omitempty" json:"type,omitempty"` // defaults to text
+	Options  []string `yaml:"options,omitempty" json:"options,omitempty"`
+	Default  any      `yaml:"default,omitempty" json:"default,omitempty"`
+	Required bool     `yaml:"required,omitempty" json:"required,omitempty"`
+}
+
+// Param types for declared snippet parameters
+const (
+	ParamString = "string"
+	ParamInt    = "int"
+	ParamFloat  = "float"
+	ParamBool   = "bool"
+)
+
+// Param declares a query parameter a strict snippet accepts.
+// Min and Max bound numeric values.
+type Param struct {
+	Name        string   `yaml:"name" json:"name"`
+	Type        string   `yaml:"type,omitempty" json:"type,omitempty"` // defaults to string
+	Description string   `yaml:"description,omitempty" json:"description,omitempty"`
+	Enum        []string `yaml:"enum,omitempty" json:"enum,omitempty"`
+	Pattern     string   `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+	Min         *float64 `yaml:"min,omitempty" json:"min,omitempty"`
+	Max         *float64 `yaml:"max,omitempty" json:"max,omitempty"`
+	Required    bool     `yaml:"required,omitempty" json:"required,omitempty"`
 }
 
 // Settings represents global vault settings
@@ -69,6 +146,7 @@ type Settings struct {
 	Prefix            string   `yaml:"prefix" json:"prefix"`
 	ExpandKey         string   `yaml:"expandKey" json:"expandKey"`
 	StrictBoundaries  bool     `yaml:"strictBoundaries" json:"strictBoundaries"`
+	PropagateCase     bool     `yaml:"propagateCase,omitempty" json:"propagateCase,omitempty"` // :Ty -> "Thank you", :TY -> "THANK YOU"
 	ExcludedApps      []string `yaml:"excludedApps,omitempty" json:"excludedApps,omitempty"`
 	Locale            string   `yaml:"locale" json:"locale"`
 	DefaultDateFormat string   `yaml:"defaultDateFormat" json:"defaultDateFormat"`
@@ -79,10 +157,14 @@ type Settings struct {
 }
 
 // Counter represents a counter state
+// Value is the last value handed out; the next value is Value+Step.
+// Reset optionally restarts the counter at Start when the period
+// ("daily", "monthly" or "yearly") of UpdatedAt has passed.
 type Counter struct {
 	Value     int       `json:"value"`
 	Step      int       `json:"step"`
 	Start     int       `json:"start"`
+	Reset     string    `json:"reset,omitempty"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
 
@@ -92,6 +174,41 @@ type CounterOpts struct {
 	Step int `json:"step,omitempty"`
 }
 
+// VaultDiff summarises what a reload changed. Each list holds sorted IDs.
+type VaultDiff struct {
+	AddedSnippets   []string `json:"addedSnippets,omitempty"`
+	ChangedSnippets []string `json:"changedSnippets,omitempty"`
+	RemovedSnippets []string `json:"removedSnippets,omitempty"`
+	AddedGroups     []string `json:"addedGroups,omitempty"`
+	ChangedGroups   []string `json:"changedGroups,omitempty"`
+	RemovedGroups   []string `json:"removedGroups,omitempty"`
+	SettingsChanged bool     `json:"settingsChanged,omitempty"`
+	CountersChanged bool     `json:"countersChanged,omitempty"`
+}
+
+// Empty reports whether nothing changed
+func (d VaultDiff) Empty() bool {
+	return len(d.AddedSnippets) == 0 && len(d.ChangedSnippets) == 0 && len(d.RemovedSnippets) == 0 &&
+		len(d.AddedGroups) == 0 && len(d.ChangedGroups) == 0 && len(d.RemovedGroups) == 0 &&
+		!d.SettingsChanged && !d.CountersChanged
+}
+
+// WatchOptions configures the vault watcher. Zero values use defaults.
+type WatchOptions struct {
+	Debounce     time.Duration `json:"debounce,omitempty"`     // quiet period before reloading (default 200ms)
+	PollInterval time.Duration `json:"pollInterval,omitempty"` // scan interval when polling (default 1s)
+	Poll         bool          `json:"poll,omitempty"`         // poll even where native notifications work
+}
+
+// ChangeEvent reports a reload done by the vault watcher. Paths are the
+// changed files relative to the vault root, with forward slashes. Err is
+// set if some of them failed to load; those parts keep their old state.
+type ChangeEvent struct {
+	Diff  VaultDiff `json:"diff"`
+	Paths []string  `json:"paths"`
+	Err   error     `json:"-"`
+}
+
 // HistoryEntry represents a snippet usage history entry
 type HistoryEntry struct {
 	Timestamp  time.Time      `json:"timestamp"`
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"errors"
+
+	"github.com/snipq/core/pkg/types"
+	"github.com/snipq/core/pkg/vault"
+	"github.com/snipq/core/pkg/watch"
+)
+
+// Vault watcher errors
+var (
+	ErrNoVault         = errors.New("no vault open")
+	ErrAlreadyWatching = errors.New("vault already watched")
+)
+
+// Watch starts reloading the vault when its files change on disk. Edits
+// are debounced, and only the changed snippet, group, settings or
+// counters are re-read. Each reload that changed something, or failed,
+// is passed to the subscribers as a ChangeEvent. Reloads and callbacks
+// run on the watcher's goroutine; expansions on other goroutines go on
+// while a reload runs.
+func (e *Engine) Watch(opts types.WatchOptions) error {
+	e.watchMu.Lock()
+	defer e.watchMu.Unlock()
+
+	if e.watcher != nil {
+		return ErrAlreadyWatching
+	}
+	root := e.vault.Path()
+	if root == "" {
+		return ErrNoVault
+	}
+
+	watcher, err := watch.New(root, watch.Options{
+		Debounce:     opts.Debounce,
+		PollInterval: opts.PollInterval,
+		Poll:         opts.Poll,
+		Include:      vault.WatchedPath,
+	})
+	if err != nil {
+		return err
+	}
+
+	e.watcher = watcher
+	go e.reloadChanges(watcher)
+	return nil
+}
+
+// StopWatching stops the watcher started by Watch. A callback already
+// running may still finish afterwards.
+func (e *Engine) StopWatching() error {
+	e.watchMu.Lock()
+	watcher := e.watcher
+	e.watcher = nil
+	e.watchMu.Unlock()
+
+	if watcher == nil {
+		return nil
+	}
+	return watcher.Close()
+}
+
+// Subscribe registers a callback for change events from the watcher and
+// returns a function that unregisters it
+func (e *Engine) Subscribe(fn func(types.ChangeEvent)) (unsubscribe func()) {
+	e.watchMu.Lock()
+	defer e.watchMu.Unlock()
+
+	if e.subscribers == nil {
+		e.subscribers = make(map[int]func(types.ChangeEvent))
+	}
+	id := e.nextSubID
+	e.nextSubID++
+	e.subscribers[id] = fn
+
+	return func() {
+		e.watchMu.Lock()
+		defer e.watchMu.Unlock()
+		delete(e.subscribers, id)
+	}
+}
+
+// reloadChanges reloads each batch of changed files until the watcher is
+// closed
+func (e *Engine) reloadChanges(watcher *watch.Watcher) {
+	for batch := range watcher.Batches() {
+		event := types.ChangeEvent{Paths: batch.Paths, Err: batch.Err}
+		if batch.Err == nil {
+			event.Diff, event.Err = e.vault.ReloadPaths(batch.Paths)
+		}
+		if event.Diff.Empty() && event.Err == nil {
+			// Nothing that matters changed, such as the engine's own writes
+			continue
+		}
+		e.publish(event)
+	}
+}
+
+func (e *Engine) publish(event types.ChangeEvent) {
+	e.watchMu.Lock()
+	subscribers := make([]func(types.ChangeEvent), 0, len(e.subscribers))
+	for _, fn := range e.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	e.watchMu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(event)
+	}
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/snipq/core/pkg/types"
+)
+
+func TestEngine_Watch(t *testing.T) {
+	engine, vaultDir := newTestEngine(t, types.Snippet{ID: "snp_sig", Name: "Signature", Trigger: "sig", Template: "-- An"})
+
+	if err := NewEngine().Watch(types.WatchOptions{}); !errors.Is(err, ErrNoVault) {
+		t.Errorf("Watch() without a vault error = %v, want ErrNoVault", err)
+	}
+
+	if err := engine.Watch(types.WatchOptions{Debounce: 20 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	defer engine.StopWatching()
+	if err := engine.Watch(types.WatchOptions{}); !errors.Is(err, ErrAlreadyWatching) {
+		t.Errorf("second Watch() error = %v, want ErrAlreadyWatching", err)
+	}
+
+	events := make(chan types.ChangeEvent, 4)
+	unsubscribe := engine.Subscribe(func(event types.ChangeEvent) { events <- event })
+	defer unsubscribe()
+
+	next := func() types.ChangeEvent {
+		t.Helper()
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("no change event")
+			return types.ChangeEvent{}
+		}
+	}
+
+	snippetPath := filepath.Join(vaultDir, "groups", "test-group", "snippets", "snp_sig.yaml")
+	writeYAML(t, snippetPath, types.Snippet{ID: "snp_sig", Name: "Signature", Trigger: "sig", Template: "-- Binh"})
+
+	event := next()
+	if event.Err != nil || !reflect.DeepEqual(event.Diff, types.VaultDiff{ChangedSnippets: []string{"snp_sig"}}) {
+		t.Errorf("change event = %+v, want snp_sig changed", event)
+	}
+	if !reflect.DeepEqual(event.Paths, []string{"groups/test-group/snippets/snp_sig.yaml"}) {
+		t.Errorf("change event paths = %v", event.Paths)
+	}
+	if got, err := engine.Expand(types.TriggerInput{RawTrigger: ":sig"}); err != nil || got.Output != "-- Binh" {
+		t.Errorf("Expand(:sig) after change = %q, %v", got.Output, err)
+	}
+
+	// A broken file is reported and the snippet keeps working
+	if err := os.WriteFile(snippetPath, []byte("id: [unterminated\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if event := next(); event.Err == nil {
+		t.Errorf("change event for a broken file = %+v, want an error", event)
+	}
+	if got, err := engine.Expand(types.TriggerInput{RawTrigger: ":sig"}); err != nil || got.Output != "-- Binh" {
+		t.Errorf("Expand(:sig) after broken change = %q, %v", got.Output, err)
+	}
+
+	// The engine's own writes, here history and the repaired snippet file,
+	// change nothing and are not reported
+	if err := engine.UpsertSnippet(types.Snippet{ID: "snp_sig", GroupID: "test-group", Name: "Signature", Trigger: "sig", Template: "-- Binh"}); err != nil {
+		t.Fatal(err)
+	}
+	writeYAML(t, filepath.Join(vaultDir, "settings.yaml"), types.Settings{Prefix: ";", HistoryEnabled: true, HistoryLimit: 10})
+	if event := next(); !reflect.DeepEqual(event.Diff, types.VaultDiff{SettingsChanged: true}) {
+		t.Errorf("change event = %+v, want only the settings change", event)
+	}
+
+	if err := engine.StopWatching(); err != nil {
+		t.Fatal(err)
+	}
+	writeYAML(t, filepath.Join(vaultDir, "settings.yaml"), types.Settings{Prefix: ":"})
+	select {
+	case event := <-events:
+		t.Errorf("change event after StopWatching: %+v", event)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
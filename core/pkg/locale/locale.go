@@ -0,0 +1,302 @@
+package locale
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed data/*.json
+var dataFS embed.FS
+
+// DefaultTag is the locale used when a requested locale is not bundled
+const DefaultTag = "en"
+
+// Ordinal styles for day-of-month ordinals
+const (
+	OrdinalNone = ""
+	OrdinalEN   = "en"  // 1st, 2nd, 3rd
+	OrdinalDot  = "dot" // 1., 2., 3.
+)
+
+// Locale holds the names and separators used to render dates and numbers
+type Locale struct {
+	Tag             string     `json:"tag"`
+	Months          [12]string `json:"months"`
+	ShortMonths     [12]string `json:"shortMonths"`
+	Days            [7]string  `json:"days"` // Sunday first, like time.Weekday
+	ShortDays       [7]string  `json:"shortDays"`
+	MinDays         [7]string  `json:"minDays"`
+	AM              string     `json:"am"`
+	PM              string     `json:"pm"`
+	Ordinal         string     `json:"ordinal"`
+	Decimal         string     `json:"decimal"`
+	Group           string     `json:"group"`
+	CurrencyPattern string     `json:"currencyPattern"`
+
+	// Currency is the default currency code for the requested region
+	Currency string `json:"-"`
+}
+
+// currencyData describes currency symbols and defaults
+type currencyData struct {
+	Symbols   map[string]string `json:"symbols"`
+	Decimals  map[string]int    `json:"decimals"`
+	Regions   map[string]string `json:"regions"`
+	Languages map[string]string `json:"languages"`
+}
+
+var (
+	loadOnce   sync.Once
+	loadErr    error
+	locales    map[string]*Locale
+	currencies currencyData
+)
+
+// load parses the embedded locale data once
+func load() error {
+	loadOnce.Do(func() {
+		locales = make(map[string]*Locale)
+
+		entries, err := dataFS.ReadDir("data")
+		if err != nil {
+			loadErr = err
+			return
+		}
+
+		for _, entry := range entries {
+			data, err := dataFS.ReadFile(path.Join("data", entry.Name()))
+			if err != nil {
+				loadErr = err
+				return
+			}
+
+			if entry.Name() == "currencies.json" {
+				if err := json.Unmarshal(data, &currencies); err != nil {
+					loadErr = fmt.Errorf("failed to parse %s: %w", entry.Name(), err)
+					return
+				}
+				continue
+			}
+
+			var l Locale
+			if err := json.Unmarshal(data, &l); err != nil {
+				loadErr = fmt.Errorf("failed to parse %s: %w", entry.Name(), err)
+				return
+			}
+			locales[l.Tag] = &l
+		}
+	})
+	return loadErr
+}
+
+// Supported returns the language tags of the bundled locales
+func Supported() []string {
+	if err := load(); err != nil {
+		return nil
+	}
+
+	tags := make([]string, 0, len(locales))
+	for tag := range locales {
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// Lookup returns the bundled locale for a BCP 47 tag such as "vi-VN" or
+// "fr_CA". It matches on the language subtag; the region only picks the
+// default currency. ok is false when the language is not bundled and the
+// default locale was returned instead.
+func Lookup(tag string) (l *Locale, ok bool) {
+	if err := load(); err != nil {
+		panic(fmt.Sprintf("locale: invalid embedded data: %v", err))
+	}
+
+	language, region := splitTag(tag)
+
+	base, ok := locales[language]
+	if !ok {
+		base = locales[DefaultTag]
+	}
+
+	resolved := *base
+	resolved.Currency = currencies.Regions[region]
+	if resolved.Currency == "" {
+		resolved.Currency = currencies.Languages[resolved.Tag]
+	}
+
+	return &resolved, ok
+}
+
+// splitTag splits a locale tag into a lower-case language and upper-case region
+func splitTag(tag string) (string, string) {
+	parts := strings.FieldsFunc(tag, func(r rune) bool { return r == '-' || r == '_' })
+	if len(parts) == 0 {
+		return DefaultTag, ""
+	}
+
+	language := strings.ToLower(parts[0])
+	region := ""
+	for _, part := range parts[1:] {
+		if len(part) == 2 {
+			region = strings.ToUpper(part)
+			break
+		}
+	}
+	return language, region
+}
+
+// MonthName returns the full month name
+func (l *Locale) MonthName(m time.Month) string {
+	return l.Months[m-1]
+}
+
+// ShortMonthName returns the abbreviated month name
+func (l *Locale) ShortMonthName(m time.Month) string {
+	return l.ShortMonths[m-1]
+}
+
+// DayName returns the full weekday name
+func (l *Locale) DayName(d time.Weekday) string {
+	return l.Days[d]
+}
+
+// ShortDayName returns the abbreviated weekday name
+func (l *Locale) ShortDayName(d time.Weekday) string {
+	return l.ShortDays[d]
+}
+
+// MinDayName returns the shortest weekday name
+func (l *Locale) MinDayName(d time.Weekday) string {
+	return l.MinDays[d]
+}
+
+// Meridiem returns the AM or PM marker for an hour
+func (l *Locale) Meridiem(hour int) string {
+	if hour < 12 {
+		return l.AM
+	}
+	return l.PM
+}
+
+// OrdinalDay formats a day of month as an ordinal
+func (l *Locale) OrdinalDay(n int) string {
+	switch l.Ordinal {
+	case OrdinalEN:
+		suffix := "th"
+		if n%100 < 11 || n%100 > 13 {
+			switch n % 10 {
+			case 1:
+				suffix = "st"
+			case 2:
+				suffix = "nd"
+			case 3:
+				suffix = "rd"
+			}
+		}
+		return strconv.Itoa(n) + suffix
+	case OrdinalDot:
+		return strconv.Itoa(n) + "."
+	}
+	return strconv.Itoa(n)
+}
+
+// FormatNumber formats a number with the locale's separators and the
+// given number of decimals. NaN and infinities are written as "NaN", "∞"
+// and "-∞".
+func (l *Locale) FormatNumber(value float64, decimals int) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "∞"
+	case math.IsInf(value, -1):
+		return "-∞"
+	}
+	if decimals < 0 {
+		decimals = 0
+	}
+
+	negative := value < 0
+	integer, fraction := roundHalfUp(math.Abs(value), decimals)
+	digits := integer + fraction
+
+	var buf strings.Builder
+	if negative && strings.Trim(digits, "0") != "" {
+		buf.WriteByte('-')
+	}
+	for i, r := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			buf.WriteString(l.Group)
+		}
+		buf.WriteRune(r)
+	}
+	if fraction != "" {
+		buf.WriteString(l.Decimal)
+		buf.WriteString(fraction)
+	}
+
+	return buf.String()
+}
+
+// roundHalfUp rounds a non-negative value to decimals places, half away from
+// zero, working on its shortest decimal representation so 2.675 rounds to
+// 2.68 as written rather than to its binary approximation
+func roundHalfUp(value float64, decimals int) (string, string) {
+	integer, fraction, _ := strings.Cut(strconv.FormatFloat(value, 'f', -1, 64), ".")
+
+	if len(fraction) <= decimals {
+		return integer, fraction + strings.Repeat("0", decimals-len(fraction))
+	}
+
+	roundUp := fraction[decimals] >= '5'
+	digits := []byte(integer + fraction[:decimals])
+	if roundUp {
+		i := len(digits) - 1
+		for ; i >= 0; i-- {
+			if digits[i] < '9' {
+				digits[i]++
+				break
+			}
+			digits[i] = '0'
+		}
+		if i < 0 {
+			digits = append([]byte{'1'}, digits...)
+		}
+	}
+
+	split := len(digits) - decimals
+	return string(digits[:split]), string(digits[split:])
+}
+
+// FormatCurrency formats an amount in a currency (ISO 4217 code). An empty
+// code uses the locale's default currency.
+func (l *Locale) FormatCurrency(value float64, code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		code = l.Currency
+	}
+
+	symbol, ok := currencies.Symbols[code]
+	if !ok {
+		symbol = code
+	}
+
+	decimals, ok := currencies.Decimals[code]
+	if !ok {
+		decimals = 2
+	}
+
+	amount := l.FormatNumber(math.Abs(value), decimals)
+	formatted := strings.NewReplacer("{symbol}", symbol, "{amount}", amount).Replace(l.CurrencyPattern)
+	if value < 0 && strings.Trim(amount, "0.,") != "" {
+		return "-" + formatted
+	}
+	return formatted
+}
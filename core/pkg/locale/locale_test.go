@@ -0,0 +1,129 @@
+package locale
+
+import (
+	"math"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSupported(t *testing.T) {
+	got := Supported()
+	sort.Strings(got)
+
+	want := []string{"de", "en", "fr", "ja", "vi"}
+	if len(got) != len(want) {
+		t.Fatalf("Supported() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Supported() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		tag          string
+		wantTag      string
+		wantCurrency string
+		wantOK       bool
+	}{
+		{"en-US", "en", "USD", true},
+		{"en-GB", "en", "GBP", true},
+		{"vi-VN", "vi", "VND", true},
+		{"vi", "vi", "VND", true},
+		{"ja_JP", "ja", "JPY", true},
+		{"fr-CA", "fr", "CAD", true},
+		{"de-Latn-CH", "de", "CHF", true},
+		{"DE", "de", "EUR", true},
+		{"", "en", "USD", true},
+		{"xx-YY", "en", "USD", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			l, ok := Lookup(tt.tag)
+			if ok != tt.wantOK {
+				t.Errorf("Lookup(%q) ok = %v, want %v", tt.tag, ok, tt.wantOK)
+			}
+			if l.Tag != tt.wantTag || l.Currency != tt.wantCurrency {
+				t.Errorf("Lookup(%q) = %s/%s, want %s/%s", tt.tag, l.Tag, l.Currency, tt.wantTag, tt.wantCurrency)
+			}
+		})
+	}
+}
+
+func TestBundledLocalesComplete(t *testing.T) {
+	for _, tag := range Supported() {
+		l, _ := Lookup(tag)
+		for m := time.January; m <= time.December; m++ {
+			if l.MonthName(m) == "" || l.ShortMonthName(m) == "" {
+				t.Errorf("%s: missing name for %s", tag, m)
+			}
+		}
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if l.DayName(d) == "" || l.ShortDayName(d) == "" || l.MinDayName(d) == "" {
+				t.Errorf("%s: missing name for %s", tag, d)
+			}
+		}
+		if l.AM == "" || l.PM == "" || l.Decimal == "" || l.CurrencyPattern == "" {
+			t.Errorf("%s: missing markers or separators", tag)
+		}
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	en, _ := Lookup("en")
+	vi, _ := Lookup("vi")
+
+	tests := []struct {
+		name     string
+		locale   *Locale
+		value    float64
+		decimals int
+		want     string
+	}{
+		{"zero", en, 0, 0, "0"},
+		{"hundreds", en, 999, 0, "999"},
+		{"thousands", en, 1000, 0, "1,000"},
+		{"rounding", en, 1999.995, 2, "2,000.00"},
+		{"negative zero", en, -0.001, 2, "0.00"},
+		{"vi separators", vi, 1234567.5, 1, "1.234.567,5"},
+		{"negative decimals clamp", en, 12.7, -1, "13"},
+		{"not a number", en, math.NaN(), 2, "NaN"},
+		{"infinity", vi, math.Inf(-1), 2, "-∞"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.locale.FormatNumber(tt.value, tt.decimals); got != tt.want {
+				t.Errorf("FormatNumber() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrdinalDay(t *testing.T) {
+	en, _ := Lookup("en")
+	de, _ := Lookup("de")
+	ja, _ := Lookup("ja")
+
+	tests := []struct {
+		locale *Locale
+		day    int
+		want   string
+	}{
+		{en, 1, "1st"}, {en, 2, "2nd"}, {en, 3, "3rd"}, {en, 4, "4th"},
+		{en, 11, "11th"}, {en, 12, "12th"}, {en, 13, "13th"},
+		{en, 21, "21st"}, {en, 22, "22nd"}, {en, 31, "31st"},
+		{de, 3, "3."},
+		{ja, 3, "3"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.locale.OrdinalDay(tt.day); got != tt.want {
+			t.Errorf("%s OrdinalDay(%d) = %q, want %q", tt.locale.Tag, tt.day, got, tt.want)
+		}
+	}
+}
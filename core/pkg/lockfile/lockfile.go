@@ -0,0 +1,384 @@
+// Package lockfile provides advisory reader/writer locks between
+// processes sharing a directory. A writer holds a lock file; each reader
+// holds a file of its own next to it. Lock files record the PID, host
+// name and time of their holder, so a lock left behind by a process that
+// died is detected and broken.
+//
+// Locks are advisory: they only exclude processes that take them too.
+// Within a process, locks on the same file are also coordinated in
+// memory, so goroutines wait for each other without polling.
+package lockfile
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default timings used for zero Options values
+const (
+	DefaultTimeout       = 10 * time.Second
+	DefaultStaleAfter    = 30 * time.Second
+	DefaultRetryInterval = 10 * time.Millisecond
+)
+
+var (
+	// ErrLocked is returned, wrapped in a *LockedError, when a lock is
+	// still held by another process after Options.Timeout
+	ErrLocked = errors.New("locked by another process")
+
+	// ErrLockLost is returned by Unlock when the lock was broken as stale
+	// while it was held. The lock file, which may belong to another
+	// holder by then, is left alone.
+	ErrLockLost = errors.New("lock was broken by another process")
+)
+
+// Options configures a Lock
+type Options struct {
+	Timeout       time.Duration // how long to wait for other holders
+	RetryInterval time.Duration // how often to check while waiting
+
+	// StaleAfter is how old a lock may get before it is considered
+	// abandoned. Holders keep locks for a single operation, so this only
+	// matters for holders on other hosts or whose process cannot be
+	// checked; a lock whose process is gone on this host is broken at
+	// once.
+	StaleAfter time.Duration
+}
+
+// Owner identifies the holder of a lock. Token tells apart holders that
+// share a PID and host name, such as goroutines or a process that reused
+// the PID of a dead one.
+type Owner struct {
+	PID      int       `json:"pid"`
+	Hostname string    `json:"hostname"`
+	Acquired time.Time `json:"acquired"`
+	Token    string    `json:"token,omitempty"`
+}
+
+// LockedError reports a lock held by another process
+type LockedError struct {
+	Path  string
+	Owner Owner
+}
+
+func (e *LockedError) Error() string {
+	if e.Owner.PID == 0 {
+		return fmt.Sprintf("%s: %v", e.Path, ErrLocked)
+	}
+	return fmt.Sprintf("%s: %v (pid %d on %s since %s)", e.Path, ErrLocked,
+		e.Owner.PID, e.Owner.Hostname, e.Owner.Acquired.Format(time.RFC3339))
+}
+
+func (e *LockedError) Unwrap() error {
+	return ErrLocked
+}
+
+// Lock is a reader/writer lock on a lock file. Readers are kept in files
+// named after it with a ".r-" suffix.
+type Lock struct {
+	path  string
+	opts  Options
+	local *sync.RWMutex
+	self  Owner
+}
+
+// locals holds the in-process lock of each lock file
+var locals sync.Map // absolute path -> *sync.RWMutex
+
+// New returns the lock kept in the file at path. Its directory must
+// exist.
+func New(path string, opts Options) *Lock {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.StaleAfter <= 0 {
+		opts.StaleAfter = DefaultStaleAfter
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = DefaultRetryInterval
+	}
+
+	hostname, _ := os.Hostname()
+	local, _ := locals.LoadOrStore(localKey(path), new(sync.RWMutex))
+	return &Lock{
+		path:  path,
+		opts:  opts,
+		local: local.(*sync.RWMutex),
+		self:  Owner{PID: os.Getpid(), Hostname: hostname},
+	}
+}
+
+// localKey names a lock file the same however its path is spelled
+func localKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	dir, file := filepath.Split(path)
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = real
+	}
+	return filepath.Join(dir, file)
+}
+
+// Handle is a held lock
+type Handle struct {
+	release func() error
+	once    sync.Once
+}
+
+// Unlock releases the lock. Later calls do nothing.
+func (h *Handle) Unlock() error {
+	var err error
+	h.once.Do(func() { err = h.release() })
+	return err
+}
+
+// Lock takes the lock for writing. It waits for the current writer and
+// readers to finish, up to Options.Timeout.
+func (l *Lock) Lock() (*Handle, error) {
+	l.local.Lock()
+	data, err := l.acquireWriter()
+	if err != nil {
+		l.local.Unlock()
+		return nil, err
+	}
+	if err := l.waitForReaders(); err != nil {
+		removeIfOwned(l.path, data)
+		l.local.Unlock()
+		return nil, err
+	}
+
+	return &Handle{release: func() error {
+		defer l.local.Unlock()
+		return removeIfOwned(l.path, data)
+	}}, nil
+}
+
+// RLock takes the lock for reading. It waits for the current writer to
+// finish, up to Options.Timeout; other readers may hold it too.
+func (l *Lock) RLock() (*Handle, error) {
+	l.local.RLock()
+	deadline := time.Now().Add(l.opts.Timeout)
+	for {
+		if err := l.waitForWriter(deadline); err != nil {
+			l.local.RUnlock()
+			return nil, err
+		}
+
+		reader, data, err := l.create(func() (*os.File, error) {
+			return os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".r-*")
+		})
+		if err != nil {
+			l.local.RUnlock()
+			return nil, err
+		}
+
+		// A writer that came in meanwhile has not seen this reader yet
+		// and goes ahead, so this reader steps back
+		if _, err := os.Stat(l.path); errors.Is(err, os.ErrNotExist) {
+			return &Handle{release: func() error {
+				defer l.local.RUnlock()
+				return removeIfOwned(reader, data)
+			}}, nil
+		}
+		removeIfOwned(reader, data)
+	}
+}
+
+// acquireWriter creates the lock file, breaking it if it is stale, and
+// returns what it wrote. The caller holds l.local for writing.
+func (l *Lock) acquireWriter() ([]byte, error) {
+	deadline := time.Now().Add(l.opts.Timeout)
+	for {
+		_, data, err := l.create(func() (*os.File, error) {
+			return os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		})
+		if !errors.Is(err, os.ErrExist) {
+			return data, err
+		}
+
+		owner, err := l.breakIfStale(l.path)
+		if err != nil {
+			return nil, err
+		}
+		if owner == nil {
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, &LockedError{Path: l.path, Owner: *owner}
+		}
+		time.Sleep(l.opts.RetryInterval)
+	}
+}
+
+// waitForReaders waits until no reader holds the lock. The caller holds
+// the lock file.
+func (l *Lock) waitForReaders() error {
+	deadline := time.Now().Add(l.opts.Timeout)
+	for {
+		readers, err := l.readers()
+		if err != nil {
+			return err
+		}
+
+		var holder *Owner
+		for _, reader := range readers {
+			owner, err := l.breakIfStale(reader)
+			if err != nil {
+				return err
+			}
+			if owner != nil {
+				holder = owner
+				break
+			}
+		}
+		if holder == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return &LockedError{Path: l.path, Owner: *holder}
+		}
+		time.Sleep(l.opts.RetryInterval)
+	}
+}
+
+// waitForWriter waits until no writer holds the lock
+func (l *Lock) waitForWriter(deadline time.Time) error {
+	for {
+		owner, err := l.breakIfStale(l.path)
+		if err != nil {
+			return err
+		}
+		if owner == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return &LockedError{Path: l.path, Owner: *owner}
+		}
+		time.Sleep(l.opts.RetryInterval)
+	}
+}
+
+// readers returns the reader files of the lock
+func (l *Lock) readers() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(l.path))
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := filepath.Base(l.path) + ".r-"
+	var readers []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), prefix) {
+			readers = append(readers, filepath.Join(filepath.Dir(l.path), entry.Name()))
+		}
+	}
+	return readers, nil
+}
+
+// create makes a lock file with open, records this process as its owner
+// under a new token, and returns the file name and what it wrote
+func (l *Lock) create(open func() (*os.File, error)) (string, []byte, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", nil, err
+	}
+
+	f, err := open()
+	if err != nil {
+		return "", nil, err
+	}
+
+	owner := l.self
+	owner.Acquired = time.Now()
+	owner.Token = hex.EncodeToString(token)
+	data, err := json.Marshal(owner)
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", nil, err
+	}
+	return f.Name(), data, nil
+}
+
+// removeIfOwned removes the lock file at path if it still holds data,
+// what its holder wrote, and reports ErrLockLost otherwise
+func removeIfOwned(path string, data []byte) error {
+	current, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && string(current) != string(data)) {
+		return fmt.Errorf("%s: %w", path, ErrLockLost)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// breakIfStale removes the lock file at path if its holder is gone and
+// returns the owner of a live one, or nil if there is none. The caller
+// holds l.local, so a file naming this process is left over from an
+// earlier process with the same PID.
+func (l *Lock) breakIfStale(path string) (*Owner, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var owner Owner
+	if err := json.Unmarshal(data, &owner); err != nil || owner.PID == 0 {
+		// Being written, or cut short by a crash
+		info, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		owner = Owner{Acquired: info.ModTime()}
+	}
+
+	if !l.stale(owner) {
+		return &owner, nil
+	}
+
+	// Only remove the file judged stale, not one another process
+	// created after breaking it first
+	if current, err := os.ReadFile(path); err != nil || string(current) != string(data) {
+		return nil, nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to break stale lock %s: %w", path, err)
+	}
+	return nil, nil
+}
+
+// stale reports whether the holder of a lock is gone. A holder on this
+// host is checked directly, however long it has held the lock; the age
+// of the lock only counts when that is not possible.
+func (l *Lock) stale(owner Owner) bool {
+	if owner.PID != 0 && owner.Hostname == l.self.Hostname {
+		if owner.PID == l.self.PID {
+			return true
+		}
+		if alive, known := processAlive(owner.PID); known {
+			return !alive
+		}
+	}
+	return time.Since(owner.Acquired) > l.opts.StaleAfter
+}
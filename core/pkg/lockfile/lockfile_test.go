@@ -0,0 +1,268 @@
+package lockfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testOptions = Options{Timeout: 100 * time.Millisecond, RetryInterval: time.Millisecond}
+
+// deadPID returns the PID of a process that has exited
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+// plant writes a lock file as another process would
+func plant(t *testing.T, path string, owner Owner) {
+	t.Helper()
+	data, err := json.Marshal(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockHeldByAnotherProcess(t *testing.T) {
+	hostname, _ := os.Hostname()
+	parent := Owner{PID: os.Getppid(), Hostname: hostname, Acquired: time.Now()}
+	longHeld := parent
+	longHeld.Acquired = time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name  string
+		file  string
+		owner Owner
+		read  bool // whether readers get the lock
+	}{
+		{name: "writer", file: ".snipq.lock", owner: parent},
+		{name: "reader", file: ".snipq.lock.r-1", owner: parent, read: true},
+		{name: "long-held writer", file: ".snipq.lock", owner: longHeld},
+		{name: "long-held reader", file: ".snipq.lock.r-1", owner: longHeld, read: true},
+		{name: "writer on another host", file: ".snipq.lock", owner: Owner{PID: 1, Hostname: "elsewhere", Acquired: time.Now()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			plant(t, filepath.Join(dir, tt.file), tt.owner)
+			lock := New(filepath.Join(dir, ".snipq.lock"), testOptions)
+
+			_, err := lock.Lock()
+			var locked *LockedError
+			if !errors.As(err, &locked) || !errors.Is(err, ErrLocked) {
+				t.Fatalf("Lock() error = %v, want a LockedError", err)
+			}
+			if locked.Owner.PID != tt.owner.PID || locked.Owner.Hostname != tt.owner.Hostname {
+				t.Errorf("LockedError owner = %+v, want %+v", locked.Owner, tt.owner)
+			}
+			if _, err := os.Stat(filepath.Join(dir, tt.file)); err != nil {
+				t.Errorf("live lock file removed: %v", err)
+			}
+
+			handle, err := lock.RLock()
+			if tt.read {
+				if err != nil {
+					t.Fatalf("RLock() error = %v", err)
+				}
+				handle.Unlock()
+			} else if !errors.Is(err, ErrLocked) {
+				t.Errorf("RLock() error = %v, want ErrLocked", err)
+			}
+		})
+	}
+}
+
+func TestLockBreaksStaleLocks(t *testing.T) {
+	hostname, _ := os.Hostname()
+	dead := deadPID(t)
+	old := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name  string
+		file  string
+		owner *Owner // nil plants an empty file
+	}{
+		{name: "dead writer", file: ".snipq.lock", owner: &Owner{PID: dead, Hostname: hostname, Acquired: time.Now()}},
+		{name: "dead reader", file: ".snipq.lock.r-1", owner: &Owner{PID: dead, Hostname: hostname, Acquired: time.Now()}},
+		{name: "earlier process with this PID", file: ".snipq.lock", owner: &Owner{PID: os.Getpid(), Hostname: hostname, Acquired: time.Now()}},
+		{name: "old writer on another host", file: ".snipq.lock", owner: &Owner{PID: 1, Hostname: "elsewhere", Acquired: old}},
+		{name: "truncated writer", file: ".snipq.lock"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, tt.file)
+			if tt.owner != nil {
+				plant(t, file, *tt.owner)
+			} else {
+				if err := os.WriteFile(file, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(file, old, old); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			lock := New(filepath.Join(dir, ".snipq.lock"), testOptions)
+			handle, err := lock.Lock()
+			if err != nil {
+				t.Fatalf("Lock() error = %v, want the stale lock broken", err)
+			}
+			if err := handle.Unlock(); err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("lock files left after Unlock: %v", entries)
+			}
+		})
+	}
+}
+
+func TestLockRecordsOwner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".snipq.lock")
+	handle, err := New(path, testOptions).Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handle.Unlock()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var owner Owner
+	if err := json.Unmarshal(data, &owner); err != nil {
+		t.Fatal(err)
+	}
+	hostname, _ := os.Hostname()
+	if owner.PID != os.Getpid() || owner.Hostname != hostname || time.Since(owner.Acquired) > time.Minute || owner.Token == "" {
+		t.Errorf("lock owner = %+v", owner)
+	}
+}
+
+func TestUnlockAfterLockBroken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".snipq.lock")
+	handle, err := New(path, testOptions).Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process broke the lock as stale and took it
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	other := Owner{PID: 1, Hostname: "elsewhere", Acquired: time.Now(), Token: "other"}
+	plant(t, path, other)
+
+	if err := handle.Unlock(); !errors.Is(err, ErrLockLost) {
+		t.Errorf("Unlock() error = %v, want ErrLockLost", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("lock of the new holder removed: %v", err)
+	}
+	var owner Owner
+	if err := json.Unmarshal(data, &owner); err != nil || owner.Token != "other" {
+		t.Errorf("lock file = %s, want the new holder's", data)
+	}
+}
+
+// simulatedProcess returns a lock that coordinates with others only
+// through the lock files, like one in another process on another host
+func simulatedProcess(path string, i int) *Lock {
+	lock := New(path, Options{RetryInterval: time.Millisecond})
+	lock.local = new(sync.RWMutex)
+	lock.self = Owner{PID: 1000 + i, Hostname: fmt.Sprintf("host-%d", i)}
+	return lock
+}
+
+// TestLockExclusion checks that writers exclude everyone and readers
+// share, both between goroutines and between processes
+func TestLockExclusion(t *testing.T) {
+	t.Run("goroutines", func(t *testing.T) {
+		testLockExclusion(t, func(path string, i int) *Lock { return New(path, Options{}) })
+	})
+	t.Run("processes", func(t *testing.T) {
+		testLockExclusion(t, simulatedProcess)
+	})
+}
+
+func testLockExclusion(t *testing.T, newLock func(path string, i int) *Lock) {
+	path := filepath.Join(t.TempDir(), ".snipq.lock")
+
+	var mu sync.Mutex
+	var readers, writers int
+	check := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if writers > 1 || (writers == 1 && readers > 0) {
+			t.Errorf("%d writers and %d readers hold the lock", writers, readers)
+		}
+	}
+	enter := func(count *int, delta int) {
+		mu.Lock()
+		*count += delta
+		mu.Unlock()
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		i, write := i, i%2 == 0
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock := newLock(path, i)
+			for j := 0; j < 20; j++ {
+				var handle *Handle
+				var err error
+				count := &readers
+				if write {
+					handle, err = lock.Lock()
+					count = &writers
+				} else {
+					handle, err = lock.RLock()
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				enter(count, 1)
+				check()
+				time.Sleep(100 * time.Microsecond)
+				enter(count, -1)
+				if err := handle.Unlock(); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("lock files left: %v", entries)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package lockfile
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processAlive reports whether a process with pid exists on this host
+func processAlive(pid int) (alive, known bool) {
+	err := syscall.Kill(pid, 0)
+	switch {
+	case err == nil, errors.Is(err, syscall.EPERM):
+		// EPERM: it exists but belongs to another user
+		return true, true
+	case errors.Is(err, syscall.ESRCH):
+		return false, true
+	}
+	return false, false
+}
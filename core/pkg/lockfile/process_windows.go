@@ -0,0 +1,31 @@
+//go:build windows
+
+package lockfile
+
+import (
+	"errors"
+	"syscall"
+)
+
+const (
+	stillActive           = 259               // exit code of a process still running
+	errorInvalidParameter = syscall.Errno(87) // OpenProcess: no such process
+)
+
+// processAlive reports whether a process with pid exists on this host
+func processAlive(pid int) (alive, known bool) {
+	h, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		if errors.Is(err, syscall.ERROR_ACCESS_DENIED) {
+			return true, true
+		}
+		return false, errors.Is(err, errorInvalidParameter)
+	}
+	defer syscall.CloseHandle(h)
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return false, false
+	}
+	return code == stillActive, true
+}
@@ -0,0 +1,163 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrParamCoercion is returned when a query param cannot be converted
+var ErrParamCoercion = errors.New("param coercion failed")
+
+// CoercionError describes a query param value that does not fit the type
+// of the default it overrides
+type CoercionError struct {
+	Param string
+	Value string
+	Type  string
+	Err   error
+}
+
+func (e *CoercionError) Error() string {
+	msg := fmt.Sprintf("%s: %s=%q is not a valid %s", ErrParamCoercion, e.Param, e.Value, e.Type)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Is reports whether target is ErrParamCoercion
+func (e *CoercionError) Is(target error) bool {
+	return target == ErrParamCoercion
+}
+
+func (e *CoercionError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	intPattern   = regexp.MustCompile(`^-?(0|[1-9][0-9]*)$`)
+	floatPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)\.[0-9]+$`)
+)
+
+// dateLayouts are the layouts accepted for date params
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// CoerceParam converts a query value to the type of def, the default it
+// overrides. Without a default the type is inferred: booleans, integers,
+// decimals and JSON objects or arrays are converted, anything else stays a
+// string. Numbers with leading zeros (zip codes, phone numbers) stay strings.
+func CoerceParam(name, value string, def any) (any, error) {
+	fail := func(typ string, err error) (any, error) {
+		return nil, &CoercionError{Param: name, Value: value, Type: typ, Err: err}
+	}
+	trimmed := strings.TrimSpace(value)
+
+	switch d := def.(type) {
+	case nil:
+		return inferValue(value), nil
+	case string:
+		return value, nil
+	case bool:
+		b, ok := ParseBool(trimmed)
+		if !ok {
+			return fail("bool", nil)
+		}
+		return b, nil
+	case int:
+		n, err := strconv.Atoi(trimmed)
+		if err != nil {
+			return fail("int", nil)
+		}
+		return n, nil
+	case int64:
+		n, err := strconv.ParseInt(trimmed, 10, 64)
+		if err != nil {
+			return fail("int", nil)
+		}
+		return n, nil
+	case float64:
+		f, err := strconv.ParseFloat(trimmed, 64)
+		if err != nil {
+			return fail("float", nil)
+		}
+		return f, nil
+	case time.Duration:
+		dur, err := time.ParseDuration(trimmed)
+		if err != nil {
+			return fail("duration", nil)
+		}
+		return dur, nil
+	case time.Time:
+		for _, layout := range dateLayouts {
+			if t, err := time.ParseInLocation(layout, trimmed, d.Location()); err == nil {
+				return t, nil
+			}
+		}
+		return fail("date", nil)
+	case map[string]any:
+		var m map[string]any
+		if err := json.Unmarshal([]byte(trimmed), &m); err != nil {
+			return fail("JSON object", err)
+		}
+		return m, nil
+	case []any:
+		var list []any
+		if err := json.Unmarshal([]byte(trimmed), &list); err != nil {
+			return fail("JSON array", err)
+		}
+		return list, nil
+	}
+
+	return inferValue(value), nil
+}
+
+// inferValue converts an unguided query value to the type it looks like.
+// "1" and "0" are integers, not booleans.
+func inferValue(value string) any {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on":
+		return true
+	case "false", "no", "off":
+		return false
+	}
+
+	if intPattern.MatchString(value) {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+
+	if floatPattern.MatchString(value) {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+
+	if strings.HasPrefix(value, "{") || strings.HasPrefix(value, "[") {
+		var literal any
+		if err := json.Unmarshal([]byte(value), &literal); err == nil {
+			return literal
+		}
+	}
+
+	return value
+}
+
+// ParseBool parses the boolean spellings accepted in query params. The
+// second result reports whether value is one of them; an empty value is
+// not. "1" and "0" only count as booleans here, where a bool is expected:
+// inferValue reads them as integers.
+func ParseBool(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "true", "1", "yes", "on", "y":
+		return true, true
+	case "false", "0", "no", "off", "n":
+		return false, true
+	}
+	return false, false
+}
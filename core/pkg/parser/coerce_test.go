@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCoerceParam(t *testing.T) {
+	hanoi := time.FixedZone("ICT", 7*3600)
+
+	tests := []struct {
+		name    string
+		value   string
+		def     any
+		want    any
+		wantErr bool
+	}{
+		{"inferred int", "42", nil, 42, false},
+		{"inferred negative int", "-3", nil, -3, false},
+		{"inferred float", "2.50", nil, 2.5, false},
+		{"inferred bool", "yes", nil, true, false},
+		{"leading zero stays string", "007", nil, "007", false},
+		{"phone number stays string", "0912345678", nil, "0912345678", false},
+		{"exponent stays string", "1e3", nil, "1e3", false},
+		{"inferred JSON object", `{"city":"Hanoi"}`, nil, map[string]any{"city": "Hanoi"}, false},
+		{"inferred JSON array", `[1,"a"]`, nil, []any{1.0, "a"}, false},
+		{"invalid JSON stays string", `[oops`, nil, "[oops", false},
+		{"plain string", "vi", nil, "vi", false},
+		{"string default keeps string", "10", "5", "10", false},
+		{"int default", "007", 3, 7, false},
+		{"int default rejects text", "seven", 3, nil, true},
+		{"int64 default", "9", int64(1), int64(9), false},
+		{"float default", "3", 0.5, 3.0, false},
+		{"bool default", "0", true, false, false},
+		{"bool default rejects text", "maybe", true, nil, true},
+		{"bool default rejects an empty value", "", true, nil, true},
+		{"inferred one is an int", "1", nil, 1, false},
+		{"duration default", "90m", time.Hour, 90 * time.Minute, false},
+		{"duration default rejects text", "soon", time.Hour, nil, true},
+		{"date default", "2025-09-01", time.Date(2025, 1, 1, 0, 0, 0, 0, hanoi), time.Date(2025, 9, 1, 0, 0, 0, 0, hanoi), false},
+		{"datetime default", "2025-09-01T08:30", time.Date(2025, 1, 1, 0, 0, 0, 0, hanoi), time.Date(2025, 9, 1, 8, 30, 0, 0, hanoi), false},
+		{"date default rejects text", "tomorrow", time.Time{}, nil, true},
+		{"object default", `{"a":1}`, map[string]any{}, map[string]any{"a": 1.0}, false},
+		{"object default rejects array", `[1]`, map[string]any{}, nil, true},
+		{"array default", `["x"]`, []any{}, []any{"x"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CoerceParam("p", tt.value, tt.def)
+			if tt.wantErr {
+				if !errors.Is(err, ErrParamCoercion) {
+					t.Fatalf("CoerceParam() error = %v, want ErrParamCoercion", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CoerceParam() error = %v", err)
+			}
+			if wantTime, ok := tt.want.(time.Time); ok {
+				if gotTime, ok := got.(time.Time); !ok || !gotTime.Equal(wantTime) || gotTime.Location() != wantTime.Location() {
+					t.Errorf("CoerceParam() = %v, want %v", got, wantTime)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CoerceParam() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,12 +3,19 @@ package parser
 import (
 	"net/url"
 	"strings"
+	"unicode"
 )
 
-// ParsedTrigger represents a parsed trigger with query parameters
+// ParsedTrigger represents a parsed trigger with query parameters.
+// Params holds the first value of each key; Values holds all of them.
+// For shorthand triggers (:ty/vi/casual or :ty(vi,casual)) Base is the
+// trigger without its positional Args; Trigger stays the literal text.
 type ParsedTrigger struct {
-	Trigger string            `json:"trigger"`
-	Params  map[string]string `json:"params"`
+	Trigger string              `json:"trigger"`
+	Base    string              `json:"base,omitempty"`
+	Args    []string            `json:"args,omitempty"`
+	Params  map[string]string   `json:"params"`
+	Values  map[string][]string `json:"values"`
 }
 
 // ParseTrigger parses a raw trigger string into trigger and query parameters
@@ -19,6 +26,7 @@ func ParseTrigger(rawTrigger string) (*ParsedTrigger, error) {
 
 	trigger := strings.TrimSpace(parts[0])
 	params := make(map[string]string)
+	all := make(map[string][]string)
 
 	// Parse query parameters if present
 	if len(parts) > 1 {
@@ -27,55 +35,122 @@ func ParseTrigger(rawTrigger string) (*ParsedTrigger, error) {
 			return nil, err
 		}
 
-		// Convert url.Values to map[string]string (take first value for each key)
+		// Keep every value, and the first value of each key in params
 		for key, values := range queryParams {
 			if len(values) > 0 {
 				params[key] = values[0]
+				all[key] = values
 			}
 		}
 	}
 
+	base, args := splitShorthand(trigger)
+
 	return &ParsedTrigger{
 		Trigger: trigger,
+		Base:    base,
+		Args:    args,
 		Params:  params,
+		Values:  all,
 	}, nil
 }
 
-// MergeParams merges query params with snippet defaults and global defaults
-// Priority: query params > snippet defaults > global defaults
-func MergeParams(queryParams map[string]string, snippetDefaults map[string]any, globalDefaults map[string]any) map[string]any {
-	result := make(map[string]any)
+// splitShorthand splits positional args off a trigger written as
+// :ty/vi/casual or :ty(vi,casual). Leading punctuation is the trigger
+// prefix and never a separator, so prefixes such as "/" or "//" work.
+// Args may be percent-encoded.
+func splitShorthand(trigger string) (string, []string) {
+	start := strings.IndexFunc(trigger, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	})
+	if start < 0 {
+		return "", nil
+	}
+	if start == 0 {
+		start = 1
+	}
 
-	// Start with global defaults
-	for key, value := range globalDefaults {
-		result[key] = value
+	if strings.HasSuffix(trigger, ")") {
+		if open := strings.Index(trigger[start:], "("); open >= 0 {
+			open += start
+			inner := trigger[open+1 : len(trigger)-1]
+			if inner == "" {
+				return trigger[:open], []string{}
+			}
+			args := strings.Split(inner, ",")
+			for i, arg := range args {
+				args[i] = unescapeArg(strings.TrimSpace(arg))
+			}
+			return trigger[:open], args
+		}
 	}
 
-	// Override with snippet defaults
-	for key, value := range snippetDefaults {
-		result[key] = value
+	if slash := strings.Index(trigger[start:], "/"); slash >= 0 {
+		slash += start
+		args := strings.Split(trigger[slash+1:], "/")
+		for i, arg := range args {
+			args[i] = unescapeArg(arg)
+		}
+		return trigger[:slash], args
 	}
 
-	// Override with query params (convert strings to appropriate types)
-	for key, value := range queryParams {
-		result[key] = convertStringValue(value)
+	return "", nil
+}
+
+func unescapeArg(arg string) string {
+	if unescaped, err := url.PathUnescape(arg); err == nil {
+		return unescaped
 	}
+	return arg
+}
 
-	return result
+// StripPrefix returns the trigger name a typed trigger refers to, without
+// the configured prefix. ok is false when the trigger lacks the prefix.
+// An empty prefix leaves the trigger unchanged.
+// Example: StripPrefix(";;ty", ";;") -> "ty", true
+func StripPrefix(trigger, prefix string) (string, bool) {
+	if prefix == "" {
+		return trigger, true
+	}
+	if !strings.HasPrefix(trigger, prefix) || len(trigger) == len(prefix) {
+		return "", false
+	}
+	return trigger[len(prefix):], true
 }
 
-// convertStringValue converts string values to appropriate types
-func convertStringValue(value string) any {
-	// Handle boolean values
-	switch strings.ToLower(value) {
-	case "true", "1", "yes", "on":
-		return true
-	case "false", "0", "no", "off":
-		return false
+// AppendParams appends query parameters to a raw trigger, keeping any
+// query it already has. Keys are added in sorted order.
+// Example: AppendParams(":inv?pad=3", {"customer": "ACME"}) -> ":inv?pad=3&customer=ACME"
+func AppendParams(rawTrigger string, params map[string]string) string {
+	if len(params) == 0 {
+		return rawTrigger
+	}
+
+	values := url.Values{}
+	for key, value := range params {
+		values.Set(key, value)
+	}
+
+	separator := "?"
+	if strings.Contains(rawTrigger, "?") {
+		separator = "&"
+	}
+
+	return rawTrigger + separator + values.Encode()
+}
+
+// MergeParams merges query params with snippet defaults and global defaults
+// Priority: query params > snippet defaults > global defaults
+// Query values are converted to the type of the default they override, or
+// inferred when there is none (see CoerceParam), and dot or bracket keys
+// build nested maps (see MergeValues).
+func MergeParams(queryParams map[string]string, snippetDefaults map[string]any, globalDefaults map[string]any) (map[string]any, error) {
+	values := make(map[string][]string, len(queryParams))
+	for key, value := range queryParams {
+		values[key] = []string{value}
 	}
 
-	// For now, keep as string - we can add more type conversion later
-	return value
+	return MergeValues(values, snippetDefaults, globalDefaults)
 }
 
 // NormalizeTrigger normalizes a trigger by removing extra whitespace
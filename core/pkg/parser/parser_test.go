@@ -1,6 +1,9 @@
 package parser
 
 import (
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -10,6 +13,7 @@ func TestParseTrigger(t *testing.T) {
 		input       string
 		wantTrigger string
 		wantParams  map[string]string
+		wantValues  map[string][]string
 		wantErr     bool
 	}{
 		{
@@ -47,6 +51,14 @@ func TestParseTrigger(t *testing.T) {
 			wantParams:  map[string]string{"format": "Mon, 02 Jan 2006"},
 			wantErr:     false,
 		},
+		{
+			name:        "trigger with repeated param",
+			input:       ":cc?to=an&to=binh",
+			wantTrigger: ":cc",
+			wantParams:  map[string]string{"to": "an"},
+			wantValues:  map[string][]string{"to": {"an", "binh"}},
+			wantErr:     false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -68,6 +80,102 @@ func TestParseTrigger(t *testing.T) {
 					t.Errorf("ParseTrigger() params[%s] = %v, want %v", key, gotValue, wantValue)
 				}
 			}
+			for key, wantValues := range tt.wantValues {
+				if !reflect.DeepEqual(got.Values[key], wantValues) {
+					t.Errorf("ParseTrigger() values[%s] = %v, want %v", key, got.Values[key], wantValues)
+				}
+			}
+		})
+	}
+}
+
+func TestParseTriggerShorthand(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantBase   string
+		wantArgs   []string
+		wantParams map[string]string
+	}{
+		{"plain trigger", ":ty", "", nil, map[string]string{}},
+		{"slash args", ":ty/vi/casual", ":ty", []string{"vi", "casual"}, map[string]string{}},
+		{"paren args", ":ty(vi, casual)", ":ty", []string{"vi", "casual"}, map[string]string{}},
+		{"empty parens", ":ty()", ":ty", []string{}, map[string]string{}},
+		{"skipped arg", ":ty//casual", ":ty", []string{"", "casual"}, map[string]string{}},
+		{"encoded arg", ":mail/B%C3%ACnh%20An", ":mail", []string{"Bình An"}, map[string]string{}},
+		{"args with query", ":ty/vi?tone=casual", ":ty", []string{"vi"}, map[string]string{"tone": "casual"}},
+		{"slash prefix", "/ty/vi", "/ty", []string{"vi"}, map[string]string{}},
+		{"slash prefix without args", "/ty", "", nil, map[string]string{}},
+		{"double slash prefix", "//ty/vi", "//ty", []string{"vi"}, map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTrigger(tt.input)
+			if err != nil {
+				t.Fatalf("ParseTrigger() error = %v", err)
+			}
+			if got.Trigger != strings.SplitN(tt.input, "?", 2)[0] {
+				t.Errorf("ParseTrigger() trigger = %q, want the literal trigger", got.Trigger)
+			}
+			if got.Base != tt.wantBase {
+				t.Errorf("ParseTrigger() base = %q, want %q", got.Base, tt.wantBase)
+			}
+			if !reflect.DeepEqual(got.Args, tt.wantArgs) {
+				t.Errorf("ParseTrigger() args = %#v, want %#v", got.Args, tt.wantArgs)
+			}
+			if !reflect.DeepEqual(got.Params, tt.wantParams) {
+				t.Errorf("ParseTrigger() params = %v, want %v", got.Params, tt.wantParams)
+			}
+		})
+	}
+}
+
+func TestStripPrefix(t *testing.T) {
+	tests := []struct {
+		trigger string
+		prefix  string
+		want    string
+		wantOK  bool
+	}{
+		{":ty", ":", "ty", true},
+		{";;ty", ";;", "ty", true},
+		{"ty", "", "ty", true},
+		{"ty", ":", "", false},
+		{":ty", ";;", "", false},
+		{":", ":", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := StripPrefix(tt.trigger, tt.prefix)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("StripPrefix(%q, %q) = %q, %v; want %q, %v", tt.trigger, tt.prefix, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestAppendParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		trigger string
+		params  map[string]string
+		want    string
+	}{
+		{"no params", ":ty", nil, ":ty"},
+		{"adds query", ":mail", map[string]string{"to": "An", "tone": "casual"}, ":mail?to=An&tone=casual"},
+		{"extends query", ":mail?lang=vi", map[string]string{"to": "Bình An"}, ":mail?lang=vi&to=B%C3%ACnh+An"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AppendParams(tt.trigger, tt.params)
+			if got != tt.want {
+				t.Errorf("AppendParams() = %q, want %q", got, tt.want)
+			}
+
+			if _, err := ParseTrigger(got); err != nil {
+				t.Errorf("ParseTrigger(%q) error = %v", got, err)
+			}
 		})
 	}
 }
@@ -101,11 +209,21 @@ func TestMergeParams(t *testing.T) {
 			globalDefaults:  map[string]any{},
 			want:            map[string]any{"upper": true, "enabled": false},
 		},
+		{
+			name:            "query values take the type of their default",
+			queryParams:     map[string]string{"pad": "5", "format": "2006", "rate": "7"},
+			snippetDefaults: map[string]any{"pad": 3, "rate": 0.5},
+			globalDefaults:  map[string]any{"format": "2006-01-02"},
+			want:            map[string]any{"pad": 5, "format": "2006", "rate": 7.0},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := MergeParams(tt.queryParams, tt.snippetDefaults, tt.globalDefaults)
+			got, err := MergeParams(tt.queryParams, tt.snippetDefaults, tt.globalDefaults)
+			if err != nil {
+				t.Fatalf("MergeParams() error = %v", err)
+			}
 			for key, wantValue := range tt.want {
 				if gotValue, exists := got[key]; !exists || gotValue != wantValue {
 					t.Errorf("MergeParams()[%s] = %v, want %v", key, gotValue, wantValue)
@@ -115,6 +233,21 @@ func TestMergeParams(t *testing.T) {
 	}
 }
 
+func TestMergeParamsCoercionError(t *testing.T) {
+	_, err := MergeParams(map[string]string{"pad": "five"}, map[string]any{"pad": 3}, nil)
+
+	var coercionErr *CoercionError
+	if !errors.As(err, &coercionErr) {
+		t.Fatalf("MergeParams() error = %v, want *CoercionError", err)
+	}
+	if !errors.Is(err, ErrParamCoercion) {
+		t.Errorf("MergeParams() error does not match ErrParamCoercion")
+	}
+	if coercionErr.Param != "pad" || coercionErr.Type != "int" {
+		t.Errorf("CoercionError = %+v, want param pad of type int", coercionErr)
+	}
+}
+
 func TestValidateTrigger(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -0,0 +1,169 @@
+package parser
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// MergeValues merges multi-valued query params with snippet defaults and
+// global defaults, like MergeParams. A key repeated in the query, or ending
+// in [], becomes a list, and dot or bracket keys build nested maps:
+// ?to=a&to=b -> to=[a b], ?addr.city=Hanoi -> addr={city: Hanoi},
+// ?items[]=x -> items=[x]. Default maps are copied, never modified.
+func MergeValues(values map[string][]string, snippetDefaults map[string]any, globalDefaults map[string]any) (map[string]any, error) {
+	result := make(map[string]any)
+
+	for key, value := range globalDefaults {
+		result[key] = value
+	}
+
+	for key, value := range snippetDefaults {
+		result[key] = value
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if len(values[key]) == 0 {
+			continue
+		}
+
+		path, list := parseKey(key)
+		value, err := coerceValues(key, values[key], list, lookupPath(result, path))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := setPath(result, path, value); err != nil {
+			return nil, &CoercionError{Param: key, Value: values[key][0], Type: "object", Err: err}
+		}
+	}
+
+	return result, nil
+}
+
+// ParamRoot returns the top-level param name of a query key:
+// "addr.city" -> "addr", "items[]" -> "items"
+func ParamRoot(key string) string {
+	path, _ := parseKey(key)
+	return path[0]
+}
+
+// parseKey splits a query key into its map path and reports whether it
+// ends in [] (always a list). Malformed keys are used literally.
+func parseKey(key string) ([]string, bool) {
+	list := false
+	if strings.HasSuffix(key, "[]") && len(key) > 2 {
+		list = true
+		key = strings.TrimSuffix(key, "[]")
+	}
+
+	var path []string
+	rest := key
+	for rest != "" {
+		end := strings.IndexAny(rest, ".[")
+		if end == -1 {
+			path = append(path, rest)
+			break
+		}
+
+		if end > 0 {
+			path = append(path, rest[:end])
+		}
+
+		if rest[end] == '.' {
+			rest = rest[end+1:]
+			if rest == "" || end == 0 {
+				return []string{key}, list
+			}
+			continue
+		}
+
+		closing := strings.IndexByte(rest[end:], ']')
+		if closing <= 1 || (end == 0 && len(path) == 0) {
+			return []string{key}, list
+		}
+		path = append(path, rest[end+1:end+closing])
+		rest = strings.TrimPrefix(rest[end+closing+1:], ".")
+	}
+
+	if len(path) == 0 {
+		return []string{key}, list
+	}
+	return path, list
+}
+
+// coerceValues converts the values of one query key. Several values, a
+// [] key or a list default produce a list whose elements take the type of
+// the default's first element.
+func coerceValues(key string, values []string, list bool, def any) (any, error) {
+	defList, defIsList := def.([]any)
+	if !list && !defIsList && len(values) == 1 {
+		return CoerceParam(key, values[0], def)
+	}
+
+	if defIsList && len(values) == 1 && strings.HasPrefix(strings.TrimSpace(values[0]), "[") {
+		return CoerceParam(key, values[0], def)
+	}
+
+	elemDef := def
+	if defIsList {
+		elemDef = nil
+		if len(defList) > 0 {
+			elemDef = defList[0]
+		}
+	}
+
+	items := make([]any, len(values))
+	for i, value := range values {
+		item, err := CoerceParam(key, value, elemDef)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+// lookupPath returns the value at path in params, or nil
+func lookupPath(params map[string]any, path []string) any {
+	var current any = params
+	for _, segment := range path {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+		current = m[segment]
+	}
+	return current
+}
+
+// setPath stores value at path in params, copying the maps along the way
+// so defaults shared with the snippet are not modified
+func setPath(params map[string]any, path []string, value any) error {
+	current := params
+	for i, segment := range path[:len(path)-1] {
+		var child map[string]any
+		switch existing := current[segment].(type) {
+		case nil:
+			child = make(map[string]any)
+		case map[string]any:
+			child = make(map[string]any, len(existing)+1)
+			for key, v := range existing {
+				child[key] = v
+			}
+		default:
+			return errors.New(strings.Join(path[:i+1], ".") + " is not an object")
+		}
+		current[segment] = child
+		current = child
+	}
+
+	current[path[len(path)-1]] = value
+	return nil
+}
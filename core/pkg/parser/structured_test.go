@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMergeValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   map[string][]string
+		defaults map[string]any
+		want     map[string]any
+	}{
+		{
+			name:   "repeated key becomes a list",
+			values: map[string][]string{"to": {"an@x.vn", "binh@x.vn"}},
+			want:   map[string]any{"to": []any{"an@x.vn", "binh@x.vn"}},
+		},
+		{
+			name:   "bracket suffix always makes a list",
+			values: map[string][]string{"items[]": {"milk"}},
+			want:   map[string]any{"items": []any{"milk"}},
+		},
+		{
+			name:     "single value for a list default",
+			values:   map[string][]string{"tags": {"urgent"}},
+			defaults: map[string]any{"tags": []any{"todo"}},
+			want:     map[string]any{"tags": []any{"urgent"}},
+		},
+		{
+			name:     "list elements take the default element type",
+			values:   map[string][]string{"qty": {"2", "07"}},
+			defaults: map[string]any{"qty": []any{1}},
+			want:     map[string]any{"qty": []any{2, 7}},
+		},
+		{
+			name:   "dot notation builds nested maps",
+			values: map[string][]string{"addr.city": {"Hanoi"}, "addr.zip": {"100000"}},
+			want:   map[string]any{"addr": map[string]any{"city": "Hanoi", "zip": 100000}},
+		},
+		{
+			name:   "bracket notation builds nested maps",
+			values: map[string][]string{"addr[city]": {"Hanoi"}, "addr[street][]": {"1 Trang Tien", "Hoan Kiem"}},
+			want: map[string]any{"addr": map[string]any{
+				"city":   "Hanoi",
+				"street": []any{"1 Trang Tien", "Hoan Kiem"},
+			}},
+		},
+		{
+			name:     "nested values override nested defaults",
+			values:   map[string][]string{"addr.city": {"Hue"}},
+			defaults: map[string]any{"addr": map[string]any{"city": "Hanoi", "country": "VN"}},
+			want:     map[string]any{"addr": map[string]any{"city": "Hue", "country": "VN"}},
+		},
+		{
+			name:   "malformed keys are literal",
+			values: map[string][]string{"a..b": {"x"}, "[c]": {"y"}},
+			want:   map[string]any{"a..b": "x", "[c]": "y"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergeValues(tt.values, tt.defaults, nil)
+			if err != nil {
+				t.Fatalf("MergeValues() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeValues() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeValuesKeepsDefaults(t *testing.T) {
+	defaults := map[string]any{"addr": map[string]any{"city": "Hanoi"}}
+
+	if _, err := MergeValues(map[string][]string{"addr.city": {"Hue"}}, defaults, nil); err != nil {
+		t.Fatalf("MergeValues() error = %v", err)
+	}
+	if city := defaults["addr"].(map[string]any)["city"]; city != "Hanoi" {
+		t.Errorf("defaults modified: city = %v", city)
+	}
+}
+
+func TestMergeValuesConflict(t *testing.T) {
+	_, err := MergeValues(map[string][]string{"addr.city": {"Hue"}}, map[string]any{"addr": "Hanoi"}, nil)
+	if !errors.Is(err, ErrParamCoercion) {
+		t.Fatalf("MergeValues() error = %v, want ErrParamCoercion", err)
+	}
+}
+
+func TestParamRoot(t *testing.T) {
+	tests := map[string]string{
+		"lang":           "lang",
+		"addr.city":      "addr",
+		"items[]":        "items",
+		"addr[street][]": "addr",
+		"a..b":           "a..b",
+	}
+
+	for key, want := range tests {
+		if got := ParamRoot(key); got != want {
+			t.Errorf("ParamRoot(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
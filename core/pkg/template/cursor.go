@@ -0,0 +1,253 @@
+package template
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snipq/core/pkg/types"
+)
+
+// Marker runes are taken from the Unicode private use area so they never
+// collide with snippet text. They are emitted by the cursor and tabstop
+// functions and stripped from the output by extractMarkers.
+const (
+	cursorMarker       = '\uE000'
+	tabStopStartMarker = '\uE001'
+	tabStopTextMarker  = '\uE002'
+	tabStopEndMarker   = '\uE003'
+)
+
+// Result is a rendered template with its cursor and tab stop positions
+type Result struct {
+	Text        string
+	Cursor      int // rune offset
+	CursorUTF16 int // UTF-16 code unit offset
+	TabStops    []types.TabStop
+}
+
+// cursorFunc marks the caret position in the rendered output
+func cursorFunc() string {
+	return string(cursorMarker)
+}
+
+// tabstopFunc marks a numbered tab stop, optionally selecting placeholder text
+func tabstopFunc(index any, placeholder ...string) (string, error) {
+	n, err := toInt(index)
+	if err != nil {
+		return "", fmt.Errorf("tabstop: invalid index: %w", err)
+	}
+	if n < 0 {
+		return "", fmt.Errorf("tabstop: index cannot be negative: %d", n)
+	}
+
+	return markTabStop(n, strings.Join(placeholder, "")), nil
+}
+
+// markTabStop surrounds placeholder with the markers of tab stop n
+func markTabStop(n int, placeholder string) string {
+	return string(tabStopStartMarker) + strconv.Itoa(n) + string(tabStopTextMarker) +
+		placeholder + string(tabStopEndMarker)
+}
+
+// expandMarkerTokens rewrites the $|$, ${n} and ${n:text} tokens in the
+// text of a template into cursor and tab stop markers. Tokens inside
+// actions, such as in string literals, are left alone. A placeholder may
+// hold actions, which render into the tab stop, and other tab stops.
+func expandMarkerTokens(templateText string) string {
+	if !strings.Contains(templateText, "$") {
+		return templateText
+	}
+
+	expanded, _ := expandTokens(templateText, 0, false)
+	return expanded
+}
+
+// expandTokens rewrites the tokens in templateText from start. In a
+// placeholder it stops at the } closing it and returns its offset;
+// otherwise it runs to the end of the text.
+func expandTokens(templateText string, start int, placeholder bool) (string, int) {
+	var buf strings.Builder
+	i := start
+	for i < len(templateText) {
+		rest := templateText[i:]
+		switch {
+		case strings.HasPrefix(rest, "{{"):
+			end := actionEnd(templateText, i)
+			buf.WriteString(templateText[i:end])
+			i = end
+		case strings.HasPrefix(rest, "$|$"):
+			buf.WriteString("{{ cursor }}")
+			i += len("$|$")
+		case placeholder && rest[0] == '}':
+			return buf.String(), i
+		default:
+			if stop, n, ok := tabStopToken(templateText, i); ok {
+				buf.WriteString(stop)
+				i += n
+				continue
+			}
+			buf.WriteByte(templateText[i])
+			i++
+		}
+	}
+	return buf.String(), i
+}
+
+// tabStopToken reads a ${n} or ${n:text} token at start and returns its
+// markers and length
+func tabStopToken(templateText string, start int) (string, int, bool) {
+	if !strings.HasPrefix(templateText[start:], "${") {
+		return "", 0, false
+	}
+
+	i := start + len("${")
+	digits := i
+	for i < len(templateText) && templateText[i] >= '0' && templateText[i] <= '9' {
+		i++
+	}
+	index, err := strconv.Atoi(templateText[digits:i])
+	if err != nil {
+		return "", 0, false
+	}
+
+	placeholder := ""
+	if i < len(templateText) && templateText[i] == ':' {
+		placeholder, i = expandTokens(templateText, i+1, true)
+	}
+	if i >= len(templateText) || templateText[i] != '}' {
+		return "", 0, false
+	}
+	return markTabStop(index, placeholder), i + 1 - start, true
+}
+
+// actionEnd returns the offset just past the action starting at start,
+// skipping string literals and comments, or the end of the text if the
+// action is not closed; the template parser reports that
+func actionEnd(templateText string, start int) int {
+	i := start + len("{{")
+	for i < len(templateText) {
+		rest := templateText[i:]
+		switch {
+		case strings.HasPrefix(rest, "}}"):
+			return i + len("}}")
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest, "*/")
+			if end < 0 {
+				return len(templateText)
+			}
+			i += end + len("*/")
+		case rest[0] == '`':
+			end := strings.IndexByte(rest[1:], '`')
+			if end < 0 {
+				return len(templateText)
+			}
+			i += end + 2
+		case rest[0] == '"' || rest[0] == '\'':
+			i = quotedEnd(templateText, i)
+		default:
+			i++
+		}
+	}
+	return len(templateText)
+}
+
+// quotedEnd returns the offset just past the quoted string or character
+// literal starting at start
+func quotedEnd(templateText string, start int) int {
+	quote := templateText[start]
+	for i := start + 1; i < len(templateText); i++ {
+		switch templateText[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		case '\n':
+			// Unterminated; the template parser reports it
+			return i
+		}
+	}
+	return len(templateText)
+}
+
+// extractMarkers strips marker runes from output and records their positions.
+// The first cursor marker wins; without one the cursor sits at the end.
+func extractMarkers(output string) Result {
+	// openStop is a tab stop whose end marker has not been seen yet, and
+	// the byte offset of its placeholder in the output text
+	type openStop struct {
+		stop  types.TabStop
+		start int
+	}
+
+	var (
+		buf      strings.Builder
+		runes    int
+		units    int
+		cursor   = -1
+		cursor16 int
+		stops    []types.TabStop
+		open     []openStop // innermost last
+		inIndex  bool
+		indexBuf strings.Builder
+	)
+
+	for _, r := range output {
+		switch {
+		case r == cursorMarker:
+			if cursor < 0 {
+				cursor, cursor16 = runes, units
+			}
+		case r == tabStopStartMarker:
+			inIndex = true
+			indexBuf.Reset()
+		case r == tabStopTextMarker && inIndex:
+			inIndex = false
+			index, _ := strconv.Atoi(indexBuf.String())
+			open = append(open, openStop{
+				stop:  types.TabStop{Index: index, Offset: runes, OffsetUTF16: units},
+				start: buf.Len(),
+			})
+		case r == tabStopEndMarker && len(open) > 0:
+			last := open[len(open)-1]
+			open = open[:len(open)-1]
+			last.stop.Length = runes - last.stop.Offset
+			last.stop.LengthUTF16 = units - last.stop.OffsetUTF16
+			last.stop.Placeholder = buf.String()[last.start:]
+			stops = append(stops, last.stop)
+		case inIndex:
+			indexBuf.WriteRune(r)
+		default:
+			buf.WriteRune(r)
+			runes++
+			units += utf16Len(r)
+		}
+	}
+
+	if cursor < 0 {
+		cursor, cursor16 = runes, units
+	}
+
+	sort.SliceStable(stops, func(i, j int) bool {
+		if stops[i].Index != stops[j].Index {
+			return stops[i].Index < stops[j].Index
+		}
+		return stops[i].Offset < stops[j].Offset
+	})
+
+	return Result{
+		Text:        buf.String(),
+		Cursor:      cursor,
+		CursorUTF16: cursor16,
+		TabStops:    stops,
+	}
+}
+
+// utf16Len returns the number of UTF-16 code units needed to encode r
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
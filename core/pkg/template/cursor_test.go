@@ -0,0 +1,197 @@
+package template
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/snipq/core/pkg/types"
+)
+
+func TestEngine_RenderResultCursor(t *testing.T) {
+	engine := NewEngine()
+
+	tests := []struct {
+		name        string
+		template    string
+		data        map[string]any
+		wantText    string
+		wantCursor  int
+		wantCursor6 int
+	}{
+		{
+			name:        "no marker puts cursor at end",
+			template:    "Hello",
+			wantText:    "Hello",
+			wantCursor:  5,
+			wantCursor6: 5,
+		},
+		{
+			name:        "cursor function",
+			template:    "Hello {{ cursor }}World",
+			wantText:    "Hello World",
+			wantCursor:  6,
+			wantCursor6: 6,
+		},
+		{
+			name:        "cursor token",
+			template:    "<b>$|$</b>",
+			wantText:    "<b></b>",
+			wantCursor:  3,
+			wantCursor6: 3,
+		},
+		{
+			name:        "multibyte runes before cursor",
+			template:    "Cảm ơn $|$!",
+			wantText:    "Cảm ơn !",
+			wantCursor:  7,
+			wantCursor6: 7,
+		},
+		{
+			name:        "astral runes count twice in UTF-16",
+			template:    "👍🎉 $|$",
+			wantText:    "👍🎉 ",
+			wantCursor:  3,
+			wantCursor6: 5,
+		},
+		{
+			name:        "first cursor wins",
+			template:    "a$|$b$|$c",
+			wantText:    "abc",
+			wantCursor:  1,
+			wantCursor6: 1,
+		},
+		{
+			name:        "cursor after rendered params",
+			template:    "Dear {{ .name }},\n$|$",
+			data:        map[string]any{"name": "Đức"},
+			wantText:    "Dear Đức,\n",
+			wantCursor:  10,
+			wantCursor6: 10,
+		},
+		{
+			name:        "dollar text is left alone",
+			template:    "Price: $5 or ${price}",
+			wantText:    "Price: $5 or ${price}",
+			wantCursor:  21,
+			wantCursor6: 21,
+		},
+		{
+			name:        "tokens inside actions are left alone",
+			template:    "{{ \"$|$\" }}{{/* $|$ }} */}}{{ `${1}` }}$|$",
+			wantText:    "$|$${1}",
+			wantCursor:  7,
+			wantCursor6: 7,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.RenderResult(tt.template, tt.data, Context{})
+			if err != nil {
+				t.Fatalf("Engine.RenderResult() error = %v", err)
+			}
+			if got.Text != tt.wantText {
+				t.Errorf("Engine.RenderResult() text = %q, want %q", got.Text, tt.wantText)
+			}
+			if got.Cursor != tt.wantCursor || got.CursorUTF16 != tt.wantCursor6 {
+				t.Errorf("Engine.RenderResult() cursor = %d/%d, want %d/%d",
+					got.Cursor, got.CursorUTF16, tt.wantCursor, tt.wantCursor6)
+			}
+		})
+	}
+}
+
+func TestEngine_RenderResultTabStops(t *testing.T) {
+	engine := NewEngine()
+
+	tests := []struct {
+		name     string
+		template string
+		wantText string
+		want     []types.TabStop
+	}{
+		{
+			name:     "empty tab stops",
+			template: "for (${1}; ${2}) {}",
+			wantText: "for (; ) {}",
+			want: []types.TabStop{
+				{Index: 1, Offset: 5, OffsetUTF16: 5},
+				{Index: 2, Offset: 7, OffsetUTF16: 7},
+			},
+		},
+		{
+			name:     "placeholders sorted by index",
+			template: "Hi ${2:name}, re: ${1:topic}",
+			wantText: "Hi name, re: topic",
+			want: []types.TabStop{
+				{Index: 1, Offset: 13, OffsetUTF16: 13, Length: 5, LengthUTF16: 5, Placeholder: "topic"},
+				{Index: 2, Offset: 3, OffsetUTF16: 3, Length: 4, LengthUTF16: 4, Placeholder: "name"},
+			},
+		},
+		{
+			name:     "placeholder holding actions",
+			template: "Hi ${1:{{ \"}\" }}{{ printf \"there\" }}}!",
+			wantText: "Hi }there!",
+			want: []types.TabStop{
+				{Index: 1, Offset: 3, OffsetUTF16: 3, Length: 6, LengthUTF16: 6, Placeholder: "}there"},
+			},
+		},
+		{
+			name:     "nested tab stops",
+			template: "a${1:x${2:y}z}b",
+			wantText: "axyzb",
+			want: []types.TabStop{
+				{Index: 1, Offset: 1, OffsetUTF16: 1, Length: 3, LengthUTF16: 3, Placeholder: "xyz"},
+				{Index: 2, Offset: 2, OffsetUTF16: 2, Length: 1, LengthUTF16: 1, Placeholder: "y"},
+			},
+		},
+		{
+			name:     "unclosed placeholder is left alone",
+			template: "a${1:x${2:y}",
+			wantText: "a${1:xy",
+			want: []types.TabStop{
+				{Index: 2, Offset: 6, OffsetUTF16: 6, Length: 1, LengthUTF16: 1, Placeholder: "y"},
+			},
+		},
+		{
+			name:     "tokens inside strings are left alone",
+			template: "{{ printf \"${1:a} %s\" \"${2}\" }}",
+			wantText: "${1:a} ${2}",
+		},
+		{
+			name:     "tabstop function with emoji placeholder",
+			template: "🎉{{ tabstop 1 \"🎉x\" }}",
+			wantText: "🎉🎉x",
+			want: []types.TabStop{
+				{Index: 1, Offset: 1, OffsetUTF16: 2, Length: 2, LengthUTF16: 3, Placeholder: "🎉x"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.RenderResult(tt.template, nil, Context{})
+			if err != nil {
+				t.Fatalf("Engine.RenderResult() error = %v", err)
+			}
+			if got.Text != tt.wantText {
+				t.Errorf("Engine.RenderResult() text = %q, want %q", got.Text, tt.wantText)
+			}
+			if !reflect.DeepEqual(got.TabStops, tt.want) {
+				t.Errorf("Engine.RenderResult() tab stops = %+v, want %+v", got.TabStops, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_RenderStripsMarkers(t *testing.T) {
+	engine := NewEngine()
+
+	got, err := engine.Render("a{{ cursor }}b${1:c}", map[string]any{})
+	if err != nil {
+		t.Fatalf("Engine.Render() error = %v", err)
+	}
+	if got != "abc" {
+		t.Errorf("Engine.Render() = %q, want %q", got, "abc")
+	}
+}
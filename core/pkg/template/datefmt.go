@@ -0,0 +1,328 @@
+package template
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snipq/core/pkg/locale"
+)
+
+// Date format styles accepted by the fmtStyle parameter
+const (
+	DateStyleAuto     = "auto"
+	DateStyleGo       = "go"
+	DateStyleMoment   = "moment"
+	DateStyleStrftime = "strftime"
+	DateStyleICU      = "icu"
+)
+
+// dateToken renders one format token for a time
+type dateToken func(t time.Time, l *locale.Locale) string
+
+// dateDialect describes a token-based date format dialect
+type dateDialect struct {
+	tokens map[string]dateToken
+	order  []string // tokens sorted longest first for greedy matching
+}
+
+func newDateDialect(tokens map[string]dateToken) *dateDialect {
+	order := make([]string, 0, len(tokens))
+	for token := range tokens {
+		order = append(order, token)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		if len(order[i]) != len(order[j]) {
+			return len(order[i]) > len(order[j])
+		}
+		return order[i] < order[j]
+	})
+	return &dateDialect{tokens: tokens, order: order}
+}
+
+func pad2(n int) string { return fmt.Sprintf("%02d", n) }
+func pad3(n int) string { return fmt.Sprintf("%03d", n) }
+
+func hour12(t time.Time) int {
+	h := t.Hour() % 12
+	if h == 0 {
+		return 12
+	}
+	return h
+}
+
+func isoWeekday(t time.Time) int {
+	if t.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(t.Weekday())
+}
+
+var momentDialect = newDateDialect(map[string]dateToken{
+	"YYYY": func(t time.Time, l *locale.Locale) string { return strconv.Itoa(t.Year()) },
+	"YY":   func(t time.Time, l *locale.Locale) string { return pad2(t.Year() % 100) },
+	"MMMM": func(t time.Time, l *locale.Locale) string { return l.MonthName(t.Month()) },
+	"MMM":  func(t time.Time, l *locale.Locale) string { return l.ShortMonthName(t.Month()) },
+	"MM":   func(t time.Time, l *locale.Locale) string { return pad2(int(t.Month())) },
+	"M":    func(t time.Time, l *locale.Locale) string { return strconv.Itoa(int(t.Month())) },
+	"DDDD": func(t time.Time, l *locale.Locale) string { return pad3(t.YearDay()) },
+	"DDD":  func(t time.Time, l *locale.Locale) string { return strconv.Itoa(t.YearDay()) },
+	"DD":   func(t time.Time, l *locale.Locale) string { return pad2(t.Day()) },
+	"Do":   func(t time.Time, l *locale.Locale) string { return l.OrdinalDay(t.Day()) },
+	"D":    func(t time.Time, l *locale.Locale) string { return strconv.Itoa(t.Day()) },
+	"dddd": func(t time.Time, l *locale.Locale) string { return l.DayName(t.Weekday()) },
+	"ddd":  func(t time.Time, l *locale.Locale) string { return l.ShortDayName(t.Weekday()) },
+	"dd":   func(t time.Time, l *locale.Locale) string { return l.MinDayName(t.Weekday()) },
+	"d":    func(t time.Time, l *locale.Locale) string { return strconv.Itoa(int(t.Weekday())) },
+	"E":    func(t time.Time, l *locale.Locale) string { return strconv.Itoa(isoWeekday(t)) },
+	"WW":   func(t time.Time, l *locale.Locale) string { return pad2(isoWeek(t)) },
+	"W":    func(t time.Time, l *locale.Locale) string { return strconv.Itoa(isoWeek(t)) },
+	"HH":   func(t time.Time, l *locale.Locale) string { return pad2(t.Hour()) },
+	"H":    func(t time.Time, l *locale.Locale) string { return strconv.Itoa(t.Hour()) },
+	"hh":   func(t time.Time, l *locale.Locale) string { return pad2(hour12(t)) },
+	"h":    func(t time.Time, l *locale.Locale) string { return strconv.Itoa(hour12(t)) },
+	"mm":   func(t time.Time, l *locale.Locale) string { return pad2(t.Minute()) },
+	"m":    func(t time.Time, l *locale.Locale) string { return strconv.Itoa(t.Minute()) },
+	"ss":   func(t time.Time, l *locale.Locale) string { return pad2(t.Second()) },
+	"s":    func(t time.Time, l *locale.Locale) string { return strconv.Itoa(t.Second()) },
+	"SSS":  func(t time.Time, l *locale.Locale) string { return pad3(t.Nanosecond() / int(time.Millisecond)) },
+	"A":    func(t time.Time, l *locale.Locale) string { return l.Meridiem(t.Hour()) },
+	"a":    func(t time.Time, l *locale.Locale) string { return strings.ToLower(l.Meridiem(t.Hour())) },
+	"ZZ":   func(t time.Time, l *locale.Locale) string { return t.Format("-0700") },
+	"Z":    func(t time.Time, l *locale.Locale) string { return t.Format("-07:00") },
+	"X":    func(t time.Time, l *locale.Locale) string { return strconv.FormatInt(t.Unix(), 10) },
+	"x":    func(t time.Time, l *locale.Locale) string { return strconv.FormatInt(t.UnixMilli(), 10) },
+})
+
+var icuDialect = newDateDialect(map[string]dateToken{
+	"yyyy": func(t time.Time, l *locale.Locale) string { return strconv.Itoa(t.Year()) },
+	"yy":   func(t time.Time, l *locale.Locale) string { return pad2(t.Year() % 100) },
+	"y":    func(t time.Time, l *locale.Locale) string { return strconv.Itoa(t.Year()) },
+	"MMMM": func(t time.Time, l *locale.Locale) string { return l.MonthName(t.Month()) },
+	"MMM":  func(t time.Time, l *locale.Locale) string { return l.ShortMonthName(t.Month()) },
+	"MM":   func(t time.Time, l *locale.Locale) string { return pad2(int(t.Month())) },
+	"M":    func(t time.Time, l *locale.Locale) string { return strconv.Itoa(int(t.Month())) },
+	"dd":   func(t time.Time, l *locale.Locale) string { return pad2(t.Day()) },
+	"d":    func(t time.Time, l *locale.Locale) string { return strconv.Itoa(t.Day()) },
+	"DDD":  func(t time.Time, l *locale.Locale) string { return pad3(t.YearDay()) },
+	"D":    func(t time.Time, l *locale.Locale) string { return strconv.Itoa(t.YearDay()) },
+	"EEEE": func(t time.Time, l *locale.Locale) string { return l.DayName(t.Weekday()) },
+	"EEE":  func(t time.Time, l *locale.Locale) string { return l.ShortDayName(t.Weekday()) },
+	"E":    func(t time.Time, l *locale.Locale) string { return l.ShortDayName(t.Weekday()) },
+	"ww":   func(t time.Time, l *locale.Locale) string { return pad2(isoWeek(t)) },
+	"w":    func(t time.Time, l *locale.Locale) string { return strconv.Itoa(isoWeek(t)) },
+	"HH":   func(t time.Time, l *locale.Locale) string { return pad2(t.Hour()) },
+	"H":    func(t time.Time, l *locale.Locale) string { return strconv.Itoa(t.Hour()) },
+	"hh":   func(t time.Time, l *locale.Locale) string { return pad2(hour12(t)) },
+	"h":    func(t time.Time, l *locale.Locale) string { return strconv.Itoa(hour12(t)) },
+	"mm":   func(t time.Time, l *locale.Locale) string { return pad2(t.Minute()) },
+	"m":    func(t time.Time, l *locale.Locale) string { return strconv.Itoa(t.Minute()) },
+	"ss":   func(t time.Time, l *locale.Locale) string { return pad2(t.Second()) },
+	"s":    func(t time.Time, l *locale.Locale) string { return strconv.Itoa(t.Second()) },
+	"SSS":  func(t time.Time, l *locale.Locale) string { return pad3(t.Nanosecond() / int(time.Millisecond)) },
+	"a":    func(t time.Time, l *locale.Locale) string { return l.Meridiem(t.Hour()) },
+	"XXX":  func(t time.Time, l *locale.Locale) string { return t.Format("Z07:00") },
+	"Z":    func(t time.Time, l *locale.Locale) string { return t.Format("-0700") },
+	"z":    func(t time.Time, l *locale.Locale) string { return t.Format("MST") },
+})
+
+var strftimeDirectives = map[byte]dateToken{
+	'Y': func(t time.Time, l *locale.Locale) string { return strconv.Itoa(t.Year()) },
+	'y': func(t time.Time, l *locale.Locale) string { return pad2(t.Year() % 100) },
+	'm': func(t time.Time, l *locale.Locale) string { return pad2(int(t.Month())) },
+	'B': func(t time.Time, l *locale.Locale) string { return l.MonthName(t.Month()) },
+	'b': func(t time.Time, l *locale.Locale) string { return l.ShortMonthName(t.Month()) },
+	'h': func(t time.Time, l *locale.Locale) string { return l.ShortMonthName(t.Month()) },
+	'd': func(t time.Time, l *locale.Locale) string { return pad2(t.Day()) },
+	'e': func(t time.Time, l *locale.Locale) string { return fmt.Sprintf("%2d", t.Day()) },
+	'j': func(t time.Time, l *locale.Locale) string { return pad3(t.YearDay()) },
+	'A': func(t time.Time, l *locale.Locale) string { return l.DayName(t.Weekday()) },
+	'a': func(t time.Time, l *locale.Locale) string { return l.ShortDayName(t.Weekday()) },
+	'u': func(t time.Time, l *locale.Locale) string { return strconv.Itoa(isoWeekday(t)) },
+	'w': func(t time.Time, l *locale.Locale) string { return strconv.Itoa(int(t.Weekday())) },
+	'V': func(t time.Time, l *locale.Locale) string { return pad2(isoWeek(t)) },
+	'H': func(t time.Time, l *locale.Locale) string { return pad2(t.Hour()) },
+	'I': func(t time.Time, l *locale.Locale) string { return pad2(hour12(t)) },
+	'M': func(t time.Time, l *locale.Locale) string { return pad2(t.Minute()) },
+	'S': func(t time.Time, l *locale.Locale) string { return pad2(t.Second()) },
+	'p': func(t time.Time, l *locale.Locale) string { return l.Meridiem(t.Hour()) },
+	'Z': func(t time.Time, l *locale.Locale) string { return t.Format("MST") },
+	'z': func(t time.Time, l *locale.Locale) string { return t.Format("-0700") },
+	's': func(t time.Time, l *locale.Locale) string { return strconv.FormatInt(t.Unix(), 10) },
+	'F': func(t time.Time, l *locale.Locale) string { return t.Format("2006-01-02") },
+	'T': func(t time.Time, l *locale.Locale) string { return t.Format("15:04:05") },
+	'R': func(t time.Time, l *locale.Locale) string { return t.Format("15:04") },
+	'n': func(t time.Time, l *locale.Locale) string { return "\n" },
+	't': func(t time.Time, l *locale.Locale) string { return "\t" },
+	'%': func(t time.Time, l *locale.Locale) string { return "%" },
+}
+
+var (
+	strftimePattern = regexp.MustCompile(`%[a-zA-Z%]`)
+	icuPattern      = regexp.MustCompile(`yy|EEE|'`)
+	momentPattern   = regexp.MustCompile(`YY|DD|Do|HH|hh|mm|ss|MMM|\[`)
+)
+
+// detectDateStyle guesses the dialect of a date format. Go reference
+// layouts always contain digits, while the token dialects use letters.
+func detectDateStyle(format string) string {
+	switch {
+	case strftimePattern.MatchString(format):
+		return DateStyleStrftime
+	case strings.ContainsAny(format, "0123456789"):
+		return DateStyleGo
+	case icuPattern.MatchString(format):
+		return DateStyleICU
+	case momentPattern.MatchString(format) || strings.Contains(format, "MM"):
+		return DateStyleMoment
+	}
+	return DateStyleGo
+}
+
+// formatTime formats t using a format in the given style, with month and
+// weekday names from l. An empty style or DateStyleAuto detects the style
+// from the format.
+func formatTime(t time.Time, format, style string, l *locale.Locale) (string, error) {
+	if style == "" || style == DateStyleAuto {
+		style = detectDateStyle(format)
+	}
+
+	switch style {
+	case DateStyleGo:
+		return formatGo(t, format, l), nil
+	case DateStyleStrftime:
+		return formatStrftime(t, format, l), nil
+	case DateStyleMoment:
+		return formatTokens(t, format, l, momentDialect, '[', ']'), nil
+	case DateStyleICU:
+		return formatTokens(t, format, l, icuDialect, '\'', '\''), nil
+	}
+
+	return "", fmt.Errorf("unknown date format style %q (want %s, %s, %s, %s or %s)",
+		style, DateStyleAuto, DateStyleGo, DateStyleMoment, DateStyleStrftime, DateStyleICU)
+}
+
+// goNameTokens are the name elements of Go reference layouts, longest first
+var goNameTokens = []string{"January", "Monday", "Jan", "Mon", "PM", "pm"}
+
+// formatGo formats t with a Go reference layout. Go only knows English
+// names, so for other locales the name elements are rendered separately.
+func formatGo(t time.Time, layout string, l *locale.Locale) string {
+	if l.Tag == locale.DefaultTag {
+		return t.Format(layout)
+	}
+
+	var buf strings.Builder
+	start := 0
+	for i := 0; i < len(layout); {
+		name := ""
+		for _, token := range goNameTokens {
+			if strings.HasPrefix(layout[i:], token) {
+				name = token
+				break
+			}
+		}
+		if name == "" {
+			i++
+			continue
+		}
+
+		buf.WriteString(t.Format(layout[start:i]))
+		switch name {
+		case "January":
+			buf.WriteString(l.MonthName(t.Month()))
+		case "Jan":
+			buf.WriteString(l.ShortMonthName(t.Month()))
+		case "Monday":
+			buf.WriteString(l.DayName(t.Weekday()))
+		case "Mon":
+			buf.WriteString(l.ShortDayName(t.Weekday()))
+		case "PM":
+			buf.WriteString(l.Meridiem(t.Hour()))
+		case "pm":
+			buf.WriteString(strings.ToLower(l.Meridiem(t.Hour())))
+		}
+		i += len(name)
+		start = i
+	}
+	buf.WriteString(t.Format(layout[start:]))
+
+	return buf.String()
+}
+
+// formatTokens formats t with a token dialect; text between the open and
+// close runes is copied literally (a doubled quote in ICU is a quote)
+func formatTokens(t time.Time, format string, l *locale.Locale, dialect *dateDialect, open, close byte) string {
+	var buf strings.Builder
+
+	for i := 0; i < len(format); {
+		if open == close && strings.HasPrefix(format[i:], string([]byte{open, open})) {
+			buf.WriteByte(open)
+			i += 2
+			continue
+		}
+		if format[i] == open {
+			i = copyLiteral(&buf, format, i+1, close, open == close)
+			continue
+		}
+
+		matched := false
+		for _, token := range dialect.order {
+			if strings.HasPrefix(format[i:], token) {
+				buf.WriteString(dialect.tokens[token](t, l))
+				i += len(token)
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			buf.WriteByte(format[i])
+			i++
+		}
+	}
+
+	return buf.String()
+}
+
+// copyLiteral copies literal text starting at i up to the close byte and
+// returns the index after it. With doubled set, two close bytes in a row
+// stand for one literal close byte (ICU's ” escape).
+func copyLiteral(buf *strings.Builder, format string, i int, close byte, doubled bool) int {
+	for i < len(format) {
+		if format[i] != close {
+			buf.WriteByte(format[i])
+			i++
+			continue
+		}
+		if doubled && i+1 < len(format) && format[i+1] == close {
+			buf.WriteByte(close)
+			i += 2
+			continue
+		}
+		return i + 1
+	}
+	return i
+}
+
+// formatStrftime formats t with strftime directives; unknown directives
+// are copied literally
+func formatStrftime(t time.Time, format string, l *locale.Locale) string {
+	var buf strings.Builder
+
+	for i := 0; i < len(format); i++ {
+		if format[i] == '%' && i+1 < len(format) {
+			if directive, ok := strftimeDirectives[format[i+1]]; ok {
+				buf.WriteString(directive(t, l))
+				i++
+				continue
+			}
+		}
+		buf.WriteByte(format[i])
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,140 @@
+package template
+
+import (
+	"testing"
+	"time"
+
+	"github.com/snipq/core/pkg/locale"
+)
+
+func TestDetectDateStyle(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"2006-01-02", DateStyleGo},
+		{"Mon, 02 Jan 2006", DateStyleGo},
+		{"Monday", DateStyleGo},
+		{"YYYY-MM-DD", DateStyleMoment},
+		{"DD/MM/YYYY HH:mm", DateStyleMoment},
+		{"[Week] W", DateStyleMoment},
+		{"MMMM Do", DateStyleMoment},
+		{"dd/MM/yyyy", DateStyleICU},
+		{"EEEE, MMMM d", DateStyleICU},
+		{"%Y-%m-%d", DateStyleStrftime},
+		{"%A %e %B", DateStyleStrftime},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			if got := detectDateStyle(tt.format); got != tt.want {
+				t.Errorf("detectDateStyle(%q) = %v, want %v", tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	// Sunday, 2 March 2025, 09:05:07.042 in UTC+7
+	ts := time.Date(2025, 3, 2, 9, 5, 7, 42*int(time.Millisecond), time.FixedZone("ICT", 7*60*60))
+	en, _ := locale.Lookup("en-US")
+
+	tests := []struct {
+		name    string
+		format  string
+		style   string
+		want    string
+		wantErr bool
+	}{
+		{"go layout", "2006-01-02 15:04", "", "2025-03-02 09:05", false},
+		{"moment date", "YYYY-MM-DD", "", "2025-03-02", false},
+		{"moment short", "D/M/YY", DateStyleMoment, "2/3/25", false},
+		{"moment names", "dddd, MMMM Do YYYY", "", "Sunday, March 2nd 2025", false},
+		{"moment abbreviations", "ddd MMM DD", "", "Sun Mar 02", false},
+		{"moment 12h clock", "hh:mm:ss A", "", "09:05:07 AM", false},
+		{"moment millis and zone", "HH:mm:ss.SSS Z", "", "09:05:07.042 +07:00", false},
+		{"moment escaped text", "[Today is] dddd", "", "Today is Sunday", false},
+		{"moment iso week", "[W]WW-E", DateStyleMoment, "W09-7", false},
+		{"moment day of year", "DDDD", DateStyleMoment, "061", false},
+		{"icu date", "dd/MM/yyyy", "", "02/03/2025", false},
+		{"icu names", "EEEE, MMMM d, yyyy", "", "Sunday, March 2, 2025", false},
+		{"icu quoted text", "h 'o''clock' a", DateStyleICU, "9 o'clock AM", false},
+		{"icu bare quote", "HH''mm", DateStyleICU, "09'05", false},
+		{"strftime date", "%Y-%m-%d", "", "2025-03-02", false},
+		{"strftime names", "%A %e %B", "", "Sunday  2 March", false},
+		{"strftime time", "%I:%M %p %z", "", "09:05 AM +0700", false},
+		{"strftime composite", "%F %T", "", "2025-03-02 09:05:07", false},
+		{"strftime percent", "100%% on %d", "", "100% on 02", false},
+		{"explicit style beats detection", "MM/dd", DateStyleICU, "03/02", false},
+		{"explicit moment dd is weekday", "MM/dd", DateStyleMoment, "03/Su", false},
+		{"explicit go", "YYYY", DateStyleGo, "YYYY", false},
+		{"unknown style", "YYYY", "cobol", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatTime(ts, tt.format, tt.style, en)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("formatTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("formatTime(%q, %q) = %q, want %q", tt.format, tt.style, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_RenderDateStyle(t *testing.T) {
+	engine := NewEngine()
+	now := time.Date(2025, 3, 2, 9, 5, 0, 0, time.UTC)
+
+	got, err := engine.RenderContext(
+		"{{ date .format \"UTC\" }} / {{ now | addDays 1 | formatDate .format }}",
+		map[string]any{"format": "MM/dd"},
+		Context{Now: now, DateStyle: DateStyleICU},
+	)
+	if err != nil {
+		t.Fatalf("Engine.RenderContext() error = %v", err)
+	}
+	if want := "03/02 / 03/03"; got != want {
+		t.Errorf("Engine.RenderContext() = %q, want %q", got, want)
+	}
+
+	if _, err := engine.RenderContext("{{ date \"x\" \"UTC\" }}", nil, Context{DateStyle: "bogus"}); err == nil {
+		t.Error("Engine.RenderContext() should fail for an unknown date style")
+	}
+}
+
+func TestFormatTimeLocalized(t *testing.T) {
+	// Sunday, 2 March 2025, 15:05
+	ts := time.Date(2025, 3, 2, 15, 5, 0, 0, time.UTC)
+
+	tests := []struct {
+		locale string
+		format string
+		want   string
+	}{
+		{"vi-VN", "dddd, D MMMM YYYY", "Chủ Nhật, 2 tháng 3 2025"},
+		{"vi-VN", "Monday, 02 January 2006 3:04 PM", "Chủ Nhật, 02 tháng 3 2025 3:05 CH"},
+		{"ja-JP", "yyyy年M月d日 EEEE a h時", "2025年3月2日 日曜日 午後 3時"},
+		{"fr-FR", "%A %e %B %Y", "dimanche  2 mars 2025"},
+		{"fr", "Mon 2 Jan", "dim. 2 mars"},
+		{"de-DE", "dddd, Do MMMM", "Sonntag, 2. März"},
+		{"de-AT", "EEE, d. MMM yyyy", "So., 2. März 2025"},
+		{"en-GB", "dddd Do MMMM", "Sunday 2nd March"},
+		{"xx-YY", "dddd Do MMMM", "Sunday 2nd March"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale+" "+tt.format, func(t *testing.T) {
+			loc, _ := locale.Lookup(tt.locale)
+			got, err := formatTime(ts, tt.format, "", loc)
+			if err != nil {
+				t.Fatalf("formatTime() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("formatTime() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package template
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snipq/core/pkg/locale"
+)
+
+// Date arithmetic functions take the time as their last argument so they
+// chain in pipelines:
+//
+//	{{ now .tz | addBusinessDays .days | formatDate "Mon, 02 Jan 2006" }}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// nowFunc returns the render time, optionally converted to a timezone
+func nowFunc(now time.Time) func(timezone ...string) time.Time {
+	return func(timezone ...string) time.Time {
+		if len(timezone) == 0 {
+			return now
+		}
+		return now.In(resolveLocation(timezone[0], now))
+	}
+}
+
+// formatDateFunc formats a time with a format in the render's date style and locale
+func formatDateFunc(style string, loc *locale.Locale) func(format string, t time.Time) (string, error) {
+	return func(format string, t time.Time) (string, error) {
+		return formatTime(t, format, style, loc)
+	}
+}
+
+// addDays adds n calendar days
+func addDays(n any, t time.Time) (time.Time, error) {
+	days, err := toInt(n)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("addDays: %w", err)
+	}
+	return t.AddDate(0, 0, days), nil
+}
+
+// addWeeks adds n weeks
+func addWeeks(n any, t time.Time) (time.Time, error) {
+	weeks, err := toInt(n)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("addWeeks: %w", err)
+	}
+	return t.AddDate(0, 0, 7*weeks), nil
+}
+
+// addMonths adds n months, clamping to the last day of the target month
+// so Jan 31 + 1 month is Feb 28/29 rather than early March
+func addMonths(n any, t time.Time) (time.Time, error) {
+	months, err := toInt(n)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("addMonths: %w", err)
+	}
+	return addMonthsClamped(t, months), nil
+}
+
+// addYears adds n years, clamping Feb 29 to Feb 28 in non-leap years
+func addYears(n any, t time.Time) (time.Time, error) {
+	years, err := toInt(n)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("addYears: %w", err)
+	}
+	return addMonthsClamped(t, 12*years), nil
+}
+
+func addMonthsClamped(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	day := t.Day()
+	if last := daysIn(first); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// addDuration adds a duration such as "90m", "1h30m", "3d" or "-2w"
+func addDuration(d string, t time.Time) (time.Time, error) {
+	duration, err := parseDuration(d)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("addDuration: %w", err)
+	}
+	return t.Add(duration), nil
+}
+
+// parseDuration extends time.ParseDuration with day (d) and week (w) units
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	unit := time.Duration(0)
+	switch s[len(s)-1] {
+	case 'd':
+		unit = 24 * time.Hour
+	case 'w':
+		unit = 7 * 24 * time.Hour
+	default:
+		return time.ParseDuration(s)
+	}
+
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// maxBusinessDays bounds the count addBusinessDays accepts, about 3800
+// years either way
+const maxBusinessDays = 1000000
+
+// addBusinessDays moves n working days forward (or backward when negative),
+// skipping Saturdays and Sundays
+func addBusinessDays(n any, t time.Time) (time.Time, error) {
+	days, err := toInt(n)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("addBusinessDays: %w", err)
+	}
+	if days > maxBusinessDays || days < -maxBusinessDays {
+		return time.Time{}, fmt.Errorf("addBusinessDays: %d days is out of range (at most %d)", days, maxBusinessDays)
+	}
+
+	step := 1
+	if days < 0 {
+		step, days = -1, -days
+	}
+
+	// Any seven days in a row hold five working days. The last few are
+	// counted one at a time so the result never falls on a weekend.
+	if weeks := (days - 1) / 5; weeks > 0 {
+		t = t.AddDate(0, 0, step*7*weeks)
+		days -= 5 * weeks
+	}
+
+	for days > 0 {
+		t = t.AddDate(0, 0, step)
+		if t.Weekday() != time.Saturday && t.Weekday() != time.Sunday {
+			days--
+		}
+	}
+	return t, nil
+}
+
+// nextWeekday returns the next given weekday strictly after t
+func nextWeekday(day string, t time.Time) (time.Time, error) {
+	weekday, err := parseWeekday(day)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("nextWeekday: %w", err)
+	}
+
+	diff := (int(weekday) - int(t.Weekday()) + 7) % 7
+	if diff == 0 {
+		diff = 7
+	}
+	return t.AddDate(0, 0, diff), nil
+}
+
+// parseWeekday accepts full or three-letter weekday names in any case
+func parseWeekday(day string) (time.Weekday, error) {
+	day = strings.ToLower(strings.TrimSpace(day))
+	for name, weekday := range weekdays {
+		if day == name || (len(day) == 3 && strings.HasPrefix(name, day)) {
+			return weekday, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %q", day)
+}
+
+// startOfDay returns midnight at the start of t's day
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// endOfDay returns the last nanosecond of t's day
+func endOfDay(t time.Time) time.Time {
+	return startOfDay(t).AddDate(0, 0, 1).Add(-time.Nanosecond)
+}
+
+// startOfWeek returns the start of the ISO week (Monday) containing t
+func startOfWeek(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return startOfDay(t).AddDate(0, 0, -offset)
+}
+
+// endOfWeek returns the end of the ISO week (Sunday) containing t
+func endOfWeek(t time.Time) time.Time {
+	return startOfWeek(t).AddDate(0, 0, 7).Add(-time.Nanosecond)
+}
+
+// startOfMonth returns midnight on the first day of t's month
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// endOfMonth returns the last nanosecond of t's month
+func endOfMonth(t time.Time) time.Time {
+	return startOfMonth(t).AddDate(0, 1, 0).Add(-time.Nanosecond)
+}
+
+// isoWeek returns the ISO 8601 week number of t
+func isoWeek(t time.Time) int {
+	_, week := t.ISOWeek()
+	return week
+}
+
+// daysIn returns the number of days in t's month
+func daysIn(t time.Time) int {
+	return endOfMonth(t).Day()
+}
@@ -0,0 +1,163 @@
+package template
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEngine_RenderDateArithmetic(t *testing.T) {
+	engine := NewEngine()
+	// Friday, 31 January 2025, 15:04 in UTC+7
+	now := time.Date(2025, 1, 31, 15, 4, 0, 0, time.FixedZone("ICT", 7*60*60))
+
+	tests := []struct {
+		name     string
+		template string
+		data     map[string]any
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "now",
+			template: "{{ now | formatDate \"2006-01-02 15:04\" }}",
+			want:     "2025-01-31 15:04",
+		},
+		{
+			name:     "now in timezone",
+			template: "{{ now \"UTC\" | formatDate \"2006-01-02 15:04\" }}",
+			want:     "2025-01-31 08:04",
+		},
+		{
+			name:     "add days",
+			template: "{{ now | addDays 3 | formatDate \"2006-01-02\" }}",
+			want:     "2025-02-03",
+		},
+		{
+			name:     "subtract days from query string",
+			template: "{{ now | addDays .days | formatDate \"2006-01-02\" }}",
+			data:     map[string]any{"days": "-31"},
+			want:     "2024-12-31",
+		},
+		{
+			name:     "plus sign decoded as space",
+			template: "{{ now | addDays .days | formatDate \"2006-01-02\" }}",
+			data:     map[string]any{"days": " 5"},
+			want:     "2025-02-05",
+		},
+		{
+			name:     "add weeks",
+			template: "{{ now | addWeeks 2 | formatDate \"2006-01-02\" }}",
+			want:     "2025-02-14",
+		},
+		{
+			name:     "add months clamps to month end",
+			template: "{{ now | addMonths 1 | formatDate \"2006-01-02\" }}",
+			want:     "2025-02-28",
+		},
+		{
+			name:     "add months across year",
+			template: "{{ now | addMonths -2 | formatDate \"2006-01-02\" }}",
+			want:     "2024-11-30",
+		},
+		{
+			name:     "add years",
+			template: "{{ now | addYears 1 | formatDate \"2006-01-02\" }}",
+			want:     "2026-01-31",
+		},
+		{
+			name:     "add duration",
+			template: "{{ now | addDuration \"1h30m\" | formatDate \"15:04\" }}",
+			want:     "16:34",
+		},
+		{
+			name:     "add duration in days",
+			template: "{{ now | addDuration \"-2d\" | formatDate \"2006-01-02\" }}",
+			want:     "2025-01-29",
+		},
+		{
+			name:     "business days skip weekend",
+			template: "{{ now | addBusinessDays 3 | formatDate \"Mon 2006-01-02\" }}",
+			want:     "Wed 2025-02-05",
+		},
+		{
+			name:     "business days backwards",
+			template: "{{ now | addBusinessDays -5 | formatDate \"Mon 2006-01-02\" }}",
+			want:     "Fri 2025-01-24",
+		},
+		{
+			name:     "business days from a saturday",
+			template: "{{ now | addDays 1 | addBusinessDays 10 | formatDate \"Mon 2006-01-02\" }}",
+			want:     "Fri 2025-02-14",
+		},
+		{
+			name:     "business days over whole weeks",
+			template: "{{ now | addBusinessDays .days | formatDate \"Mon 2006-01-02\" }}",
+			data:     map[string]any{"days": 261},
+			want:     "Mon 2026-02-02",
+		},
+		{
+			name:     "business days out of range",
+			template: "{{ now | addBusinessDays .days }}",
+			data:     map[string]any{"days": "300000000"},
+			wantErr:  true,
+		},
+		{
+			name:     "next monday",
+			template: "{{ now | nextWeekday \"Monday\" | formatDate \"Mon 2006-01-02\" }}",
+			want:     "Mon 2025-02-03",
+		},
+		{
+			name:     "next friday is a week away on a friday",
+			template: "{{ now | nextWeekday \"fri\" | formatDate \"Mon 2006-01-02\" }}",
+			want:     "Fri 2025-02-07",
+		},
+		{
+			name:     "start and end of week",
+			template: "{{ now | startOfWeek | formatDate \"Mon 01-02 15:04\" }} - {{ now | endOfWeek | formatDate \"Mon 01-02 15:04\" }}",
+			want:     "Mon 01-27 00:00 - Sun 02-02 23:59",
+		},
+		{
+			name:     "start and end of month",
+			template: "{{ now | addDays 1 | startOfMonth | formatDate \"2006-01-02\" }} - {{ now | addDays 1 | endOfMonth | formatDate \"2006-01-02\" }}",
+			want:     "2025-02-01 - 2025-02-28",
+		},
+		{
+			name:     "start and end of day",
+			template: "{{ now | startOfDay | formatDate \"15:04\" }}-{{ now | endOfDay | formatDate \"15:04:05\" }}",
+			want:     "00:00-23:59:59",
+		},
+		{
+			name:     "iso week",
+			template: "W{{ isoWeek now }}",
+			want:     "W5",
+		},
+		{
+			name:     "unknown weekday",
+			template: "{{ now | nextWeekday \"someday\" }}",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid duration",
+			template: "{{ now | addDuration \"soon\" }}",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid day count",
+			template: "{{ now | addDays .days }}",
+			data:     map[string]any{"days": "many"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.RenderContext(tt.template, tt.data, Context{Now: now})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Engine.RenderContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("Engine.RenderContext() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,11 +4,15 @@ import (
 	"crypto/rand"
 	"fmt"
 	"math/big"
+	"reflect"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/snipq/core/pkg/locale"
 )
 
 // Engine handles template rendering with built-in functions
@@ -16,24 +20,69 @@ type Engine struct {
 	template *template.Template
 }
 
+// CounterSource hands out counter values while a template renders
+type CounterSource interface {
+	Next(name string) (int, error)
+}
+
+// ClipboardSource reads the host clipboard while a template renders
+type ClipboardSource interface {
+	ReadText() (string, error)
+}
+
+// SnippetSource renders other vault snippets for the snippet function.
+// args are "key=value" query fragments applied to the included snippet.
+type SnippetSource interface {
+	IncludeSnippet(ref string, args []string) (string, error)
+}
+
+// Context carries the per-render state used by stateful built-in functions
+// A zero Now renders against the wall clock. DateStyle selects the dialect
+// of date formats (see DateStyleAuto and friends); empty means auto-detect.
+// Locale is a tag such as "vi-VN" used for names and number formatting.
+type Context struct {
+	Now       time.Time
+	DateStyle string
+	Locale    string
+	Counters  CounterSource
+	Clipboard ClipboardSource
+	Snippets  SnippetSource
+}
+
 // NewEngine creates a new template engine with built-in functions
 func NewEngine() *Engine {
 	tmpl := template.New("snipq").Funcs(template.FuncMap{
-		"date":      dateFunc,
-		"uuid":      uuidFunc,
-		"counter":   counterFunc,
-		"clipboard": clipboardFunc,
-		"random":    randomFunc,
-		"upper":     strings.ToUpper,
-		"lower":     strings.ToLower,
-		"title":     titleFunc,
-		"trim":      strings.TrimSpace,
-		"eq":        equal,
-		"ne":        notEqual,
-		"lt":        lessThan,
-		"le":        lessEqual,
-		"gt":        greaterThan,
-		"ge":        greaterEqual,
+		"uuid":    uuidFunc,
+		"default": defaultFunc,
+		"random":  randomFunc,
+		"cursor":  cursorFunc,
+		"tabstop": tabstopFunc,
+
+		"addDays":         addDays,
+		"addWeeks":        addWeeks,
+		"addMonths":       addMonths,
+		"addYears":        addYears,
+		"addDuration":     addDuration,
+		"addBusinessDays": addBusinessDays,
+		"nextWeekday":     nextWeekday,
+		"startOfDay":      startOfDay,
+		"endOfDay":        endOfDay,
+		"startOfWeek":     startOfWeek,
+		"endOfWeek":       endOfWeek,
+		"startOfMonth":    startOfMonth,
+		"endOfMonth":      endOfMonth,
+		"isoWeek":         isoWeek,
+
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"title": titleFunc,
+		"trim":  strings.TrimSpace,
+		"eq":    equal,
+		"ne":    notEqual,
+		"lt":    lessThan,
+		"le":    lessEqual,
+		"gt":    greaterThan,
+		"ge":    greaterEqual,
 	})
 
 	return &Engine{
@@ -43,12 +92,44 @@ func NewEngine() *Engine {
 
 // Render renders a template with the given data
 func (e *Engine) Render(templateText string, data map[string]any) (string, error) {
+	return e.RenderContext(templateText, data, Context{})
+}
+
+// RenderContext renders a template with the given data and per-render context
+func (e *Engine) RenderContext(templateText string, data map[string]any, ctx Context) (string, error) {
+	result, err := e.RenderResult(templateText, data, ctx)
+	if err != nil {
+		return "", err
+	}
+	return result.Text, nil
+}
+
+// RenderResult renders a template and reports where its cursor and tab stop
+// markers ended up in the output
+func (e *Engine) RenderResult(templateText string, data map[string]any, ctx Context) (*Result, error) {
+	output, err := e.RenderMarked(templateText, data, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := extractMarkers(output)
+	return &result, nil
+}
+
+// RenderMarked renders a template leaving cursor and tab stop markers in
+// the output. It is meant for output embedded in another render, such as
+// an included snippet, so its markers are located by the outer render.
+func (e *Engine) RenderMarked(templateText string, data map[string]any, ctx Context) (string, error) {
+	if ctx.Now.IsZero() {
+		ctx.Now = time.Now()
+	}
+
 	tmpl, err := e.template.Clone()
 	if err != nil {
 		return "", err
 	}
 
-	tmpl, err = tmpl.Parse(templateText)
+	tmpl, err = tmpl.Funcs(contextFuncs(ctx)).Parse(expandMarkerTokens(templateText))
 	if err != nil {
 		return "", fmt.Errorf("template parse error: %w", err)
 	}
@@ -64,24 +145,29 @@ func (e *Engine) Render(templateText string, data map[string]any) (string, error
 
 // Built-in template functions
 
-// dateFunc formats the current date/time
-func dateFunc(format, timezone string) string {
-	var loc *time.Location
-	var err error
+// dateFunc formats the render time in the given timezone
+func dateFunc(now time.Time, style string, loc *locale.Locale) func(format, timezone string) (string, error) {
+	return func(format, timezone string) (string, error) {
+		return formatTime(now.In(resolveLocation(timezone, now)), format, style, loc)
+	}
+}
 
+// resolveLocation maps a timezone name to a location. "Local", an empty
+// name and unknown names resolve to the location of the render time, so
+// hosts control "local" time through the Now they inject.
+func resolveLocation(timezone string, now time.Time) *time.Location {
 	switch timezone {
 	case "Local", "":
-		loc = time.Local
+		return now.Location()
 	case "UTC":
-		loc = time.UTC
-	default:
-		loc, err = time.LoadLocation(timezone)
-		if err != nil {
-			loc = time.Local
-		}
+		return time.UTC
 	}
 
-	return time.Now().In(loc).Format(format)
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return now.Location()
+	}
+	return loc
 }
 
 // uuidFunc generates a UUID
@@ -93,17 +179,94 @@ func uuidFunc(withHyphens bool) string {
 	return strings.ReplaceAll(id.String(), "-", "")
 }
 
-// counterFunc increments and returns a counter value
-// This is a placeholder - actual implementation needs counter state management
-func counterFunc(name string, pad int) string {
-	// TODO: Implement actual counter management
-	// For now, return a placeholder
-	return fmt.Sprintf("%0*d", pad, 1)
+// contextFuncs binds the built-in functions that depend on per-render state
+func contextFuncs(ctx Context) template.FuncMap {
+	loc, _ := locale.Lookup(ctx.Locale)
+
+	return template.FuncMap{
+		"date":           dateFunc(ctx.Now, ctx.DateStyle, loc),
+		"now":            nowFunc(ctx.Now),
+		"formatDate":     formatDateFunc(ctx.DateStyle, loc),
+		"formatNumber":   formatNumberFunc(loc),
+		"formatCurrency": formatCurrencyFunc(loc),
+		"formatPercent":  formatPercentFunc(loc),
+		"counter":        counterFunc(ctx.Counters),
+		"clipboard":      clipboardFunc(ctx.Clipboard),
+		"snippet":        snippetFunc(ctx.Snippets),
+	}
 }
 
-// clipboardFunc placeholder for clipboard content
-func clipboardFunc() string {
-	return ""
+// counterFunc returns the next value of a named counter, optionally zero-padded
+func counterFunc(counters CounterSource) func(name string, pad ...any) (string, error) {
+	return func(name string, pad ...any) (string, error) {
+		if counters == nil {
+			return "", fmt.Errorf("counter %q: no counter source available", name)
+		}
+
+		width := 0
+		if len(pad) > 0 {
+			var err error
+			width, err = toInt(pad[0])
+			if err != nil {
+				return "", fmt.Errorf("counter %q: invalid pad: %w", name, err)
+			}
+		}
+
+		value, err := counters.Next(name)
+		if err != nil {
+			return "", fmt.Errorf("counter %q: %w", name, err)
+		}
+
+		return fmt.Sprintf("%0*d", width, value), nil
+	}
+}
+
+// clipboardFunc returns the clipboard text, read at most once per render.
+// A missing or failing clipboard renders as an empty string so templates
+// can fall back with `default`.
+func clipboardFunc(clipboard ClipboardSource) func() string {
+	var (
+		read bool
+		text string
+	)
+	return func() string {
+		if clipboard == nil {
+			return ""
+		}
+		if !read {
+			read = true
+			value, err := clipboard.ReadText()
+			if err == nil {
+				text = value
+			}
+		}
+		return text
+	}
+}
+
+// snippetFunc renders another snippet by trigger or ID:
+// {{ snippet ":sig" "lang=vi" }}
+func snippetFunc(snippets SnippetSource) func(ref string, args ...string) (string, error) {
+	return func(ref string, args ...string) (string, error) {
+		if snippets == nil {
+			return "", fmt.Errorf("snippet %q: no snippet source available", ref)
+		}
+		return snippets.IncludeSnippet(ref, args)
+	}
+}
+
+// defaultFunc returns value, or def when value is nil or an empty string.
+// Designed for pipelines: {{ clipboard | trim | default "n/a" }}
+func defaultFunc(def, value any) any {
+	switch v := value.(type) {
+	case nil:
+		return def
+	case string:
+		if v == "" {
+			return def
+		}
+	}
+	return value
 }
 
 // titleFunc converts string to title case (replacement for deprecated strings.Title)
@@ -148,12 +311,16 @@ func randomFunc(args ...any) string {
 
 // Comparison functions for templates
 
+// equal compares numbers by value, so an int param equals a float literal
 func equal(a, b any) bool {
-	return a == b
+	if x, y, ok := numberPair(a, b); ok {
+		return x == y
+	}
+	return reflect.DeepEqual(a, b)
 }
 
 func notEqual(a, b any) bool {
-	return a != b
+	return !equal(a, b)
 }
 
 func lessThan(a, b any) bool {
@@ -172,9 +339,104 @@ func greaterEqual(a, b any) bool {
 	return compareValues(a, b) >= 0
 }
 
+// toFloat converts numeric template arguments (including numeric strings) to float64
+func toFloat(v any) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case int32:
+		return float64(n), nil
+	case time.Duration:
+		return float64(n), nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(n), 64)
+	case bool:
+		if n {
+			return 1, nil
+		}
+		return 0, nil
+	case nil:
+		return 0, nil
+	}
+	return 0, fmt.Errorf("cannot convert %T to number", v)
+}
+
+// toInt converts numeric template arguments (including numeric strings) to int
+func toInt(v any) (int, error) {
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case int64:
+		return int(n), nil
+	case float64:
+		return int(n), nil
+	case string:
+		return strconv.Atoi(strings.TrimSpace(n))
+	case nil:
+		return 0, nil
+	}
+	return 0, fmt.Errorf("cannot convert %T to int", v)
+}
+
+// compareValues orders times chronologically, numbers (including numeric
+// strings compared with each other) by value and anything else as strings
 func compareValues(a, b any) int {
-	// Simple string comparison for now
-	aStr := fmt.Sprintf("%v", a)
-	bStr := fmt.Sprintf("%v", b)
-	return strings.Compare(aStr, bStr)
+	if x, ok := a.(time.Time); ok {
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y)
+		}
+	}
+
+	x, y, ok := numberPair(a, b)
+	if !ok {
+		x, y, ok = numericStrings(a, b)
+	}
+	if ok {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+// numberPair converts a and b to float64 when both are numeric types
+func numberPair(a, b any) (float64, float64, bool) {
+	if !isNumber(a) || !isNumber(b) {
+		return 0, 0, false
+	}
+	x, errA := toFloat(a)
+	y, errB := toFloat(b)
+	return x, y, errA == nil && errB == nil
+}
+
+// numericStrings converts a and b to float64 when each is a number or a
+// numeric string, so "10" sorts after "9" and after 9
+func numericStrings(a, b any) (float64, float64, bool) {
+	_, aStr := a.(string)
+	_, bStr := b.(string)
+	if (!aStr && !isNumber(a)) || (!bStr && !isNumber(b)) {
+		return 0, 0, false
+	}
+	x, errA := toFloat(a)
+	y, errB := toFloat(b)
+	return x, y, errA == nil && errB == nil
+}
+
+func isNumber(v any) bool {
+	switch v.(type) {
+	case int, int32, int64, float32, float64, time.Duration:
+		return true
+	}
+	return false
 }
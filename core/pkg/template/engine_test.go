@@ -1,8 +1,10 @@
 package template
 
 import (
+	"errors"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestEngine_Render(t *testing.T) {
@@ -161,3 +163,237 @@ func TestBuiltinFunctions(t *testing.T) {
 		})
 	}
 }
+
+func TestEngine_RenderComparisons(t *testing.T) {
+	day := time.Date(2025, 8, 28, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		template string
+		data     map[string]any
+		want     string
+	}{
+		{"int against literal", `{{ lt .n 9 }}`, map[string]any{"n": 10}, "false"},
+		{"numeric strings", `{{ lt .n "9" }}`, map[string]any{"n": "10"}, "false"},
+		{"number against numeric string", `{{ gt .n "9" }}`, map[string]any{"n": 10}, "true"},
+		{"float against int", `{{ ge .n 2 }}`, map[string]any{"n": 2.0}, "true"},
+		{"eq across number types", `{{ eq .n 2 }}`, map[string]any{"n": 2.0}, "true"},
+		{"ne across number types", `{{ ne .n 2 }}`, map[string]any{"n": int64(3)}, "true"},
+		{"strings", `{{ lt .a "b" }}`, map[string]any{"a": "a"}, "true"},
+		{"word against number", `{{ lt .a 5 }}`, map[string]any{"a": "abc"}, "false"},
+		{"durations", `{{ gt .a .b }}`, map[string]any{"a": time.Hour, "b": 90 * time.Minute}, "false"},
+		{"times", `{{ le .a .b }}`, map[string]any{"a": day, "b": day.AddDate(0, 0, 1)}, "true"},
+		{"eq on JSON values", `{{ eq .a .b }}`, map[string]any{"a": []any{"x"}, "b": []any{"x"}}, "true"},
+	}
+
+	engine := NewEngine()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.Render(tt.template, tt.data)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeCounters is an in-memory CounterSource for tests
+type fakeCounters map[string]int
+
+func (f fakeCounters) Next(name string) (int, error) {
+	f[name]++
+	return f[name], nil
+}
+
+func TestEngine_RenderContextCounter(t *testing.T) {
+	engine := NewEngine()
+
+	tests := []struct {
+		name     string
+		template string
+		data     map[string]any
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "unpadded counter",
+			template: "#{{ counter \"inv\" }}",
+			want:     "#1",
+		},
+		{
+			name:     "int pad",
+			template: "#{{ counter \"inv\" .pad }}",
+			data:     map[string]any{"pad": 5},
+			want:     "#00001",
+		},
+		{
+			name:     "string pad from query",
+			template: "#{{ counter \"inv\" .pad }}",
+			data:     map[string]any{"pad": "3"},
+			want:     "#001",
+		},
+		{
+			name:     "invalid pad",
+			template: "#{{ counter \"inv\" .pad }}",
+			data:     map[string]any{"pad": "wide"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := Context{Counters: fakeCounters{}}
+			got, err := engine.RenderContext(tt.template, tt.data, ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Engine.RenderContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Engine.RenderContext() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_RenderCounterWithoutSource(t *testing.T) {
+	engine := NewEngine()
+
+	if _, err := engine.Render("{{ counter \"inv\" }}", map[string]any{}); err == nil {
+		t.Error("Engine.Render() should fail when no counter source is available")
+	}
+}
+
+// fakeClipboard is a ClipboardSource that counts reads
+type fakeClipboard struct {
+	text  string
+	err   error
+	reads int
+}
+
+func (f *fakeClipboard) ReadText() (string, error) {
+	f.reads++
+	return f.text, f.err
+}
+
+func TestEngine_RenderContextClipboard(t *testing.T) {
+	engine := NewEngine()
+
+	tests := []struct {
+		name      string
+		clipboard *fakeClipboard
+		template  string
+		want      string
+	}{
+		{
+			name:      "clipboard text",
+			clipboard: &fakeClipboard{text: "hello"},
+			template:  "{{ clipboard }}",
+			want:      "hello",
+		},
+		{
+			name:      "clipboard trim",
+			clipboard: &fakeClipboard{text: "  hello\n"},
+			template:  "[{{ clipboard | trim }}]",
+			want:      "[hello]",
+		},
+		{
+			name:      "empty clipboard falls back",
+			clipboard: &fakeClipboard{text: "   "},
+			template:  "{{ clipboard | trim | default \"n/a\" }}",
+			want:      "n/a",
+		},
+		{
+			name:      "unavailable clipboard falls back",
+			clipboard: &fakeClipboard{err: errors.New("locked")},
+			template:  "{{ clipboard | default \"n/a\" }}",
+			want:      "n/a",
+		},
+		{
+			name:      "clipboard read once per render",
+			clipboard: &fakeClipboard{text: "x"},
+			template:  "{{ clipboard }}{{ clipboard }}{{ clipboard }}",
+			want:      "xxx",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.RenderContext(tt.template, map[string]any{}, Context{Clipboard: tt.clipboard})
+			if err != nil {
+				t.Fatalf("Engine.RenderContext() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Engine.RenderContext() = %q, want %q", got, tt.want)
+			}
+			if tt.clipboard.reads != 1 {
+				t.Errorf("clipboard read %d times, want 1", tt.clipboard.reads)
+			}
+		})
+	}
+}
+
+func TestEngine_RenderClipboardWithoutSource(t *testing.T) {
+	engine := NewEngine()
+
+	got, err := engine.Render("{{ clipboard | default \"none\" }}", map[string]any{})
+	if err != nil {
+		t.Fatalf("Engine.Render() error = %v", err)
+	}
+	if got != "none" {
+		t.Errorf("Engine.Render() = %q, want %q", got, "none")
+	}
+}
+
+func TestEngine_RenderContextDate(t *testing.T) {
+	engine := NewEngine()
+	now := time.Date(2024, 2, 29, 22, 15, 0, 0, time.FixedZone("ICT", 7*60*60))
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{
+			name:     "local uses Now location",
+			template: "{{ date \"2006-01-02 15:04\" \"Local\" }}",
+			want:     "2024-02-29 22:15",
+		},
+		{
+			name:     "empty timezone uses Now location",
+			template: "{{ date \"2006-01-02\" \"\" }}",
+			want:     "2024-02-29",
+		},
+		{
+			name:     "UTC",
+			template: "{{ date \"2006-01-02 15:04\" \"UTC\" }}",
+			want:     "2024-02-29 15:15",
+		},
+		{
+			name:     "now param matches date",
+			template: "{{ .now.Year }}",
+			want:     "2024",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.RenderContext(tt.template, map[string]any{"now": now}, Context{Now: now})
+			if err != nil {
+				t.Fatalf("Engine.RenderContext() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Engine.RenderContext() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_RenderSnippetWithoutSource(t *testing.T) {
+	engine := NewEngine()
+
+	if _, err := engine.Render("{{ snippet \":sig\" }}", map[string]any{}); err == nil {
+		t.Error("Engine.Render() should fail when no snippet source is available")
+	}
+}
@@ -0,0 +1,79 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/snipq/core/pkg/locale"
+)
+
+// formatNumberFunc formats a number with locale separators:
+// {{ formatNumber .amount 2 }} renders 1234.5 as "1,234.50" in en and "1.234,50" in de
+func formatNumberFunc(loc *locale.Locale) func(value any, decimals ...any) (string, error) {
+	return func(value any, decimals ...any) (string, error) {
+		n, err := toFiniteFloat(value)
+		if err != nil {
+			return "", fmt.Errorf("formatNumber: %w", err)
+		}
+
+		places := 0
+		if len(decimals) > 0 {
+			places, err = toInt(decimals[0])
+			if err != nil {
+				return "", fmt.Errorf("formatNumber: invalid decimals: %w", err)
+			}
+		}
+
+		return loc.FormatNumber(n, places), nil
+	}
+}
+
+// formatCurrencyFunc formats an amount of money in an ISO 4217 currency,
+// defaulting to the currency of the locale's region:
+// {{ formatCurrency .amount "EUR" }}
+func formatCurrencyFunc(loc *locale.Locale) func(value any, currency ...string) (string, error) {
+	return func(value any, currency ...string) (string, error) {
+		n, err := toFiniteFloat(value)
+		if err != nil {
+			return "", fmt.Errorf("formatCurrency: %w", err)
+		}
+
+		code := ""
+		if len(currency) > 0 {
+			code = currency[0]
+		}
+
+		return loc.FormatCurrency(n, code), nil
+	}
+}
+
+// formatPercentFunc formats a ratio as a percentage: 0.25 renders as "25%"
+func formatPercentFunc(loc *locale.Locale) func(value any, decimals ...any) (string, error) {
+	format := formatNumberFunc(loc)
+	return func(value any, decimals ...any) (string, error) {
+		n, err := toFiniteFloat(value)
+		if err != nil {
+			return "", fmt.Errorf("formatPercent: %w", err)
+		}
+
+		number, err := format(n*100, decimals...)
+		if err != nil {
+			return "", err
+		}
+		return number + "%", nil
+	}
+}
+
+// toFiniteFloat converts a value to a number that can be formatted,
+// rejecting NaN and infinities, which "NaN" or "Inf" query values parse to
+func toFiniteFloat(value any) (float64, error) {
+	n, err := toFloat(value)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return 0, errors.New("cannot format a value that is not a finite number")
+	}
+	return n, nil
+}
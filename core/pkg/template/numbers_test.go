@@ -0,0 +1,129 @@
+package template
+
+import (
+	"testing"
+)
+
+func TestEngine_RenderNumbers(t *testing.T) {
+	engine := NewEngine()
+
+	tests := []struct {
+		name     string
+		locale   string
+		template string
+		data     map[string]any
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "number en",
+			locale:   "en-US",
+			template: "{{ formatNumber .n 2 }}",
+			data:     map[string]any{"n": 1234567.891},
+			want:     "1,234,567.89",
+		},
+		{
+			name:     "number de",
+			locale:   "de-DE",
+			template: "{{ formatNumber .n 2 }}",
+			data:     map[string]any{"n": 1234567.891},
+			want:     "1.234.567,89",
+		},
+		{
+			name:     "number from query string",
+			locale:   "vi-VN",
+			template: "{{ formatNumber .n }}",
+			data:     map[string]any{"n": "1500000"},
+			want:     "1.500.000",
+		},
+		{
+			name:     "negative number",
+			locale:   "en",
+			template: "{{ formatNumber -1234.5 1 }}",
+			want:     "-1,234.5",
+		},
+		{
+			name:     "currency default for region",
+			locale:   "vi-VN",
+			template: "{{ formatCurrency .n }}",
+			data:     map[string]any{"n": 1500000},
+			want:     "1.500.000 ₫",
+		},
+		{
+			name:     "currency en USD",
+			locale:   "en-US",
+			template: "{{ formatCurrency 1234.5 }}",
+			want:     "$1,234.50",
+		},
+		{
+			name:     "currency explicit code",
+			locale:   "de-DE",
+			template: "{{ formatCurrency 1234.5 \"eur\" }}",
+			want:     "1.234,50 €",
+		},
+		{
+			name:     "currency ja",
+			locale:   "ja-JP",
+			template: "{{ formatCurrency 1234.5 }}",
+			want:     "¥1,235",
+		},
+		{
+			name:     "currency fr with narrow space groups",
+			locale:   "fr-FR",
+			template: "{{ formatCurrency -9876.5 }}",
+			want:     "-9 876,50 €",
+		},
+		{
+			name:     "currency unknown code uses code",
+			locale:   "en-US",
+			template: "{{ formatCurrency 10 \"XYZ\" }}",
+			want:     "XYZ10.00",
+		},
+		{
+			name:     "percent",
+			locale:   "en",
+			template: "{{ formatPercent 0.256 1 }}",
+			want:     "25.6%",
+		},
+		{
+			name:     "not a number",
+			locale:   "en",
+			template: "{{ formatNumber .n 2 }}",
+			data:     map[string]any{"n": "NaN"},
+			wantErr:  true,
+		},
+		{
+			name:     "infinite currency",
+			locale:   "en-US",
+			template: "{{ formatCurrency .n }}",
+			data:     map[string]any{"n": "-Inf"},
+			wantErr:  true,
+		},
+		{
+			name:     "infinite percent",
+			locale:   "en",
+			template: "{{ formatPercent .n }}",
+			data:     map[string]any{"n": 1e308},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid number",
+			locale:   "en",
+			template: "{{ formatNumber .n }}",
+			data:     map[string]any{"n": "lots"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.RenderContext(tt.template, tt.data, Context{Locale: tt.locale})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Engine.RenderContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Engine.RenderContext() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// tempSuffix ends the name of a file being written. A leftover one means
+// a write was interrupted before it replaced its target.
+const tempSuffix = ".snipq-tmp"
+
+// staleTempAge is how old a leftover temporary file must be before load
+// removes it, so a write still in progress in another process is left
+// alone
+const staleTempAge = time.Minute
+
+// tempFile is the part of *os.File used by writeFileAtomic
+type tempFile interface {
+	io.Writer
+	Name() string
+	Chmod(mode os.FileMode) error
+	Sync() error
+	Close() error
+}
+
+// createTemp creates the temporary file of a write. Tests replace it to
+// inject faults.
+var createTemp = func(dir, pattern string) (tempFile, error) {
+	return os.CreateTemp(dir, pattern)
+}
+
+// writeFileAtomic replaces the file at path with data. The data goes to a
+// temporary file in the same directory, which is synced to disk and then
+// renamed over path, so after a crash or power loss path holds either
+// its old or its new content, never a truncated mix.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	f, err := createTemp(dir, "."+base+".*"+tempSuffix)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	if err = f.Chmod(perm); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// syncDir makes a rename in dir durable. Windows cannot sync
+// directories, and its renames are durable once they return.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return err
+	}
+	return nil
+}
+
+// isTempFile reports whether name is a temporary file of writeFileAtomic
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, tempSuffix)
+}
+
+// removeStaleTempFiles deletes temporary files left in the vault by
+// interrupted writes and returns their paths. Their targets still hold
+// the last completed write, so discarding them loses nothing that was
+// saved.
+func removeStaleTempFiles(root string, now time.Time) ([]string, error) {
+	var removed []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel != "." && !WatchedPath(rel) {
+				// Backups and anything else kept next to the vault
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isTempFile(d.Name()) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if now.Sub(info.ModTime()) < staleTempAge {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove leftover %s: %w", rel, err)
+		}
+		removed = append(removed, rel)
+		return nil
+	})
+	return removed, err
+}
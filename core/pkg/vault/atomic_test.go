@@ -0,0 +1,244 @@
+package vault
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/snipq/core/pkg/types"
+)
+
+var errInjected = errors.New("injected fault")
+
+// faultyFile fails a write after letting part of it reach the disk, or
+// fails the sync that should make it durable
+type faultyFile struct {
+	*os.File
+	partialWrite bool
+	failSync     bool
+}
+
+func (f *faultyFile) Write(p []byte) (int, error) {
+	if f.partialWrite {
+		n, _ := f.File.Write(p[:len(p)/2])
+		return n, errInjected
+	}
+	return f.File.Write(p)
+}
+
+func (f *faultyFile) Sync() error {
+	if f.failSync {
+		return errInjected
+	}
+	return f.File.Sync()
+}
+
+// injectFault makes writes fail until the test ends
+func injectFault(t *testing.T, fault faultyFile) {
+	t.Helper()
+	original := createTemp
+	createTemp = func(dir, pattern string) (tempFile, error) {
+		f, err := os.CreateTemp(dir, pattern)
+		if err != nil {
+			return nil, err
+		}
+		fault := fault
+		fault.File = f
+		return &fault, nil
+	}
+	t.Cleanup(func() { createTemp = original })
+}
+
+// tempFiles returns the temporary files left below root
+func tempFiles(t *testing.T, root string) []string {
+	t.Helper()
+	var found []string
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err == nil && isTempFile(d.Name()) {
+			found = append(found, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return found
+}
+
+func TestWriteFileAtomicFaults(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault faultyFile
+		save  func(v *Vault) error
+		file  string
+	}{
+		{
+			name:  "partial counters write",
+			fault: faultyFile{partialWrite: true},
+			save: func(v *Vault) error {
+				return v.UpdateCounter("inv", &types.Counter{Value: 5, Step: 1})
+			},
+			file: "counters.json",
+		},
+		{
+			name:  "counters sync failure",
+			fault: faultyFile{failSync: true},
+			save: func(v *Vault) error {
+				return v.UpdateCounter("inv", &types.Counter{Value: 5, Step: 1})
+			},
+			file: "counters.json",
+		},
+		{
+			name:  "partial snippet write",
+			fault: faultyFile{partialWrite: true},
+			save: func(v *Vault) error {
+				return v.UpsertSnippet(&types.Snippet{ID: "snp_sig", GroupID: "g", Name: "Signature", Trigger: "sig", Template: "-- Binh"})
+			},
+			file: "groups/g/snippets/snp_sig.yaml",
+		},
+		{
+			name:  "partial settings write",
+			fault: faultyFile{partialWrite: true},
+			save: func(v *Vault) error {
+				return v.SaveSettings(&types.Settings{Prefix: ";"})
+			},
+			file: "settings.yaml",
+		},
+		{
+			name:  "history sync failure",
+			fault: faultyFile{failSync: true},
+			save: func(v *Vault) error {
+				return v.ClearHistory()
+			},
+			file: "history.jsonl",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vaultDir := t.TempDir()
+			vault := NewVault()
+			if err := vault.Load(vaultDir); err != nil {
+				t.Fatal(err)
+			}
+			if err := vault.SaveSettings(&types.Settings{Prefix: ":", HistoryEnabled: true, HistoryLimit: 10}); err != nil {
+				t.Fatal(err)
+			}
+			if err := vault.CreateGroup(&types.Group{ID: "g", Name: "G", Enabled: true}); err != nil {
+				t.Fatal(err)
+			}
+			if err := vault.UpsertSnippet(&types.Snippet{ID: "snp_sig", GroupID: "g", Name: "Signature", Trigger: "sig", Template: "-- An"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := vault.UpdateCounter("inv", &types.Counter{Value: 1, Step: 1}); err != nil {
+				t.Fatal(err)
+			}
+			if err := vault.AddHistoryEntry(&types.HistoryEntry{SnippetID: "snp_sig"}); err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(vaultDir, filepath.FromSlash(tt.file))
+			before, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			injectFault(t, tt.fault)
+			if err := tt.save(vault); !errors.Is(err, errInjected) {
+				t.Fatalf("save error = %v, want the injected fault", err)
+			}
+
+			after, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(after) != string(before) {
+				t.Errorf("%s after a failed write = %q, want %q", tt.file, after, before)
+			}
+			if left := tempFiles(t, vaultDir); len(left) != 0 {
+				t.Errorf("temporary files left behind: %v", left)
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counters.json")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(path, []byte("new"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "new" {
+		t.Errorf("content = %q, %v; want %q", data, err, "new")
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+	if left := tempFiles(t, filepath.Dir(path)); len(left) != 0 {
+		t.Errorf("temporary files left behind: %v", left)
+	}
+}
+
+func TestVaultRecoversInterruptedWrites(t *testing.T) {
+	vaultDir := t.TempDir()
+	vault := NewVault()
+	if err := vault.Load(vaultDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.CreateGroup(&types.Group{ID: "g", Name: "G", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.UpsertSnippet(&types.Snippet{ID: "snp_sig", GroupID: "g", Name: "Signature", Trigger: "sig", Template: "-- An"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.UpdateCounter("inv", &types.Counter{Value: 42, Step: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash mid-write leaves truncated temporary files next to their
+	// targets; one from a write still running in another process is new
+	stale := []string{
+		".counters.json.123" + tempSuffix,
+		"groups/g/snippets/.snp_sig.yaml.456" + tempSuffix,
+	}
+	fresh := "groups/g/.group.yaml.789" + tempSuffix
+	old := time.Now().Add(-2 * staleTempAge)
+	for _, rel := range append(stale, fresh) {
+		path := filepath.Join(vaultDir, filepath.FromSlash(rel))
+		if err := os.WriteFile(path, []byte(`{"inv": {"val`), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if rel != fresh {
+			if err := os.Chtimes(path, old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	reloaded := NewVault()
+	if err := reloaded.Load(vaultDir); err != nil {
+		t.Fatal(err)
+	}
+	if counter := reloaded.GetCounter("inv"); counter == nil || counter.Value != 42 {
+		t.Errorf("counter after recovery = %+v, want 42", counter)
+	}
+	if snippet, err := reloaded.GetSnippet("snp_sig"); err != nil || snippet.Template != "-- An" {
+		t.Errorf("snippet after recovery = %+v, %v", snippet, err)
+	}
+
+	var left []string
+	for _, path := range tempFiles(t, vaultDir) {
+		rel, _ := filepath.Rel(vaultDir, path)
+		left = append(left, filepath.ToSlash(rel))
+	}
+	if !reflect.DeepEqual(left, []string{fresh}) {
+		t.Errorf("temporary files after load = %v, want only %s", left, fresh)
+	}
+}
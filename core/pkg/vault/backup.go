@@ -53,7 +53,7 @@ func (v *Vault) BackupVault(backupDir string) error {
 	// Create backup manifest
 	manifest := map[string]interface{}{
 		"created_at":  time.Now().Format(time.RFC3339),
-		"source_path": v.path,
+		"source_path": v.Path(),
 		"backup_path": backupPath,
 		"version":     "1.0",
 	}
@@ -64,7 +64,7 @@ func (v *Vault) BackupVault(backupDir string) error {
 	}
 
 	manifestPath := filepath.Join(backupPath, "manifest.json")
-	if err := os.WriteFile(manifestPath, manifestData, 0600); err != nil {
+	if err := writeFileAtomic(manifestPath, manifestData, 0600); err != nil {
 		return fmt.Errorf("failed to write backup manifest: %w", err)
 	}
 
@@ -94,13 +94,33 @@ func (v *Vault) RestoreVault(backupPath string) error {
 		return fmt.Errorf("failed to parse backup manifest: %w", err)
 	}
 
+	v.writeMu.Lock()
+	defer v.writeMu.Unlock()
+
 	// Create backup of current state before restore
-	backupDir := filepath.Join(v.path, "backups", "pre_restore")
+	backupDir := filepath.Join(v.Path(), "backups", "pre_restore")
 	if err := v.BackupVault(backupDir); err != nil {
 		return fmt.Errorf("failed to create pre-restore backup: %w", err)
 	}
 
 	// Restore files
+	if err := v.restoreFiles(backupPath); err != nil {
+		return err
+	}
+
+	// Reload vault data
+	return v.loadLocked(v.Path())
+}
+
+// restoreFiles writes the files of a backup to the vault, holding the
+// vault lock so other processes never load half of them
+func (v *Vault) restoreFiles(backupPath string) error {
+	unlock, err := lockVault(v.Path(), true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	if err := v.restoreSnippets(backupPath); err != nil {
 		return fmt.Errorf("failed to restore snippets: %w", err)
 	}
@@ -117,44 +137,45 @@ func (v *Vault) RestoreVault(backupPath string) error {
 		return fmt.Errorf("failed to restore counters: %w", err)
 	}
 
-	// Reload vault data
-	return v.Load(v.path)
+	return nil
 }
 
 func (v *Vault) backupSnippets(backupPath string) error {
 	snippetsPath := filepath.Join(backupPath, "snippets.json")
-	snippetsData, err := json.MarshalIndent(v.snippets, "", "  ")
+	snippetsData, err := json.MarshalIndent(v.snapshot().snippets, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(snippetsPath, snippetsData, 0600)
+	return writeFileAtomic(snippetsPath, snippetsData, 0600)
 }
 
 func (v *Vault) backupGroups(backupPath string) error {
 	groupsPath := filepath.Join(backupPath, "groups.json")
-	groupsData, err := json.MarshalIndent(v.groups, "", "  ")
+	groupsData, err := json.MarshalIndent(v.snapshot().groups, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(groupsPath, groupsData, 0600)
+	return writeFileAtomic(groupsPath, groupsData, 0600)
 }
 
 func (v *Vault) backupSettings(backupPath string) error {
 	settingsPath := filepath.Join(backupPath, "settings.yaml")
-	settingsData, err := yaml.Marshal(v.settings)
+	settingsData, err := yaml.Marshal(v.snapshot().settings)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(settingsPath, settingsData, 0600)
+	return writeFileAtomic(settingsPath, settingsData, 0600)
 }
 
 func (v *Vault) backupCounters(backupPath string) error {
 	countersPath := filepath.Join(backupPath, "counters.json")
+	v.counterMu.Lock()
 	countersData, err := json.MarshalIndent(v.counters, "", "  ")
+	v.counterMu.Unlock()
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(countersPath, countersData, 0600)
+	return writeFileAtomic(countersPath, countersData, 0600)
 }
 
 func (v *Vault) restoreSnippets(backupPath string) error {
@@ -169,11 +190,10 @@ func (v *Vault) restoreSnippets(backupPath string) error {
 		return err
 	}
 
-	v.snippets = snippets
-
 	// Save individual snippets
+	s := v.snapshot()
 	for _, snippet := range snippets {
-		if err := v.saveSnippet(snippet); err != nil {
+		if err := s.saveSnippet(snippet); err != nil {
 			return fmt.Errorf("failed to save snippet %s: %w", snippet.ID, err)
 		}
 	}
@@ -193,11 +213,10 @@ func (v *Vault) restoreGroups(backupPath string) error {
 		return err
 	}
 
-	v.groups = groups
-
 	// Save individual groups
+	s := v.snapshot()
 	for _, group := range groups {
-		if err := v.saveGroup(group); err != nil {
+		if err := s.saveGroup(group); err != nil {
 			return fmt.Errorf("failed to save group %s: %w", group.ID, err)
 		}
 	}
@@ -217,8 +236,9 @@ func (v *Vault) restoreSettings(backupPath string) error {
 		return err
 	}
 
-	v.settings = &settings
-	return v.saveSettings()
+	restored := newState(v.Path())
+	restored.settings = &settings
+	return restored.saveSettings()
 }
 
 func (v *Vault) restoreCounters(backupPath string) error {
@@ -233,6 +253,9 @@ func (v *Vault) restoreCounters(backupPath string) error {
 		return err
 	}
 
+	v.counterMu.Lock()
+	defer v.counterMu.Unlock()
+
 	v.counters = counters
 	return v.saveCounters()
 }
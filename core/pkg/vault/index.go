@@ -0,0 +1,57 @@
+package vault
+
+import (
+	"sort"
+
+	"github.com/snipq/core/pkg/types"
+)
+
+// triggerIndex maps prefix-less trigger names to the snippets using them,
+// in resolution order
+type triggerIndex map[string][]*types.Snippet
+
+// reindex rebuilds the trigger and pattern indexes. It runs whenever
+// snippets, groups or the prefix change.
+func (s *state) reindex() {
+	index := make(triggerIndex, len(s.snippets))
+	for _, snippet := range s.snippets {
+		if snippet.Trigger == "" {
+			continue
+		}
+		name := s.triggerName(snippet.Trigger)
+		index[name] = append(index[name], snippet)
+	}
+
+	for _, candidates := range index {
+		sort.Slice(candidates, func(i, j int) bool {
+			return s.resolvesBefore(candidates[i], candidates[j])
+		})
+	}
+
+	s.index = index
+	s.reindexPatterns()
+	s.reindexParamPatterns()
+}
+
+// resolvesBefore orders snippets sharing a trigger: by group order, then
+// higher snippet priority, then group and snippet ID so the result never
+// depends on map order
+func (s *state) resolvesBefore(a, b *types.Snippet) bool {
+	if orderA, orderB := s.groupOrder(a.GroupID), s.groupOrder(b.GroupID); orderA != orderB {
+		return orderA < orderB
+	}
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	if a.GroupID != b.GroupID {
+		return a.GroupID < b.GroupID
+	}
+	return a.ID < b.ID
+}
+
+func (s *state) groupOrder(groupID string) int {
+	if group, ok := s.groups[groupID]; ok {
+		return group.Order
+	}
+	return 0
+}
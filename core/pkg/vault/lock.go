@@ -0,0 +1,90 @@
+package vault
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/snipq/core/pkg/lockfile"
+	"github.com/snipq/core/pkg/types"
+)
+
+// LockFile is the advisory lock file in the vault root. Processes sharing
+// a vault, such as the CLI, a tray app and a sync client, hold it for
+// writing while they change files and for reading while they load them.
+const LockFile = ".snipq.lock"
+
+// lockOptions configures the vault lock; tests shorten its timeouts
+var lockOptions lockfile.Options
+
+// lockVault takes the lock of the vault at path, exclusively for writes
+// or shared for reads, and returns the function releasing it. A vault
+// without a path has no lock.
+func lockVault(path string, exclusive bool) (unlock func(), err error) {
+	if path == "" {
+		return func() {}, nil
+	}
+
+	lock := lockfile.New(filepath.Join(path, LockFile), lockOptions)
+	take := lock.RLock
+	if exclusive {
+		take = lock.Lock
+	}
+	handle, err := take()
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock vault: %w", err)
+	}
+	return func() { handle.Unlock() }, nil
+}
+
+// syncCounters merges counters.json into the counters in memory, so a
+// counter another process advanced is not rolled back. Counters that did
+// not change keep their identity, so SwapCounters only reports conflicts
+// for the ones that did. Callers hold counterMu and the vault lock.
+func (v *Vault) syncCounters() error {
+	path := v.Path()
+	if path == "" {
+		return nil
+	}
+
+	counters, err := readCounters(path)
+	if err != nil {
+		return fmt.Errorf("failed to load counters: %w", err)
+	}
+	v.mergeCounters(counters)
+	return nil
+}
+
+// mergeCounters replaces the counters in memory with counters read from
+// disk, keeping the ones that are unchanged. Callers hold counterMu.
+func (v *Vault) mergeCounters(counters map[string]*types.Counter) {
+	for name, counter := range counters {
+		if current := v.counters[name]; current != nil && sameCounter(current, counter) {
+			counters[name] = current
+		}
+	}
+	v.counters = counters
+}
+
+// sameCounter compares counters as stored, since timestamps read back
+// from counters.json lose their monotonic clock reading
+func sameCounter(a, b *types.Counter) bool {
+	return a.Value == b.Value && a.Step == b.Step && a.Start == b.Start &&
+		a.Reset == b.Reset && a.UpdatedAt.Equal(b.UpdatedAt)
+}
+
+// syncHistory re-reads history.jsonl, so entries other processes added
+// are kept when it is saved. Callers hold historyMu and the vault lock.
+func (v *Vault) syncHistory() error {
+	path := v.Path()
+	if path == "" {
+		return nil
+	}
+
+	history, err := readHistory(path)
+	if err != nil {
+		return fmt.Errorf("failed to load history: %w", err)
+	}
+	v.history = history
+	v.historyLines = len(history)
+	return nil
+}
@@ -0,0 +1,347 @@
+package vault
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+
+	"github.com/snipq/core/pkg/parser"
+	"github.com/snipq/core/pkg/types"
+)
+
+// MaxRegexLength limits the length of a snippet's regex trigger
+const MaxRegexLength = 256
+
+// maxSamples bounds how many example strings are generated per pattern
+const maxSamples = 32
+
+// PatternMatch is a snippet whose regex trigger matched a typed trigger,
+// with the values of its named capture groups
+type PatternMatch struct {
+	Snippet  *types.Snippet
+	Captures map[string]string
+}
+
+// compiledPattern is a regex trigger in the pattern index
+type compiledPattern struct {
+	snippet *types.Snippet
+	re      *regexp.Regexp
+}
+
+// CompileRegexTrigger compiles a regex trigger so it must match a whole
+// prefix-less trigger name
+func CompileRegexTrigger(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// FindSnippetsByPattern returns the snippets whose regex trigger matches a
+// typed trigger, in the resolution order of FindSnippetsByTrigger. Literal
+// triggers take precedence: callers only fall back to patterns when no
+// literal trigger matched.
+func (v *Vault) FindSnippetsByPattern(trigger string, opts types.ListOptions) []PatternMatch {
+	s := v.snapshot()
+	name, ok := parser.StripPrefix(trigger, s.getSettings().Prefix)
+	if !ok {
+		return nil
+	}
+
+	var matches []PatternMatch
+	for _, pattern := range s.patterns {
+		if !s.visible(pattern.snippet.GroupID, opts) {
+			continue
+		}
+		groups := pattern.re.FindStringSubmatch(name)
+		if groups == nil {
+			continue
+		}
+
+		captures := make(map[string]string)
+		for i, key := range pattern.re.SubexpNames() {
+			if key != "" && i < len(groups) {
+				captures[key] = groups[i]
+			}
+		}
+		matches = append(matches, PatternMatch{Snippet: pattern.snippet, Captures: captures})
+	}
+	return matches
+}
+
+// reindexPatterns rebuilds the pattern index in resolution order,
+// recompiling only the patterns of snippets added or changed since the
+// last one. Snippets with invalid patterns never pass validation on
+// upsert; ones edited by hand are skipped.
+func (s *state) reindexPatterns() {
+	previous := make(map[string]compiledPattern, len(s.patterns))
+	for _, pattern := range s.patterns {
+		previous[pattern.snippet.ID] = pattern
+	}
+
+	var patterns []compiledPattern
+	for id, snippet := range s.snippets {
+		if snippet.Regex == "" {
+			continue
+		}
+		if old, ok := previous[id]; ok && old.snippet.Regex == snippet.Regex {
+			patterns = append(patterns, compiledPattern{snippet: snippet, re: old.re})
+		} else if re, err := CompileRegexTrigger(snippet.Regex); err == nil {
+			patterns = append(patterns, compiledPattern{snippet: snippet, re: re})
+		}
+	}
+
+	sort.Slice(patterns, func(i, j int) bool {
+		return s.resolvesBefore(patterns[i].snippet, patterns[j].snippet)
+	})
+	s.patterns = patterns
+}
+
+// CompileParamPattern compiles the pattern of a strict param so it must
+// match a whole value
+func CompileParamPattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// ParamPattern returns the compiled pattern of a strict param. Patterns
+// of the vault's snippets are compiled once, when they are loaded or
+// edited; others are compiled on each call.
+func (v *Vault) ParamPattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := v.snapshot().params[pattern]; ok {
+		return re, nil
+	}
+	return CompileParamPattern(pattern)
+}
+
+// reindexParamPatterns compiles the param patterns of the snippets,
+// reusing the ones already compiled. Invalid patterns are left out, so
+// ParamPattern reports their error.
+func (s *state) reindexParamPatterns() {
+	params := make(map[string]*regexp.Regexp)
+	for _, snippet := range s.snippets {
+		for _, param := range snippet.Params {
+			if param.Pattern == "" || params[param.Pattern] != nil {
+				continue
+			}
+			if re, ok := s.params[param.Pattern]; ok {
+				params[param.Pattern] = re
+			} else if re, err := CompileParamPattern(param.Pattern); err == nil {
+				params[param.Pattern] = re
+			}
+		}
+	}
+	s.params = params
+}
+
+// checkPatternOverlap rejects a regex trigger that overlaps the regex
+// trigger of another snippet in the same group, since neither would
+// reliably win
+func (s *state) checkPatternOverlap(snippet *types.Snippet) error {
+	if snippet.Regex == "" {
+		return nil
+	}
+
+	for _, other := range s.patterns {
+		if other.snippet.GroupID != snippet.GroupID || other.snippet.ID == snippet.ID {
+			continue
+		}
+		overlap, err := patternsOverlap(snippet.Regex, other.snippet.Regex)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSnippet, err)
+		}
+		if overlap {
+			return fmt.Errorf("%w: regex '%s' overlaps regex '%s' in group '%s' (snippet: %s)",
+				ErrDuplicateTrigger, snippet.Regex, other.snippet.Regex, snippet.GroupID, other.snippet.ID)
+		}
+	}
+	return nil
+}
+
+// validateRegex validates a snippet's regex trigger. Besides compiling it,
+// patterns that may backtrack catastrophically in other regex engines are
+// rejected: a repeat around another unbounded repeat, around something
+// that can match nothing, or around text that can be split into repeats
+// in more than one way.
+func validateRegex(pattern string) error {
+	if len(pattern) > MaxRegexLength {
+		return fmt.Errorf("%w: regex is longer than %d characters", ErrInvalidSnippet, MaxRegexLength)
+	}
+
+	tree, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return fmt.Errorf("%w: invalid regex: %v", ErrInvalidSnippet, err)
+	}
+	re, err := CompileRegexTrigger(pattern)
+	if err != nil {
+		return fmt.Errorf("%w: invalid regex: %v", ErrInvalidSnippet, err)
+	}
+	if re.MatchString("") {
+		return fmt.Errorf("%w: regex '%s' matches an empty trigger", ErrInvalidSnippet, pattern)
+	}
+
+	for _, name := range re.SubexpNames() {
+		if name != "" && parser.ParamRoot(name) != name {
+			return fmt.Errorf("%w: regex group '%s' is not a valid param name", ErrInvalidSnippet, name)
+		}
+	}
+
+	if reason := catastrophic(tree, false); reason != "" {
+		return fmt.Errorf("%w: regex '%s' %s", ErrInvalidSnippet, pattern, reason)
+	}
+	return nil
+}
+
+// catastrophic returns why a pattern may backtrack exponentially, or ""
+func catastrophic(re *syntax.Regexp, repeated bool) string {
+	if unbounded(re) {
+		if repeated {
+			return "nests unbounded repeats"
+		}
+
+		sub, err := CompileRegexTrigger(re.Sub[0].String())
+		if err != nil {
+			return ""
+		}
+		if sub.MatchString("") {
+			return "repeats an empty match"
+		}
+		samples := patternSamples(re.Sub[0])
+		for _, a := range samples {
+			for _, b := range samples {
+				if a != "" && b != "" && sub.MatchString(a+b) {
+					return "repeats text that splits into repeats more than one way"
+				}
+			}
+		}
+		repeated = true
+	}
+
+	if repeated && re.Op == syntax.OpAlternate {
+		for i := range re.Sub {
+			for j := i + 1; j < len(re.Sub); j++ {
+				if overlap, _ := patternsOverlap(re.Sub[i].String(), re.Sub[j].String()); overlap {
+					return "repeats overlapping alternatives"
+				}
+			}
+		}
+	}
+
+	for _, sub := range re.Sub {
+		if reason := catastrophic(sub, repeated); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+func unbounded(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpStar, syntax.OpPlus:
+		return true
+	case syntax.OpRepeat:
+		return re.Max < 0
+	}
+	return false
+}
+
+// patternsOverlap reports whether two patterns are known to match a
+// common trigger: an example string of one is matched by the other
+func patternsOverlap(a, b string) (bool, error) {
+	reA, err := CompileRegexTrigger(a)
+	if err != nil {
+		return false, err
+	}
+	reB, err := CompileRegexTrigger(b)
+	if err != nil {
+		return false, err
+	}
+	treeA, err := syntax.Parse(a, syntax.Perl)
+	if err != nil {
+		return false, err
+	}
+	treeB, err := syntax.Parse(b, syntax.Perl)
+	if err != nil {
+		return false, err
+	}
+
+	for _, sample := range patternSamples(treeA) {
+		if reB.MatchString(sample) {
+			return true, nil
+		}
+	}
+	for _, sample := range patternSamples(treeB) {
+		if reA.MatchString(sample) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// patternSamples returns example strings a pattern matches: each
+// alternative, with optional parts both left out and taken once
+func patternSamples(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCharClass:
+		if len(re.Rune) == 0 {
+			return nil
+		}
+		return []string{string(re.Rune[0])}
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return []string{"a"}
+	case syntax.OpCapture, syntax.OpPlus:
+		return patternSamples(re.Sub[0])
+	case syntax.OpStar, syntax.OpQuest:
+		return union([]string{""}, patternSamples(re.Sub[0]))
+	case syntax.OpRepeat:
+		var samples []string
+		if re.Min == 0 {
+			samples = []string{""}
+		}
+		for _, sample := range patternSamples(re.Sub[0]) {
+			samples = union(samples, []string{strings.Repeat(sample, max(re.Min, 1))})
+		}
+		return samples
+	case syntax.OpConcat:
+		samples := []string{""}
+		for _, sub := range re.Sub {
+			var next []string
+			for _, prefix := range samples {
+				for _, suffix := range patternSamples(sub) {
+					if len(next) < maxSamples {
+						next = append(next, prefix+suffix)
+					}
+				}
+			}
+			samples = next
+		}
+		return samples
+	case syntax.OpAlternate:
+		var samples []string
+		for _, sub := range re.Sub {
+			samples = union(samples, patternSamples(sub))
+		}
+		return samples
+	}
+	return []string{""}
+}
+
+// union appends the strings of b missing from a, up to maxSamples
+func union(a, b []string) []string {
+	for _, s := range b {
+		if len(a) >= maxSamples {
+			break
+		}
+		found := false
+		for _, existing := range a {
+			if existing == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			a = append(a, s)
+		}
+	}
+	return a
+}
@@ -0,0 +1,162 @@
+package vault
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/snipq/core/pkg/types"
+)
+
+func TestValidateRegex(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr bool
+	}{
+		{`inv(?P<number>\d+)`, false},
+		{`jira-(?P<project>[A-Z]+)-(?P<issue>\d+)`, false},
+		{`(foo|bar)+`, false},
+		{`(\d{3}-?)+`, false},
+		{`(a|ab)+`, false},
+		{`(`, true},
+		{`a*`, true},          // matches an empty trigger
+		{`(a+)+b`, true},      // nested unbounded repeats
+		{`(x+x+)+y`, true},    // nested unbounded repeats
+		{`(a|aa)+b`, true},    // repeats split more than one way
+		{`(\d|\d\d)*x`, true}, // repeats split more than one way
+		{`(ab|\wb)+c`, true},  // overlapping alternatives
+		{`x(a?)*`, true},      // repeats an empty match
+		{`(?P<a-b>x)`, true},  // invalid group name
+		{string(make([]byte, MaxRegexLength+1)), true},
+	}
+
+	for _, tt := range tests {
+		err := validateRegex(tt.pattern)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateRegex(%q) error = %v, wantErr %v", tt.pattern, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidSnippet) {
+			t.Errorf("validateRegex(%q) error = %v, want ErrInvalidSnippet", tt.pattern, err)
+		}
+	}
+}
+
+func TestPatternsOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{`inv\d+`, `inv[0-9]+`, true},
+		{`inv\d+`, `inv-\d+`, false},
+		{`jira-[A-Z]+-\d+`, `jira-ABC-\d+`, true},
+		{`(a|b)x`, `bx`, true},
+		{`a\d`, `b\d`, false},
+	}
+
+	for _, tt := range tests {
+		got, err := patternsOverlap(tt.a, tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("patternsOverlap(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestVaultRegexTriggers(t *testing.T) {
+	vault := NewVault()
+	if err := vault.Load(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.UpsertGroup(&types.Group{ID: "g", Name: "G", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	invoice := &types.Snippet{ID: "snp_inv", Name: "Invoice", Regex: `inv(?P<number>\d+)`, Template: "INV-{{ .number }}", GroupID: "g"}
+	if err := vault.UpsertSnippet(invoice); err != nil {
+		t.Fatalf("UpsertSnippet() error = %v", err)
+	}
+
+	// Edits recompile only the patterns that changed
+	compiled := vault.snapshot().patterns[0].re
+	renamed := *invoice
+	renamed.Name = "Invoice (renamed)"
+	if err := vault.UpsertSnippet(&renamed); err != nil {
+		t.Fatal(err)
+	}
+	if got := vault.snapshot().patterns[0].re; got != compiled {
+		t.Error("UpsertSnippet(renamed) recompiled an unchanged pattern")
+	}
+	changed := renamed
+	changed.Regex = `inv(?P<number>\d{1,6})`
+	if err := vault.UpsertSnippet(&changed); err != nil {
+		t.Fatal(err)
+	}
+	if got := vault.snapshot().patterns[0].re; got == compiled || got.String() != `^(?:inv(?P<number>\d{1,6}))$` {
+		t.Errorf("pattern after changing the regex = %v", got)
+	}
+
+	overlapping := &types.Snippet{ID: "snp_inv2", Name: "Invoice 2", Regex: `inv[0-9]+`, Template: "x", GroupID: "g"}
+	if err := vault.UpsertSnippet(overlapping); !errors.Is(err, ErrDuplicateTrigger) {
+		t.Errorf("UpsertSnippet(overlapping) error = %v, want ErrDuplicateTrigger", err)
+	}
+
+	noTrigger := &types.Snippet{ID: "snp_none", Name: "None", Template: "x", GroupID: "g"}
+	if err := vault.UpsertSnippet(noTrigger); !errors.Is(err, ErrInvalidSnippet) {
+		t.Errorf("UpsertSnippet(no trigger) error = %v, want ErrInvalidSnippet", err)
+	}
+
+	matches := vault.FindSnippetsByPattern(":inv42", types.ListOptions{})
+	if len(matches) != 1 || matches[0].Snippet.ID != "snp_inv" || matches[0].Captures["number"] != "42" {
+		t.Errorf("FindSnippetsByPattern(:inv42) = %+v", matches)
+	}
+	for _, trigger := range []string{"inv42", ":inv", ":inv42x", ":xinv42"} {
+		if got := vault.FindSnippetsByPattern(trigger, types.ListOptions{}); len(got) != 0 {
+			t.Errorf("FindSnippetsByPattern(%q) = %+v, want none", trigger, got)
+		}
+	}
+
+	if err := vault.DeleteSnippet("snp_inv"); err != nil {
+		t.Fatal(err)
+	}
+	if got := vault.FindSnippetsByPattern(":inv42", types.ListOptions{}); len(got) != 0 {
+		t.Errorf("FindSnippetsByPattern after delete = %+v, want none", got)
+	}
+}
+
+func TestVaultParamPatterns(t *testing.T) {
+	vault := NewVault()
+	if err := vault.Load(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.UpsertGroup(&types.Group{ID: "g", Name: "G", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	ticket := &types.Snippet{ID: "snp_ticket", Name: "Ticket", Trigger: "ticket", Template: "{{ .id }}", GroupID: "g", Strict: true,
+		Params: []types.Param{{Name: "id", Pattern: `[A-Z]+-\d+`}}}
+	if err := vault.UpsertSnippet(ticket); err != nil {
+		t.Fatal(err)
+	}
+
+	re, err := vault.ParamPattern(`[A-Z]+-\d+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !re.MatchString("OPS-12") || re.MatchString("see OPS-12") {
+		t.Errorf("ParamPattern() = %v, want it anchored", re)
+	}
+
+	// An unrelated edit keeps the compiled pattern
+	other := &types.Snippet{ID: "snp_other", Name: "Other", Trigger: "other", Template: "x", GroupID: "g"}
+	if err := vault.UpsertSnippet(other); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := vault.ParamPattern(`[A-Z]+-\d+`); again != re {
+		t.Error("ParamPattern() recompiled after an unrelated edit")
+	}
+
+	if _, err := vault.ParamPattern(`(`); err == nil {
+		t.Error("ParamPattern(invalid) error = nil")
+	}
+}
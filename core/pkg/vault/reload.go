@@ -0,0 +1,405 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/snipq/core/pkg/types"
+)
+
+// Reload re-reads the vault from its path. The new state replaces the
+// current one only if everything loaded, so a half-written or invalid
+// file leaves the vault as it was. The diff lists what changed.
+func (v *Vault) Reload() (types.VaultDiff, error) {
+	v.writeMu.Lock()
+	defer v.writeMu.Unlock()
+
+	return v.reloadLocked()
+}
+
+func (v *Vault) reloadLocked() (types.VaultDiff, error) {
+	path := v.Path()
+	if path == "" {
+		return types.VaultDiff{}, fmt.Errorf("vault path not set")
+	}
+
+	unlock, err := lockVault(path, false)
+	if err != nil {
+		return types.VaultDiff{}, err
+	}
+	defer unlock()
+
+	next := NewVault()
+	if err := next.load(path); err != nil {
+		return types.VaultDiff{}, err
+	}
+
+	diff := v.diff(next)
+	countersChanged, err := v.replace(next)
+	if err != nil {
+		return types.VaultDiff{}, err
+	}
+	diff.CountersChanged = countersChanged
+	return diff, nil
+}
+
+// replace swaps in the state of a freshly loaded vault and reports
+// whether its counters differ. Counters and history are read here, under
+// their locks, so ones saved by an expansion while the rest loaded are
+// not lost; if either fails to load nothing is replaced.
+func (v *Vault) replace(next *Vault) (bool, error) {
+	path := next.Path()
+
+	v.counterMu.Lock()
+	defer v.counterMu.Unlock()
+	counters, err := readCounters(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to load counters: %w", err)
+	}
+
+	v.historyMu.Lock()
+	defer v.historyMu.Unlock()
+	history, err := readHistory(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to load history: %w", err)
+	}
+
+	countersChanged := !countersEqual(v.counters, counters)
+	v.current.Store(next.snapshot())
+	v.mergeCounters(counters)
+	v.history = history
+	v.historyLines = len(history)
+	v.trimHistory(next.GetSettings().HistoryLimit)
+	return countersChanged, nil
+}
+
+// diff compares the groups, snippets and settings of the vault with a
+// newly loaded state
+func (v *Vault) diff(next *Vault) types.VaultDiff {
+	s, n := v.snapshot(), next.snapshot()
+	var diff types.VaultDiff
+	diff.AddedSnippets, diff.ChangedSnippets, diff.RemovedSnippets = diffMaps(s.snippets, n.snippets)
+	diff.AddedGroups, diff.ChangedGroups, diff.RemovedGroups = diffMaps(s.groups, n.groups)
+	diff.SettingsChanged = !reflect.DeepEqual(s.getSettings(), n.getSettings())
+	return diff
+}
+
+// diffMaps returns the sorted keys added to, changed in and removed from
+// old in next
+func diffMaps[T any](old, next map[string]*T) (added, changed, removed []string) {
+	for id, item := range next {
+		previous, exists := old[id]
+		switch {
+		case !exists:
+			added = append(added, id)
+		case !reflect.DeepEqual(previous, item):
+			changed = append(changed, id)
+		}
+	}
+	for id := range old {
+		if _, exists := next[id]; !exists {
+			removed = append(removed, id)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return added, changed, removed
+}
+
+// countersEqual compares counters as stored, since timestamps read back
+// from counters.json lose their monotonic clock reading
+func countersEqual(a, b map[string]*types.Counter) bool {
+	dataA, errA := json.Marshal(a)
+	dataB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(dataA, dataB)
+}
+
+// ReloadPaths re-reads only the parts of the vault behind the given
+// changed files, relative to the vault root with forward slashes:
+// settings.yaml, counters.json, a single snippet file or a whole group.
+// Other files such as history.jsonl are ignored, and "" or "groups"
+// reloads everything. Each part is swapped in only if it loaded; the
+// diffs of the parts that did are merged.
+func (v *Vault) ReloadPaths(paths []string) (types.VaultDiff, error) {
+	v.writeMu.Lock()
+	defer v.writeMu.Unlock()
+
+	if v.Path() == "" {
+		return types.VaultDiff{}, fmt.Errorf("vault path not set")
+	}
+
+	var full, settings, counters bool
+	groups := make(map[string]bool)
+	var snippetFiles []string
+	for _, file := range paths {
+		file = path.Clean(file)
+		parts := strings.Split(file, "/")
+		switch {
+		case file == "." || file == "groups":
+			full = true
+		case file == "settings.yaml":
+			settings = true
+		case file == "counters.json":
+			counters = true
+		case parts[0] != "groups":
+			continue
+		case len(parts) >= 4 && parts[2] == "snippets" && strings.HasSuffix(file, ".yaml"):
+			snippetFiles = append(snippetFiles, file)
+		case len(parts) == 2 || parts[2] == "group.yaml" || (parts[2] == "snippets" && path.Ext(file) == ""):
+			// The group directory, its group.yaml, or a directory of snippets
+			groups[parts[1]] = true
+		}
+	}
+
+	if full {
+		return v.reloadLocked()
+	}
+
+	unlock, err := lockVault(v.Path(), false)
+	if err != nil {
+		return types.VaultDiff{}, err
+	}
+	defer unlock()
+
+	var diff types.VaultDiff
+	var errs []error
+	reload := func(part types.VaultDiff, err error) {
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		diff = mergeDiff(diff, part)
+	}
+
+	if settings {
+		reload(v.reloadSettings())
+	}
+	if counters {
+		reload(v.reloadCounters())
+	}
+
+	groupIDs := make([]string, 0, len(groups))
+	for groupID := range groups {
+		groupIDs = append(groupIDs, groupID)
+	}
+	sort.Strings(groupIDs)
+	for _, groupID := range groupIDs {
+		reload(v.reloadGroup(groupID))
+	}
+
+	sort.Strings(snippetFiles)
+	for _, file := range snippetFiles {
+		if !groups[strings.Split(file, "/")[1]] {
+			reload(v.reloadSnippet(file))
+		}
+	}
+
+	return diff, errors.Join(errs...)
+}
+
+func (v *Vault) reloadSettings() (types.VaultDiff, error) {
+	s := v.snapshot()
+	loaded := newState(s.path)
+	if err := loaded.loadSettings(); err != nil {
+		return types.VaultDiff{}, fmt.Errorf("failed to load settings: %w", err)
+	}
+
+	diff := types.VaultDiff{SettingsChanged: !reflect.DeepEqual(s.getSettings(), loaded.getSettings())}
+	if diff.SettingsChanged {
+		// The prefix may have changed
+		next := s.clone()
+		next.settings = loaded.settings
+		next.reindex()
+		v.current.Store(next)
+	}
+	return diff, nil
+}
+
+// reloadCounters re-reads counters.json under the counter lock, so a
+// counter saved meanwhile by an expansion is not rolled back
+func (v *Vault) reloadCounters() (types.VaultDiff, error) {
+	v.counterMu.Lock()
+	defer v.counterMu.Unlock()
+
+	counters, err := readCounters(v.Path())
+	if err != nil {
+		return types.VaultDiff{}, fmt.Errorf("failed to load counters: %w", err)
+	}
+
+	diff := types.VaultDiff{CountersChanged: !countersEqual(v.counters, counters)}
+	if diff.CountersChanged {
+		v.mergeCounters(counters)
+	}
+	return diff, nil
+}
+
+// reloadGroup re-reads a group and all its snippets. A group whose
+// directory is gone is removed.
+func (v *Vault) reloadGroup(groupID string) (types.VaultDiff, error) {
+	s := v.snapshot()
+	loaded := newState(s.path)
+	if _, err := os.Stat(filepath.Join(s.path, "groups", groupID)); err == nil {
+		if err := loaded.loadGroup(groupID); err != nil {
+			return types.VaultDiff{}, fmt.Errorf("group %s: %w", groupID, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return types.VaultDiff{}, err
+	}
+
+	old := newState(s.path)
+	if group, exists := s.groups[groupID]; exists {
+		old.groups[groupID] = group
+	}
+	for id, snippet := range s.snippets {
+		if snippet.GroupID == groupID {
+			old.snippets[id] = snippet
+		}
+	}
+
+	var diff types.VaultDiff
+	diff.AddedSnippets, diff.ChangedSnippets, diff.RemovedSnippets = diffMaps(old.snippets, loaded.snippets)
+	diff.AddedGroups, diff.ChangedGroups, diff.RemovedGroups = diffMaps(old.groups, loaded.groups)
+
+	next := s.clone()
+	delete(next.groups, groupID)
+	for id := range old.snippets {
+		delete(next.snippets, id)
+	}
+	for file := range next.sources {
+		if strings.HasPrefix(file, "groups/"+groupID+"/") {
+			delete(next.sources, file)
+		}
+	}
+	v.publishMerged(next, loaded)
+	return diff, nil
+}
+
+// reloadSnippet re-reads one snippet file. When that cannot be done on
+// its own, because the file's group is new or its snippet ID is also
+// defined by another file, the whole group is reloaded instead.
+func (v *Vault) reloadSnippet(file string) (types.VaultDiff, error) {
+	s := v.snapshot()
+	groupID := strings.Split(file, "/")[1]
+	if _, exists := s.groups[groupID]; !exists {
+		return v.reloadGroup(groupID)
+	}
+
+	oldID, known := s.sources[file]
+	if known && s.definedElsewhere(oldID, file) {
+		return v.reloadGroup(groupID)
+	}
+
+	loaded := newState(s.path)
+	err := loaded.loadSnippet(filepath.Join(s.path, filepath.FromSlash(file)), groupID)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return types.VaultDiff{}, err
+	}
+	for id := range loaded.snippets {
+		if _, exists := s.snippets[id]; exists && id != oldID {
+			return v.reloadGroup(groupID)
+		}
+	}
+
+	old := make(map[string]*types.Snippet)
+	if snippet, exists := s.snippets[oldID]; known && exists {
+		old[oldID] = snippet
+	}
+
+	var diff types.VaultDiff
+	diff.AddedSnippets, diff.ChangedSnippets, diff.RemovedSnippets = diffMaps(old, loaded.snippets)
+
+	next := s.clone()
+	if known {
+		delete(next.snippets, oldID)
+		delete(next.sources, file)
+	}
+	v.publishMerged(next, loaded)
+	return diff, nil
+}
+
+// definedElsewhere reports whether a snippet ID is also loaded from a
+// file other than file
+func (s *state) definedElsewhere(id, file string) bool {
+	for other, otherID := range s.sources {
+		if otherID == id && other != file {
+			return true
+		}
+	}
+	return false
+}
+
+// publishMerged adds the groups and snippets of a partially loaded state
+// to next and publishes it
+func (v *Vault) publishMerged(next, loaded *state) {
+	for id, group := range loaded.groups {
+		next.groups[id] = group
+	}
+	for id, snippet := range loaded.snippets {
+		next.snippets[id] = snippet
+	}
+	for file, id := range loaded.sources {
+		next.sources[file] = id
+	}
+	next.reindex()
+	v.current.Store(next)
+}
+
+// mergeDiff combines the diffs of two partial reloads. An ID removed by
+// one and added by the other, like a snippet moved between files, is
+// reported as changed.
+func mergeDiff(a, b types.VaultDiff) types.VaultDiff {
+	diff := types.VaultDiff{
+		SettingsChanged: a.SettingsChanged || b.SettingsChanged,
+		CountersChanged: a.CountersChanged || b.CountersChanged,
+	}
+	diff.AddedSnippets, diff.ChangedSnippets, diff.RemovedSnippets = mergeIDs(
+		[3][]string{a.AddedSnippets, a.ChangedSnippets, a.RemovedSnippets},
+		[3][]string{b.AddedSnippets, b.ChangedSnippets, b.RemovedSnippets})
+	diff.AddedGroups, diff.ChangedGroups, diff.RemovedGroups = mergeIDs(
+		[3][]string{a.AddedGroups, a.ChangedGroups, a.RemovedGroups},
+		[3][]string{b.AddedGroups, b.ChangedGroups, b.RemovedGroups})
+	return diff
+}
+
+// mergeIDs merges two added, changed and removed ID lists
+func mergeIDs(a, b [3][]string) (added, changed, removed []string) {
+	const wasChanged = 1
+	state := make(map[string]int)
+	for _, lists := range [][3][]string{a, b} {
+		for kind, ids := range lists {
+			for _, id := range ids {
+				if previous, seen := state[id]; seen && previous != kind {
+					state[id] = wasChanged
+				} else {
+					state[id] = kind
+				}
+			}
+		}
+	}
+
+	var merged [3][]string
+	for id, kind := range state {
+		merged[kind] = append(merged[kind], id)
+	}
+	for _, ids := range merged {
+		sort.Strings(ids)
+	}
+	return merged[0], merged[1], merged[2]
+}
+
+// WatchedPath reports whether a path relative to the vault root, with
+// forward slashes, holds state that ReloadPaths reloads
+func WatchedPath(rel string) bool {
+	return rel == "settings.yaml" || rel == "counters.json" || rel == "groups" || strings.HasPrefix(rel, "groups/")
+}
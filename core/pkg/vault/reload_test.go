@@ -0,0 +1,233 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/snipq/core/pkg/types"
+)
+
+func TestVaultReload(t *testing.T) {
+	vaultDir := t.TempDir()
+	vault := NewVault()
+	if err := vault.Load(vaultDir); err != nil {
+		t.Fatal(err)
+	}
+	for _, group := range []*types.Group{{ID: "a", Name: "A", Enabled: true}, {ID: "b", Name: "B", Enabled: true}} {
+		if err := vault.UpsertGroup(group); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, snippet := range []*types.Snippet{
+		{ID: "snp_keep", Name: "Keep", Trigger: "keep", Template: "keep", GroupID: "a"},
+		{ID: "snp_edit", Name: "Edit", Trigger: "edit", Template: "before", GroupID: "a"},
+		{ID: "snp_drop", Name: "Drop", Trigger: "drop", Template: "drop", GroupID: "b"},
+	} {
+		if err := vault.UpsertSnippet(snippet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	diff, err := vault.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Errorf("Reload() without edits = %+v, want empty", diff)
+	}
+
+	// Edit files behind the vault's back
+	writeFile := func(rel, content string) {
+		t.Helper()
+		path := filepath.Join(vaultDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("groups/a/snippets/snp_edit.yaml", "id: snp_edit\nname: Edit\ntrigger: edit\ntemplate: after\n")
+	writeFile("groups/c/group.yaml", "id: c\nname: C\n")
+	writeFile("groups/c/snippets/snp_new.yaml", "id: snp_new\nname: New\ntrigger: new\ntemplate: new\n")
+	if err := os.RemoveAll(filepath.Join(vaultDir, "groups", "b")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile("settings.yaml", "prefix: \";;\"\n")
+
+	diff, err = vault.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := types.VaultDiff{
+		AddedSnippets:   []string{"snp_new"},
+		ChangedSnippets: []string{"snp_edit"},
+		RemovedSnippets: []string{"snp_drop"},
+		AddedGroups:     []string{"c"},
+		RemovedGroups:   []string{"b"},
+		SettingsChanged: true,
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("Reload() = %+v, want %+v", diff, want)
+	}
+
+	// Removed snippets are gone rather than merged with the new state
+	if got := vault.FindSnippetByTrigger(";;drop"); got != nil {
+		t.Errorf("removed snippet still found after reload")
+	}
+	if got := vault.FindSnippetByTrigger(";;edit"); got == nil || got.Template != "after" {
+		t.Errorf("FindSnippetByTrigger(;;edit) = %v, want the edited snippet", got)
+	}
+
+	// A broken file leaves the loaded state untouched
+	writeFile("groups/a/snippets/snp_keep.yaml", "id: [unterminated\n")
+	if _, err := vault.Reload(); err == nil {
+		t.Fatal("Reload() with a broken snippet file should fail")
+	}
+	if got := vault.FindSnippetByTrigger(";;keep"); got == nil || got.Template != "keep" {
+		t.Errorf("FindSnippetByTrigger(;;keep) after failed reload = %v, want the old snippet", got)
+	}
+	if got := vault.GetSettings().Prefix; got != ";;" {
+		t.Errorf("prefix after failed reload = %q, want %q", got, ";;")
+	}
+}
+
+func TestVaultReloadWithoutPath(t *testing.T) {
+	if _, err := NewVault().Reload(); err == nil {
+		t.Error("Reload() before Load should fail")
+	}
+}
+
+func TestVaultReloadPaths(t *testing.T) {
+	vaultDir := t.TempDir()
+	vault := NewVault()
+	if err := vault.Load(vaultDir); err != nil {
+		t.Fatal(err)
+	}
+	for _, group := range []*types.Group{{ID: "a", Name: "A", Enabled: true}, {ID: "b", Name: "B", Enabled: true}} {
+		if err := vault.UpsertGroup(group); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, snippet := range []*types.Snippet{
+		{ID: "snp_one", Name: "One", Trigger: "one", Template: "one", GroupID: "a"},
+		{ID: "snp_two", Name: "Two", Trigger: "two", Template: "two", GroupID: "b"},
+	} {
+		if err := vault.UpsertSnippet(snippet); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := vault.UpdateCounter("invoice", &types.Counter{Value: 7, Step: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile := func(rel, content string) {
+		t.Helper()
+		path := filepath.Join(vaultDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The vault's own writes reload to nothing
+	diff, err := vault.ReloadPaths([]string{
+		"counters.json", "history.jsonl",
+		"groups/a/snippets/snp_one.yaml", "groups/b/snippets/snp_two.yaml",
+	})
+	if err != nil || !diff.Empty() {
+		t.Errorf("ReloadPaths(own writes) = %+v, %v; want empty", diff, err)
+	}
+
+	tests := []struct {
+		name  string
+		edit  func()
+		paths []string
+		want  types.VaultDiff
+	}{
+		{
+			name: "snippet edited",
+			edit: func() {
+				writeFile("groups/a/snippets/snp_one.yaml", "id: snp_one\nname: One\ntrigger: one\ntemplate: edited\n")
+			},
+			paths: []string{"groups/a/snippets/snp_one.yaml"},
+			want:  types.VaultDiff{ChangedSnippets: []string{"snp_one"}},
+		},
+		{
+			name: "snippet added under another file name",
+			edit: func() {
+				writeFile("groups/a/snippets/hand/three.yaml", "id: snp_three\nname: Three\ntrigger: three\ntemplate: three\n")
+			},
+			paths: []string{"groups/a/snippets/hand/three.yaml"},
+			want:  types.VaultDiff{AddedSnippets: []string{"snp_three"}},
+		},
+		{
+			name:  "snippet file removed",
+			edit:  func() { os.Remove(filepath.Join(vaultDir, "groups", "a", "snippets", "hand", "three.yaml")) },
+			paths: []string{"groups/a/snippets/hand/three.yaml"},
+			want:  types.VaultDiff{RemovedSnippets: []string{"snp_three"}},
+		},
+		{
+			name:  "group removed",
+			edit:  func() { os.RemoveAll(filepath.Join(vaultDir, "groups", "b")) },
+			paths: []string{"groups/b", "groups/b/snippets/snp_two.yaml"},
+			want:  types.VaultDiff{RemovedSnippets: []string{"snp_two"}, RemovedGroups: []string{"b"}},
+		},
+		{
+			name: "group added",
+			edit: func() {
+				writeFile("groups/c/snippets/four.yaml", "id: snp_four\nname: Four\ntrigger: four\ntemplate: four\n")
+			},
+			paths: []string{"groups/c"},
+			want:  types.VaultDiff{AddedSnippets: []string{"snp_four"}, AddedGroups: []string{"c"}},
+		},
+		{
+			name:  "settings and counters",
+			edit:  func() { writeFile("settings.yaml", "prefix: \";;\"\n"); writeFile("counters.json", "{}") },
+			paths: []string{"settings.yaml", "counters.json"},
+			want:  types.VaultDiff{SettingsChanged: true, CountersChanged: true},
+		},
+	}
+
+	for _, tt := range tests {
+		tt.edit()
+		diff, err := vault.ReloadPaths(tt.paths)
+		if err != nil {
+			t.Errorf("%s: ReloadPaths() error = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(diff, tt.want) {
+			t.Errorf("%s: ReloadPaths() = %+v, want %+v", tt.name, diff, tt.want)
+		}
+	}
+
+	// Partial reloads leave the vault as a full load would
+	fresh := NewVault()
+	if err := fresh.Load(vaultDir); err != nil {
+		t.Fatal(err)
+	}
+	if diff := vault.diff(fresh); !diff.Empty() {
+		t.Errorf("state after partial reloads differs from a full load: %+v", diff)
+	}
+	if got := vault.FindSnippetByTrigger(";;one"); got == nil || got.Template != "edited" {
+		t.Errorf("FindSnippetByTrigger(;;one) = %v, want the edited snippet", got)
+	}
+
+	// A broken snippet keeps its old state and does not block other paths
+	writeFile("groups/a/snippets/snp_one.yaml", "id: [unterminated\n")
+	writeFile("groups/c/snippets/four.yaml", "id: snp_four\nname: Four\ntrigger: four\ntemplate: four, edited\n")
+	diff, err = vault.ReloadPaths([]string{"groups/a/snippets/snp_one.yaml", "groups/c/snippets/four.yaml"})
+	if err == nil {
+		t.Error("ReloadPaths() with a broken snippet file should fail")
+	}
+	if !reflect.DeepEqual(diff.ChangedSnippets, []string{"snp_four"}) {
+		t.Errorf("ReloadPaths() with a broken file = %+v, want snp_four changed", diff)
+	}
+	if got := vault.FindSnippetByTrigger(";;one"); got == nil || got.Template != "edited" {
+		t.Errorf("FindSnippetByTrigger(;;one) after failed reload = %v, want the old snippet", got)
+	}
+}
@@ -0,0 +1,139 @@
+package vault
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/snipq/core/pkg/parser"
+	"github.com/snipq/core/pkg/types"
+)
+
+// state is a snapshot of the vault's groups, snippets and settings with
+// the indexes built from them. A published state is never modified:
+// edits copy it, change the copy and publish that, so readers holding a
+// snapshot never see half an edit and never wait for one. The groups,
+// snippets and settings it points to are shared between snapshots and
+// are replaced rather than modified.
+type state struct {
+	path     string
+	groups   map[string]*types.Group
+	snippets map[string]*types.Snippet
+	settings *types.Settings
+	index    triggerIndex
+	patterns []compiledPattern
+	params   map[string]*regexp.Regexp // param pattern -> compiled by CompileParamPattern
+	sources  map[string]string         // snippet file, relative to the vault root -> snippet ID
+}
+
+func newState(path string) *state {
+	return &state{
+		path:     path,
+		groups:   make(map[string]*types.Group),
+		snippets: make(map[string]*types.Snippet),
+		index:    make(triggerIndex),
+		sources:  make(map[string]string),
+	}
+}
+
+// clone returns a copy of s to edit before publishing it. Callers
+// reindex the copy once they are done.
+func (s *state) clone() *state {
+	next := &state{
+		path:     s.path,
+		groups:   make(map[string]*types.Group, len(s.groups)),
+		snippets: make(map[string]*types.Snippet, len(s.snippets)),
+		settings: s.settings,
+		index:    s.index,
+		patterns: s.patterns,
+		params:   s.params,
+		sources:  make(map[string]string, len(s.sources)),
+	}
+	for id, group := range s.groups {
+		next.groups[id] = group
+	}
+	for id, snippet := range s.snippets {
+		next.snippets[id] = snippet
+	}
+	for file, id := range s.sources {
+		next.sources[file] = id
+	}
+	return next
+}
+
+func (s *state) getSettings() *types.Settings {
+	if s.settings == nil {
+		// Return default settings
+		return &types.Settings{
+			Prefix:            ":",
+			ExpandKey:         "Tab",
+			StrictBoundaries:  true,
+			Locale:            "en-US",
+			DefaultDateFormat: "2006-01-02",
+			Timezone:          "Local",
+			HistoryEnabled:    true,
+			HistoryLimit:      200,
+			PinForSensitive:   true,
+		}
+	}
+	return s.settings
+}
+
+func (s *state) triggerName(trigger string) string {
+	if name, ok := parser.StripPrefix(trigger, s.getSettings().Prefix); ok {
+		return name
+	}
+	return trigger
+}
+
+func (s *state) findByName(name string, opts types.ListOptions) []*types.Snippet {
+	var candidates []*types.Snippet
+	for _, snippet := range s.index[name] {
+		if s.visible(snippet.GroupID, opts) {
+			candidates = append(candidates, snippet)
+		}
+	}
+	return candidates
+}
+
+func (s *state) isGroupEnabled(groupID string) bool {
+	group, exists := s.groups[groupID]
+	return exists && group.Enabled
+}
+
+// visible reports whether snippets of a group are listed with opts
+func (s *state) visible(groupID string, opts types.ListOptions) bool {
+	return opts.IncludeDisabled || s.isGroupEnabled(groupID)
+}
+
+// snippetPath returns the file a snippet is saved to
+func (s *state) snippetPath(snippet *types.Snippet) string {
+	return filepath.Join(s.path, "groups", snippet.GroupID, "snippets", snippet.ID+".yaml")
+}
+
+// sourcePath returns the file a snippet was loaded from, which need not
+// be named after its ID, or the file it is saved to if it has none
+func (s *state) sourcePath(snippet *types.Snippet) string {
+	var files []string
+	for file, id := range s.sources {
+		if id == snippet.ID && strings.HasPrefix(file, "groups/"+snippet.GroupID+"/") {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return s.snippetPath(snippet)
+	}
+	sort.Strings(files)
+	return filepath.Join(s.path, filepath.FromSlash(files[0]))
+}
+
+// relPath returns a path inside the vault relative to its root, with
+// forward slashes
+func (s *state) relPath(file string) string {
+	rel, err := filepath.Rel(s.path, file)
+	if err != nil {
+		return filepath.ToSlash(file)
+	}
+	return filepath.ToSlash(rel)
+}
@@ -3,6 +3,7 @@ package vault
 import (
 	"fmt"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/snipq/core/pkg/types"
@@ -22,7 +23,7 @@ func ValidateSnippet(snippet *types.Snippet) error {
 		return fmt.Errorf("%w: name cannot be empty", ErrInvalidSnippet)
 	}
 
-	if strings.TrimSpace(snippet.Trigger) == "" {
+	if strings.TrimSpace(snippet.Trigger) == "" && snippet.Regex == "" {
 		return fmt.Errorf("%w: trigger cannot be empty", ErrInvalidSnippet)
 	}
 
@@ -39,6 +40,116 @@ func ValidateSnippet(snippet *types.Snippet) error {
 		return fmt.Errorf("%w: trigger cannot contain whitespace", ErrInvalidSnippet)
 	}
 
+	if snippet.Regex != "" {
+		if err := validateRegex(snippet.Regex); err != nil {
+			return err
+		}
+	}
+
+	if err := validateFields(snippet.Fields); err != nil {
+		return err
+	}
+
+	if err := validateParams(snippet.Params); err != nil {
+		return err
+	}
+
+	return validateArgs(snippet)
+}
+
+// validateFields validates a snippet's fill-in field declarations
+func validateFields(fields []types.Field) error {
+	seen := make(map[string]bool, len(fields))
+
+	for _, field := range fields {
+		if strings.TrimSpace(field.Name) == "" {
+			return fmt.Errorf("%w: field name cannot be empty", ErrInvalidSnippet)
+		}
+
+		if seen[field.Name] {
+			return fmt.Errorf("%w: duplicate field '%s'", ErrInvalidSnippet, field.Name)
+		}
+		seen[field.Name] = true
+
+		switch field.Type {
+		case "", types.FieldText, types.FieldCheckbox, types.FieldDate:
+		case types.FieldChoice:
+			if len(field.Options) == 0 {
+				return fmt.Errorf("%w: choice field '%s' needs options", ErrInvalidSnippet, field.Name)
+			}
+		default:
+			return fmt.Errorf("%w: field '%s' has unknown type '%s'", ErrInvalidSnippet, field.Name, field.Type)
+		}
+	}
+
+	return nil
+}
+
+// validateParams validates a snippet's parameter declarations
+func validateParams(params []types.Param) error {
+	seen := make(map[string]bool, len(params))
+
+	for _, param := range params {
+		if strings.TrimSpace(param.Name) == "" {
+			return fmt.Errorf("%w: param name cannot be empty", ErrInvalidSnippet)
+		}
+
+		if seen[param.Name] {
+			return fmt.Errorf("%w: duplicate param '%s'", ErrInvalidSnippet, param.Name)
+		}
+		seen[param.Name] = true
+
+		switch param.Type {
+		case "", types.ParamString, types.ParamInt, types.ParamFloat, types.ParamBool:
+		default:
+			return fmt.Errorf("%w: param '%s' has unknown type '%s'", ErrInvalidSnippet, param.Name, param.Type)
+		}
+
+		if param.Pattern != "" {
+			if _, err := regexp.Compile(param.Pattern); err != nil {
+				return fmt.Errorf("%w: param '%s' has invalid pattern: %v", ErrInvalidSnippet, param.Name, err)
+			}
+		}
+
+		if param.Min != nil && param.Max != nil && *param.Min > *param.Max {
+			return fmt.Errorf("%w: param '%s' has min greater than max", ErrInvalidSnippet, param.Name)
+		}
+	}
+
+	return nil
+}
+
+// validateArgs validates a snippet's positional args and param aliases
+func validateArgs(snippet *types.Snippet) error {
+	names := make(map[string]bool)
+	for _, param := range snippet.Params {
+		names[param.Name] = true
+	}
+	for key := range snippet.Defaults {
+		names[key] = true
+	}
+
+	seen := make(map[string]bool, len(snippet.Args))
+	for _, arg := range snippet.Args {
+		if strings.TrimSpace(arg) == "" {
+			return fmt.Errorf("%w: arg name cannot be empty", ErrInvalidSnippet)
+		}
+		if seen[arg] {
+			return fmt.Errorf("%w: duplicate arg '%s'", ErrInvalidSnippet, arg)
+		}
+		seen[arg] = true
+		names[arg] = true
+	}
+
+	for alias, target := range snippet.Aliases {
+		if strings.TrimSpace(alias) == "" || strings.TrimSpace(target) == "" {
+			return fmt.Errorf("%w: alias and param name cannot be empty", ErrInvalidSnippet)
+		}
+		if names[alias] {
+			return fmt.Errorf("%w: alias '%s' shadows a param", ErrInvalidSnippet, alias)
+		}
+	}
+
 	return nil
 }
 
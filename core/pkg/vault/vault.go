@@ -2,124 +2,203 @@ package vault
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"gopkg.in/yaml.v3"
 
+	"github.com/snipq/core/pkg/parser"
 	"github.com/snipq/core/pkg/types"
 )
 
-// Vault manages the file-based snippet vault
+// ErrCounterConflict is returned by SwapCounters when another update
+// changed one of the counters first
+var ErrCounterConflict = errors.New("counter changed concurrently")
+
+// Vault manages the file-based snippet vault. It is safe for concurrent
+// use. Groups, snippets and settings are kept in a snapshot that edits
+// and reloads replace atomically, so lookups never wait for an edit and
+// always see either all of it or none of it; edits and reloads run one
+// at a time. Counters and history have their own locks, held only while
+// they are read or saved. Between processes, the lock file in the vault
+// root is held for writing while files change and for reading while they
+// load, and counters are re-read under it before they are saved; history
+// entries are appended to history.jsonl under it. Snippets, groups and settings returned by the vault are shared
+// and must not be modified.
 type Vault struct {
-	path     string
-	groups   map[string]*types.Group
-	snippets map[string]*types.Snippet
-	settings *types.Settings
-	counters map[string]*types.Counter
-	history  []*types.HistoryEntry
+	current atomic.Pointer[state]
+	writeMu sync.Mutex // serialises edits and reloads
+
+	counterMu sync.Mutex
+	counters  map[string]*types.Counter
+
+	historyMu    sync.Mutex
+	history      []*types.HistoryEntry
+	historyLines int // entries in history.jsonl when last read or written
 }
 
 // NewVault creates a new vault instance
 func NewVault() *Vault {
-	return &Vault{
-		groups:   make(map[string]*types.Group),
-		snippets: make(map[string]*types.Snippet),
+	v := &Vault{
 		counters: make(map[string]*types.Counter),
 		history:  make([]*types.HistoryEntry, 0),
 	}
+	v.current.Store(newState(""))
+	return v
+}
+
+// snapshot returns the current groups, snippets and settings
+func (v *Vault) snapshot() *state {
+	return v.current.Load()
 }
 
-// Load loads the vault from the specified path
+// Load loads the vault from the specified path. The vault's current
+// state is replaced only if everything loaded.
 func (v *Vault) Load(path string) error {
-	v.path = path
+	v.writeMu.Lock()
+	defer v.writeMu.Unlock()
+
+	return v.loadLocked(path)
+}
 
+func (v *Vault) loadLocked(path string) error {
 	// Ensure vault directory exists
-	if err := os.MkdirAll(path, 0755); err != nil {
+	if err := os.MkdirAll(filepath.Join(path, "groups"), 0755); err != nil {
 		return fmt.Errorf("failed to create vault directory: %w", err)
 	}
 
-	// Load settings
-	if err := v.loadSettings(); err != nil {
-		return fmt.Errorf("failed to load settings: %w", err)
+	// Unlike a reload, loading cleans up after writes interrupted by a
+	// crash, which deletes files, so it holds the lock exclusively
+	unlock, err := lockVault(path, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := removeStaleTempFiles(path, time.Now()); err != nil {
+		return fmt.Errorf("failed to recover interrupted writes: %w", err)
 	}
 
-	// Load counters
-	if err := v.loadCounters(); err != nil {
-		return fmt.Errorf("failed to load counters: %w", err)
+	next := NewVault()
+	if err := next.load(path); err != nil {
+		return err
 	}
 
-	// Load history
-	if err := v.loadHistory(); err != nil {
-		return fmt.Errorf("failed to load history: %w", err)
+	_, err = v.replace(next)
+	return err
+}
+
+// load reads the groups, snippets and settings of the vault at path into
+// an empty vault. Counters and history are read by replace. It writes
+// nothing, so callers need only hold the vault lock for reading.
+func (v *Vault) load(path string) error {
+	s := newState(path)
+
+	// Load settings
+	if err := s.loadSettings(); err != nil {
+		return fmt.Errorf("failed to load settings: %w", err)
 	}
 
 	// Load groups and snippets
-	if err := v.loadGroups(); err != nil {
+	if err := s.loadGroups(); err != nil {
 		return fmt.Errorf("failed to load groups: %w", err)
 	}
 
+	s.reindex()
+	v.current.Store(s)
 	return nil
 }
 
 // Save saves the vault to disk
 func (v *Vault) Save() error {
-	if v.path == "" {
+	s := v.snapshot()
+	if s.path == "" {
 		return fmt.Errorf("vault path not set")
 	}
 
+	unlock, err := lockVault(s.path, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Save settings
-	if err := v.saveSettings(); err != nil {
+	if err := s.saveSettings(); err != nil {
 		return fmt.Errorf("failed to save settings: %w", err)
 	}
 
-	// Save counters
-	if err := v.saveCounters(); err != nil {
+	// Save counters, keeping those other processes saved
+	v.counterMu.Lock()
+	err = v.syncCounters()
+	if err == nil {
+		err = v.saveCounters()
+	}
+	v.counterMu.Unlock()
+	if err != nil {
 		return fmt.Errorf("failed to save counters: %w", err)
 	}
 
-	// Save history
-	if err := v.saveHistory(); err != nil {
+	// Save history, keeping entries other processes added
+	v.historyMu.Lock()
+	err = v.syncHistory()
+	if err == nil {
+		v.trimHistory(s.getSettings().HistoryLimit)
+		err = v.saveHistory()
+	}
+	v.historyMu.Unlock()
+	if err != nil {
 		return fmt.Errorf("failed to save history: %w", err)
 	}
 
 	return nil
 }
 
+// Path returns the directory the vault was loaded from
+func (v *Vault) Path() string {
+	return v.snapshot().path
+}
+
 // GetSettings returns the vault settings
 func (v *Vault) GetSettings() *types.Settings {
-	if v.settings == nil {
-		// Return default settings
-		return &types.Settings{
-			Prefix:            ":",
-			ExpandKey:         "Tab",
-			StrictBoundaries:  true,
-			Locale:            "en-US",
-			DefaultDateFormat: "2006-01-02",
-			Timezone:          "Local",
-			HistoryEnabled:    true,
-			HistoryLimit:      200,
-			PinForSensitive:   true,
-		}
-	}
-	return v.settings
+	return v.snapshot().getSettings()
 }
 
-// SaveSettings saves the settings
+// SaveSettings saves the settings. The vault keeps settings, which must
+// not be modified afterwards.
 func (v *Vault) SaveSettings(settings *types.Settings) error {
-	v.settings = settings
-	return v.saveSettings()
+	v.writeMu.Lock()
+	defer v.writeMu.Unlock()
+
+	unlock, err := lockVault(v.Path(), true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	next := v.snapshot().clone()
+	next.settings = settings
+	next.reindex()
+	v.current.Store(next)
+	return next.saveSettings()
 }
 
-// ListGroups returns all groups sorted by order
-func (v *Vault) ListGroups() []*types.Group {
-	groups := make([]*types.Group, 0, len(v.groups))
-	for _, group := range v.groups {
-		groups = append(groups, group)
+// ListGroups returns the groups sorted by order. Disabled groups are
+// left out unless opts.IncludeDisabled is set.
+func (v *Vault) ListGroups(opts types.ListOptions) []*types.Group {
+	s := v.snapshot()
+	groups := make([]*types.Group, 0, len(s.groups))
+	for _, group := range s.groups {
+		if group.Enabled || opts.IncludeDisabled {
+			groups = append(groups, group)
+		}
 	}
 
 	sort.Slice(groups, func(i, j int) bool {
@@ -132,10 +211,15 @@ func (v *Vault) ListGroups() []*types.Group {
 	return groups
 }
 
-// ListSnippets returns all snippets for a group
-func (v *Vault) ListSnippets(groupID string) []*types.Snippet {
+// ListSnippets returns all snippets for a group. A disabled group lists
+// no snippets unless opts.IncludeDisabled is set.
+func (v *Vault) ListSnippets(groupID string, opts types.ListOptions) []*types.Snippet {
+	s := v.snapshot()
 	snippets := make([]*types.Snippet, 0)
-	for _, snippet := range v.snippets {
+	if !s.visible(groupID, opts) {
+		return snippets
+	}
+	for _, snippet := range s.snippets {
 		if snippet.GroupID == groupID {
 			snippets = append(snippets, snippet)
 		}
@@ -148,91 +232,292 @@ func (v *Vault) ListSnippets(groupID string) []*types.Snippet {
 	return snippets
 }
 
-// FindSnippetByTrigger finds a snippet by its trigger
+// FindSnippetByTrigger finds a snippet in an enabled group by a typed
+// trigger, which must start with the configured prefix (":ty" finds the
+// snippet named "ty"). When several groups use the trigger the first
+// candidate wins.
 func (v *Vault) FindSnippetByTrigger(trigger string) *types.Snippet {
-	for _, snippet := range v.snippets {
-		if snippet.Trigger == trigger {
-			return snippet
+	return first(v.FindSnippetsByTrigger(trigger, types.ListOptions{}))
+}
+
+// FindSnippetsByTrigger returns every snippet a typed trigger refers to,
+// in resolution order: by group order, then by snippet priority (higher
+// first), then by group and snippet ID. Snippets in disabled groups are
+// left out unless opts.IncludeDisabled is set.
+func (v *Vault) FindSnippetsByTrigger(trigger string, opts types.ListOptions) []*types.Snippet {
+	s := v.snapshot()
+	name, ok := parser.StripPrefix(trigger, s.getSettings().Prefix)
+	if !ok {
+		return nil
+	}
+	return s.findByName(name, opts)
+}
+
+// FindSnippetByName finds a snippet in an enabled group by its
+// prefix-less trigger name. Snippets not yet migrated, whose trigger still
+// holds the prefix, match too.
+func (v *Vault) FindSnippetByName(name string) *types.Snippet {
+	return first(v.FindSnippetsByName(name, types.ListOptions{}))
+}
+
+// FindSnippetsByName returns every snippet with a prefix-less trigger
+// name, in the order of FindSnippetsByTrigger
+func (v *Vault) FindSnippetsByName(name string, opts types.ListOptions) []*types.Snippet {
+	return v.snapshot().findByName(name, opts)
+}
+
+// IsGroupEnabled reports whether a group exists and is enabled
+func (v *Vault) IsGroupEnabled(groupID string) bool {
+	return v.snapshot().isGroupEnabled(groupID)
+}
+
+func first(snippets []*types.Snippet) *types.Snippet {
+	if len(snippets) == 0 {
+		return nil
+	}
+	return snippets[0]
+}
+
+// TriggerName returns a stored trigger without the configured prefix
+func (v *Vault) TriggerName(trigger string) string {
+	return v.snapshot().triggerName(trigger)
+}
+
+// MigrateTriggerPrefix rewrites snippets whose trigger starts with prefix
+// to store the prefix-less name, and returns how many were changed
+func (v *Vault) MigrateTriggerPrefix(prefix string) (int, error) {
+	if prefix == "" {
+		return 0, nil
+	}
+
+	v.writeMu.Lock()
+	defer v.writeMu.Unlock()
+
+	unlock, err := lockVault(v.Path(), true)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	next := v.snapshot().clone()
+	ids := make([]string, 0, len(next.snippets))
+	for id := range next.snippets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	migrated := 0
+	for _, id := range ids {
+		name, ok := parser.StripPrefix(next.snippets[id].Trigger, prefix)
+		if !ok {
+			continue
 		}
+
+		snippet := *next.snippets[id]
+		snippet.Trigger = name
+		next.snippets[id] = &snippet
+
+		// Rewrite the file the snippet came from, whatever its name
+		file := next.sourcePath(&snippet)
+		next.sources[next.relPath(file)] = id
+		if err = next.writeSnippet(&snippet, file); err != nil {
+			err = fmt.Errorf("failed to migrate snippet %s: %w", id, err)
+			break
+		}
+		migrated++
 	}
-	return nil
+
+	next.reindex()
+	v.current.Store(next)
+	return migrated, err
 }
 
-// UpsertSnippet adds or updates a snippet
+// UpsertSnippet adds or updates a snippet. The vault keeps snippet,
+// which must not be modified afterwards.
 func (v *Vault) UpsertSnippet(snippet *types.Snippet) error {
 	if err := ValidateSnippet(snippet); err != nil {
 		return err
 	}
 
+	v.writeMu.Lock()
+	defer v.writeMu.Unlock()
+
+	unlock, err := lockVault(v.Path(), true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s := v.snapshot()
+
+	// Store the trigger without the configured prefix
+	snippet.Trigger = s.triggerName(snippet.Trigger)
+
 	// Check for duplicate trigger in the same group (excluding current snippet)
-	if err := v.checkDuplicateTrigger(snippet.Trigger, snippet.GroupID, snippet.ID); err != nil {
+	if err := s.checkDuplicateTrigger(snippet.Trigger, snippet.GroupID, snippet.ID); err != nil {
+		return err
+	}
+	if err := s.checkPatternOverlap(snippet); err != nil {
 		return err
 	}
 
 	// Ensure group exists
-	if _, exists := v.groups[snippet.GroupID]; !exists {
+	if _, exists := s.groups[snippet.GroupID]; !exists {
 		return fmt.Errorf("%w: group '%s' does not exist", ErrInvalidGroup, snippet.GroupID)
 	}
 
-	v.snippets[snippet.ID] = snippet
+	next := s.clone()
+	next.snippets[snippet.ID] = snippet
+	next.sources[next.relPath(next.snippetPath(snippet))] = snippet.ID
+	next.reindex()
+	v.current.Store(next)
 
 	// Save snippet to file
-	return v.saveSnippet(snippet)
+	return next.saveSnippet(snippet)
 }
 
 // DeleteSnippet deletes a snippet
 func (v *Vault) DeleteSnippet(id string) error {
-	snippet, exists := v.snippets[id]
+	v.writeMu.Lock()
+	defer v.writeMu.Unlock()
+
+	unlock, err := lockVault(v.Path(), true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	next := v.snapshot().clone()
+	snippet, exists := next.snippets[id]
 	if !exists {
 		return fmt.Errorf("snippet not found: %s", id)
 	}
 
 	// Delete from memory
-	delete(v.snippets, id)
+	snippetPath := next.snippetPath(snippet)
+	delete(next.snippets, id)
+	delete(next.sources, next.relPath(snippetPath))
+	next.reindex()
+	v.current.Store(next)
 
 	// Delete file
-	snippetPath := filepath.Join(v.path, "groups", snippet.GroupID, "snippets", snippet.ID+".yaml")
 	return os.Remove(snippetPath)
 }
 
-// GetCounter returns a counter value
+// GetCounter returns a counter value. Counters are replaced rather than
+// modified, so the result may be kept and compared with SwapCounters.
 func (v *Vault) GetCounter(name string) *types.Counter {
+	v.counterMu.Lock()
+	defer v.counterMu.Unlock()
+
 	return v.counters[name]
 }
 
 // UpdateCounter updates a counter value
 func (v *Vault) UpdateCounter(name string, counter *types.Counter) error {
+	unlock, err := lockVault(v.Path(), true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	v.counterMu.Lock()
+	defer v.counterMu.Unlock()
+	if err := v.syncCounters(); err != nil {
+		return err
+	}
+
 	v.counters[name] = counter
 	return v.saveCounters()
 }
 
+// SwapCounters stores the counters in next only if each one is still the
+// counter returned by GetCounter when old was read (nil for a counter
+// never used), so two updates racing on a counter never both succeed,
+// whether they run in this process or another one sharing the vault.
+// If any of them changed, nothing is stored and ErrCounterConflict is
+// returned; GetCounter then returns the current value.
+func (v *Vault) SwapCounters(old, next map[string]*types.Counter) error {
+	unlock, err := lockVault(v.Path(), true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	v.counterMu.Lock()
+	defer v.counterMu.Unlock()
+	// Another process may have advanced a counter since it was read
+	if err := v.syncCounters(); err != nil {
+		return err
+	}
+
+	for name := range next {
+		if v.counters[name] != old[name] {
+			return fmt.Errorf("%w: %s", ErrCounterConflict, name)
+		}
+	}
+	for name, counter := range next {
+		v.counters[name] = counter
+	}
+	return v.saveCounters()
+}
+
 // AddHistoryEntry adds an entry to the history
 func (v *Vault) AddHistoryEntry(entry *types.HistoryEntry) error {
-	if !v.GetSettings().HistoryEnabled {
+	settings := v.GetSettings()
+	if !settings.HistoryEnabled {
 		return nil
 	}
 
+	unlock, err := lockVault(v.Path(), true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	v.historyMu.Lock()
+	defer v.historyMu.Unlock()
+
+	// Entries are appended to history.jsonl, which is trimmed back to the
+	// limit, keeping the entries other processes added, once it holds
+	// twice as many
+	limit := settings.HistoryLimit
+	if v.historyLines+1 > 2*limit {
+		if err := v.syncHistory(); err != nil {
+			return err
+		}
+		v.history = append(v.history, entry)
+		v.trimHistory(limit)
+		return v.saveHistory()
+	}
+
+	if err := v.appendHistory(entry); err != nil {
+		return err
+	}
+	v.historyLines++
 	v.history = append(v.history, entry)
+	v.trimHistory(limit)
+	return nil
+}
 
-	// Trim history if it exceeds the limit
-	limit := v.GetSettings().HistoryLimit
+// trimHistory keeps the last limit entries in memory. Callers hold
+// historyMu.
+func (v *Vault) trimHistory(limit int) {
 	if len(v.history) > limit {
 		v.history = v.history[len(v.history)-limit:]
 	}
-
-	return v.saveHistory()
 }
 
 // Private methods
 
-func (v *Vault) loadSettings() error {
-	settingsPath := filepath.Join(v.path, "settings.yaml")
+func (s *state) loadSettings() error {
+	settingsPath := filepath.Join(s.path, "settings.yaml")
 
 	data, err := os.ReadFile(settingsPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// Use default settings
-			v.settings = v.GetSettings()
+			s.settings = s.getSettings()
 			return nil
 		}
 		return err
@@ -243,57 +528,63 @@ func (v *Vault) loadSettings() error {
 		return err
 	}
 
-	v.settings = &settings
+	s.settings = &settings
 	return nil
 }
 
-func (v *Vault) saveSettings() error {
-	settingsPath := filepath.Join(v.path, "settings.yaml")
+func (s *state) saveSettings() error {
+	settingsPath := filepath.Join(s.path, "settings.yaml")
 
-	data, err := yaml.Marshal(v.settings)
+	data, err := yaml.Marshal(s.settings)
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(settingsPath, data, 0600)
+	return writeFileAtomic(settingsPath, data, 0600)
 }
 
-func (v *Vault) loadCounters() error {
-	countersPath := filepath.Join(v.path, "counters.json")
+func readCounters(path string) (map[string]*types.Counter, error) {
+	countersPath := filepath.Join(path, "counters.json")
+	counters := make(map[string]*types.Counter)
 
 	data, err := os.ReadFile(countersPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// No counters file yet
-			return nil
+			return counters, nil
 		}
-		return err
+		return nil, err
 	}
 
-	return json.Unmarshal(data, &v.counters)
+	if err := json.Unmarshal(data, &counters); err != nil {
+		return nil, err
+	}
+	return counters, nil
 }
 
+// saveCounters writes counters.json. Callers hold counterMu.
 func (v *Vault) saveCounters() error {
-	countersPath := filepath.Join(v.path, "counters.json")
+	countersPath := filepath.Join(v.Path(), "counters.json")
 
 	data, err := json.MarshalIndent(v.counters, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(countersPath, data, 0600)
+	return writeFileAtomic(countersPath, data, 0600)
 }
 
-func (v *Vault) loadHistory() error {
-	historyPath := filepath.Join(v.path, "history.jsonl")
+func readHistory(path string) ([]*types.HistoryEntry, error) {
+	historyPath := filepath.Join(path, "history.jsonl")
+	history := make([]*types.HistoryEntry, 0)
 
 	data, err := os.ReadFile(historyPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// No history file yet
-			return nil
+			return history, nil
 		}
-		return err
+		return nil, err
 	}
 
 	lines := strings.Split(string(data), "\n")
@@ -308,14 +599,44 @@ func (v *Vault) loadHistory() error {
 			continue // Skip invalid entries
 		}
 
-		v.history = append(v.history, &entry)
+		history = append(history, &entry)
 	}
 
-	return nil
+	return history, nil
 }
 
+// appendHistory adds entry to the end of history.jsonl without syncing
+// it; losing the last entries in a crash is harmless. A line cut short by
+// an earlier crash is ended first, so it does not swallow this one.
+// Callers hold historyMu.
+func (v *Vault) appendHistory(entry *types.HistoryEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line := append(data, '\n')
+
+	f, err := os.OpenFile(filepath.Join(v.Path(), "history.jsonl"), os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			line = append([]byte{'\n'}, line...)
+		}
+	}
+
+	_, err = f.Write(line)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// saveHistory writes history.jsonl. Callers hold historyMu.
 func (v *Vault) saveHistory() error {
-	historyPath := filepath.Join(v.path, "history.jsonl")
+	historyPath := filepath.Join(v.Path(), "history.jsonl")
 
 	var lines []string
 	for _, entry := range v.history {
@@ -326,61 +647,66 @@ func (v *Vault) saveHistory() error {
 		lines = append(lines, string(data))
 	}
 
-	return os.WriteFile(historyPath, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+	if err := writeFileAtomic(historyPath, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		return err
+	}
+	v.historyLines = len(lines)
+	return nil
 }
 
-func (v *Vault) loadGroups() error {
-	groupsDir := filepath.Join(v.path, "groups")
-
-	if err := os.MkdirAll(groupsDir, 0755); err != nil {
+func (s *state) loadGroups() error {
+	entries, err := os.ReadDir(filepath.Join(s.path, "groups"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
 		return err
 	}
 
-	return filepath.WalkDir(groupsDir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
 		}
-
-		if d.IsDir() && d.Name() != "groups" {
-			return v.loadGroup(d.Name())
+		if err := s.loadGroup(entry.Name()); err != nil {
+			return fmt.Errorf("group %s: %w", entry.Name(), err)
 		}
+	}
 
-		return nil
-	})
+	return nil
 }
 
-func (v *Vault) loadGroup(groupID string) error {
-	groupPath := filepath.Join(v.path, "groups", groupID, "group.yaml")
+func (s *state) loadGroup(groupID string) error {
+	groupPath := filepath.Join(s.path, "groups", groupID, "group.yaml")
 
 	data, err := os.ReadFile(groupPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			// Create default group
-			group := &types.Group{
+			// Use a default group; group.yaml is written when it is edited
+			s.groups[groupID] = &types.Group{
 				ID:      groupID,
 				Name:    groupID,
 				Enabled: true,
 			}
-			v.groups[groupID] = group
-			return v.saveGroup(group)
+			return s.loadSnippetsForGroup(groupID)
 		}
 		return err
 	}
 
-	var group types.Group
+	// Groups are enabled unless group.yaml says otherwise
+	group := types.Group{Enabled: true}
 	if err := yaml.Unmarshal(data, &group); err != nil {
 		return err
 	}
 
 	group.ID = groupID
-	v.groups[groupID] = &group
+	s.groups[groupID] = &group
 
 	// Load snippets for this group
-	return v.loadSnippetsForGroup(groupID)
+	return s.loadSnippetsForGroup(groupID)
 }
 
-func (v *Vault) saveGroup(group *types.Group) error {
-	groupDir := filepath.Join(v.path, "groups", group.ID)
+func (s *state) saveGroup(group *types.Group) error {
+	groupDir := filepath.Join(s.path, "groups", group.ID)
 	if err := os.MkdirAll(groupDir, 0755); err != nil {
 		return err
 	}
@@ -391,11 +717,11 @@ func (v *Vault) saveGroup(group *types.Group) error {
 		return err
 	}
 
-	return os.WriteFile(groupPath, data, 0600)
+	return writeFileAtomic(groupPath, data, 0600)
 }
 
-func (v *Vault) loadSnippetsForGroup(groupID string) error {
-	snippetsDir := filepath.Join(v.path, "groups", groupID, "snippets")
+func (s *state) loadSnippetsForGroup(groupID string) error {
+	snippetsDir := filepath.Join(s.path, "groups", groupID, "snippets")
 
 	if err := os.MkdirAll(snippetsDir, 0755); err != nil {
 		return err
@@ -407,14 +733,14 @@ func (v *Vault) loadSnippetsForGroup(groupID string) error {
 		}
 
 		if !d.IsDir() && strings.HasSuffix(d.Name(), ".yaml") {
-			return v.loadSnippet(path, groupID)
+			return s.loadSnippet(path, groupID)
 		}
 
 		return nil
 	})
 }
 
-func (v *Vault) loadSnippet(path, groupID string) error {
+func (s *state) loadSnippet(path, groupID string) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return err
@@ -422,34 +748,40 @@ func (v *Vault) loadSnippet(path, groupID string) error {
 
 	var snippet types.Snippet
 	if err := yaml.Unmarshal(data, &snippet); err != nil {
-		return err
+		return fmt.Errorf("%s: %w", path, err)
 	}
 
 	snippet.GroupID = groupID
-	v.snippets[snippet.ID] = &snippet
+	s.snippets[snippet.ID] = &snippet
+	s.sources[s.relPath(path)] = snippet.ID
 
 	return nil
 }
 
-func (v *Vault) saveSnippet(snippet *types.Snippet) error {
-	snippetDir := filepath.Join(v.path, "groups", snippet.GroupID, "snippets")
-	if err := os.MkdirAll(snippetDir, 0755); err != nil {
+func (s *state) saveSnippet(snippet *types.Snippet) error {
+	return s.writeSnippet(snippet, s.snippetPath(snippet))
+}
+
+func (s *state) writeSnippet(snippet *types.Snippet, snippetPath string) error {
+	if err := os.MkdirAll(filepath.Dir(snippetPath), 0755); err != nil {
 		return err
 	}
 
-	snippetPath := filepath.Join(snippetDir, snippet.ID+".yaml")
 	data, err := yaml.Marshal(snippet)
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(snippetPath, data, 0600)
+	return writeFileAtomic(snippetPath, data, 0600)
 }
 
 // checkDuplicateTrigger checks if a trigger already exists in the group
-func (v *Vault) checkDuplicateTrigger(trigger, groupID, excludeSnippetID string) error {
-	for _, snippet := range v.snippets {
-		if snippet.GroupID == groupID && snippet.Trigger == trigger && snippet.ID != excludeSnippetID {
+func (s *state) checkDuplicateTrigger(trigger, groupID, excludeSnippetID string) error {
+	if trigger == "" {
+		return nil
+	}
+	for _, snippet := range s.index[trigger] {
+		if snippet.GroupID == groupID && snippet.ID != excludeSnippetID {
 			return fmt.Errorf("%w: trigger '%s' already exists in group '%s' (snippet: %s)",
 				ErrDuplicateTrigger, trigger, groupID, snippet.ID)
 		}
@@ -463,13 +795,25 @@ func (v *Vault) CreateGroup(group *types.Group) error {
 		return err
 	}
 
+	v.writeMu.Lock()
+	defer v.writeMu.Unlock()
+
+	unlock, err := lockVault(v.Path(), true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Check for duplicate ID
-	if _, exists := v.groups[group.ID]; exists {
+	next := v.snapshot().clone()
+	if _, exists := next.groups[group.ID]; exists {
 		return fmt.Errorf("%w: group with ID '%s' already exists", ErrDuplicateGroup, group.ID)
 	}
 
-	v.groups[group.ID] = group
-	return v.saveGroup(group)
+	next.groups[group.ID] = group
+	next.reindex()
+	v.current.Store(next)
+	return next.saveGroup(group)
 }
 
 // UpsertGroup adds or updates a group
@@ -478,34 +822,63 @@ func (v *Vault) UpsertGroup(group *types.Group) error {
 		return err
 	}
 
-	v.groups[group.ID] = group
-	return v.saveGroup(group)
+	v.writeMu.Lock()
+	defer v.writeMu.Unlock()
+
+	unlock, err := lockVault(v.Path(), true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	next := v.snapshot().clone()
+	next.groups[group.ID] = group
+	next.reindex()
+	v.current.Store(next)
+	return next.saveGroup(group)
 }
 
 // DeleteGroup deletes a group and all its snippets
 func (v *Vault) DeleteGroup(groupID string) error {
-	if _, exists := v.groups[groupID]; !exists {
+	v.writeMu.Lock()
+	defer v.writeMu.Unlock()
+
+	unlock, err := lockVault(v.Path(), true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	next := v.snapshot().clone()
+	if _, exists := next.groups[groupID]; !exists {
 		return fmt.Errorf("%w: group '%s' not found", ErrInvalidGroup, groupID)
 	}
 
 	// Delete all snippets in the group
-	for snippetID, snippet := range v.snippets {
+	for snippetID, snippet := range next.snippets {
 		if snippet.GroupID == groupID {
-			delete(v.snippets, snippetID)
+			delete(next.snippets, snippetID)
 		}
 	}
 
 	// Delete from memory
-	delete(v.groups, groupID)
+	delete(next.groups, groupID)
+	for file := range next.sources {
+		if strings.HasPrefix(file, "groups/"+groupID+"/") {
+			delete(next.sources, file)
+		}
+	}
+	next.reindex()
+	v.current.Store(next)
 
 	// Delete directory
-	groupDir := filepath.Join(v.path, "groups", groupID)
+	groupDir := filepath.Join(next.path, "groups", groupID)
 	return os.RemoveAll(groupDir)
 }
 
 // GetSnippet returns a snippet by ID
 func (v *Vault) GetSnippet(id string) (*types.Snippet, error) {
-	snippet, exists := v.snippets[id]
+	snippet, exists := v.snapshot().snippets[id]
 	if !exists {
 		return nil, fmt.Errorf("snippet not found: %s", id)
 	}
@@ -514,18 +887,22 @@ func (v *Vault) GetSnippet(id string) (*types.Snippet, error) {
 
 // GetGroup returns a group by ID
 func (v *Vault) GetGroup(id string) (*types.Group, error) {
-	group, exists := v.groups[id]
+	group, exists := v.snapshot().groups[id]
 	if !exists {
 		return nil, fmt.Errorf("group not found: %s", id)
 	}
 	return group, nil
 }
 
-// ListAllSnippets returns all snippets across all groups
-func (v *Vault) ListAllSnippets() []*types.Snippet {
-	snippets := make([]*types.Snippet, 0, len(v.snippets))
-	for _, snippet := range v.snippets {
-		snippets = append(snippets, snippet)
+// ListAllSnippets returns all snippets across all groups. Snippets in
+// disabled groups are left out unless opts.IncludeDisabled is set.
+func (v *Vault) ListAllSnippets(opts types.ListOptions) []*types.Snippet {
+	s := v.snapshot()
+	snippets := make([]*types.Snippet, 0, len(s.snippets))
+	for _, snippet := range s.snippets {
+		if s.visible(snippet.GroupID, opts) {
+			snippets = append(snippets, snippet)
+		}
 	}
 
 	sort.Slice(snippets, func(i, j int) bool {
@@ -538,12 +915,19 @@ func (v *Vault) ListAllSnippets() []*types.Snippet {
 	return snippets
 }
 
-// SearchSnippets searches for snippets by name, trigger, or tags
-func (v *Vault) SearchSnippets(query string) []*types.Snippet {
+// SearchSnippets searches for snippets by name, trigger, or tags.
+// Snippets in disabled groups are left out unless opts.IncludeDisabled is
+// set.
+func (v *Vault) SearchSnippets(query string, opts types.ListOptions) []*types.Snippet {
+	s := v.snapshot()
 	query = strings.ToLower(query)
 	var results []*types.Snippet
 
-	for _, snippet := range v.snippets {
+	for _, snippet := range s.snippets {
+		if !s.visible(snippet.GroupID, opts) {
+			continue
+		}
+
 		// Search in name, trigger, and tags
 		if strings.Contains(strings.ToLower(snippet.Name), query) ||
 			strings.Contains(strings.ToLower(snippet.Trigger), query) ||
@@ -571,11 +955,25 @@ func (v *Vault) searchInTags(tags []string, query string) bool {
 
 // GetHistory returns the expansion history
 func (v *Vault) GetHistory() []*types.HistoryEntry {
-	return v.history
+	v.historyMu.Lock()
+	defer v.historyMu.Unlock()
+
+	history := make([]*types.HistoryEntry, len(v.history))
+	copy(history, v.history)
+	return history
 }
 
 // ClearHistory clears the expansion history
 func (v *Vault) ClearHistory() error {
+	unlock, err := lockVault(v.Path(), true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	v.historyMu.Lock()
+	defer v.historyMu.Unlock()
+
 	v.history = make([]*types.HistoryEntry, 0)
 	return v.saveHistory()
 }
@@ -1,10 +1,15 @@
 package vault
 
 import (
+	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/snipq/core/pkg/lockfile"
 	"github.com/snipq/core/pkg/types"
 )
 
@@ -62,6 +67,151 @@ func TestVaultValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid fields",
+			snippet: &types.Snippet{
+				ID:       "test1",
+				Name:     "Test",
+				Trigger:  "test",
+				Template: "Hello {{.name}}",
+				GroupID:  "group1",
+				Fields: []types.Field{
+					{Name: "name", Required: true},
+					{Name: "tone", Type: types.FieldChoice, Options: []string{"formal", "casual"}},
+					{Name: "urgent", Type: types.FieldCheckbox},
+					{Name: "due", Type: types.FieldDate},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "duplicate field",
+			snippet: &types.Snippet{
+				ID:       "test1",
+				Name:     "Test",
+				Trigger:  "test",
+				Template: "Hello",
+				GroupID:  "group1",
+				Fields:   []types.Field{{Name: "name"}, {Name: "name"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown field type",
+			snippet: &types.Snippet{
+				ID:       "test1",
+				Name:     "Test",
+				Trigger:  "test",
+				Template: "Hello",
+				GroupID:  "group1",
+				Fields:   []types.Field{{Name: "name", Type: "color"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid params",
+			snippet: &types.Snippet{
+				ID:       "test1",
+				Name:     "Test",
+				Trigger:  "test",
+				Template: "Hello",
+				GroupID:  "group1",
+				Strict:   true,
+				Params: []types.Param{
+					{Name: "lang", Enum: []string{"en", "vi"}},
+					{Name: "pad", Type: types.ParamInt, Min: floatPtr(1), Max: floatPtr(9)},
+					{Name: "code", Pattern: "[A-Z]{3}"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid param pattern",
+			snippet: &types.Snippet{
+				ID:       "test1",
+				Name:     "Test",
+				Trigger:  "test",
+				Template: "Hello",
+				GroupID:  "group1",
+				Params:   []types.Param{{Name: "code", Pattern: "[A-Z"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "param min greater than max",
+			snippet: &types.Snippet{
+				ID:       "test1",
+				Name:     "Test",
+				Trigger:  "test",
+				Template: "Hello",
+				GroupID:  "group1",
+				Params:   []types.Param{{Name: "pad", Type: types.ParamInt, Min: floatPtr(5), Max: floatPtr(1)}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown param type",
+			snippet: &types.Snippet{
+				ID:       "test1",
+				Name:     "Test",
+				Trigger:  "test",
+				Template: "Hello",
+				GroupID:  "group1",
+				Params:   []types.Param{{Name: "pad", Type: "number"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid args and aliases",
+			snippet: &types.Snippet{
+				ID:       "test1",
+				Name:     "Test",
+				Trigger:  "test",
+				Template: "Hello",
+				GroupID:  "group1",
+				Defaults: map[string]any{"lang": "en", "tone": "neutral"},
+				Args:     []string{"lang", "tone"},
+				Aliases:  map[string]string{"l": "lang", "t": "tone"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "duplicate arg",
+			snippet: &types.Snippet{
+				ID:       "test1",
+				Name:     "Test",
+				Trigger:  "test",
+				Template: "Hello",
+				GroupID:  "group1",
+				Args:     []string{"lang", "lang"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "alias shadows a param",
+			snippet: &types.Snippet{
+				ID:       "test1",
+				Name:     "Test",
+				Trigger:  "test",
+				Template: "Hello",
+				GroupID:  "group1",
+				Defaults: map[string]any{"lang": "en", "tone": "neutral"},
+				Aliases:  map[string]string{"tone": "lang"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "choice without options",
+			snippet: &types.Snippet{
+				ID:       "test1",
+				Name:     "Test",
+				Trigger:  "test",
+				Template: "Hello",
+				GroupID:  "group1",
+				Fields:   []types.Field{{Name: "tone", Type: types.FieldChoice}},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -74,6 +224,10 @@ func TestVaultValidation(t *testing.T) {
 	}
 }
 
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
 func TestVaultGroupValidation(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -182,7 +336,7 @@ func TestVaultCRUDOperations(t *testing.T) {
 	}
 
 	// Test search functionality
-	results := vault.SearchSnippets("test")
+	results := vault.SearchSnippets("test", types.ListOptions{})
 	if len(results) != 1 {
 		t.Errorf("SearchSnippets() found %d results, want 1", len(results))
 	}
@@ -363,3 +517,442 @@ func TestVaultSettingsValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestVaultTriggerPrefix(t *testing.T) {
+	vault := NewVault()
+	if err := vault.Load(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.UpsertGroup(&types.Group{ID: "g", Name: "G", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Upserted triggers are stored without the prefix
+	snippet := &types.Snippet{ID: "snp_ty", Name: "Thanks", Trigger: ":ty", Template: "Thanks", GroupID: "g"}
+	if err := vault.UpsertSnippet(snippet); err != nil {
+		t.Fatal(err)
+	}
+	if snippet.Trigger != "ty" {
+		t.Errorf("stored trigger = %q, want %q", snippet.Trigger, "ty")
+	}
+
+	// A snippet from an unmigrated vault still holds the prefix
+	unmigrated := vault.snapshot().clone()
+	unmigrated.snippets["snp_sig"] = &types.Snippet{ID: "snp_sig", Name: "Sig", Trigger: ":sig", Template: "An", GroupID: "g"}
+	unmigrated.reindex()
+	vault.current.Store(unmigrated)
+
+	dup := &types.Snippet{ID: "snp_sig2", Name: "Sig 2", Trigger: "sig", Template: "Binh", GroupID: "g"}
+	if err := vault.UpsertSnippet(dup); !errors.Is(err, ErrDuplicateTrigger) {
+		t.Errorf("UpsertSnippet() error = %v, want ErrDuplicateTrigger", err)
+	}
+
+	tests := []struct {
+		trigger string
+		want    string
+	}{
+		{":ty", "snp_ty"},
+		{":sig", "snp_sig"},
+		{"ty", ""},
+		{":", ""},
+		{";;ty", ""},
+	}
+	for _, tt := range tests {
+		got := vault.FindSnippetByTrigger(tt.trigger)
+		if (got == nil && tt.want != "") || (got != nil && got.ID != tt.want) {
+			t.Errorf("FindSnippetByTrigger(%q) = %v, want %q", tt.trigger, got, tt.want)
+		}
+	}
+
+	migrated, err := vault.MigrateTriggerPrefix(":")
+	if err != nil {
+		t.Fatalf("MigrateTriggerPrefix() error = %v", err)
+	}
+	if sig, _ := vault.GetSnippet("snp_sig"); migrated != 1 || sig.Trigger != "sig" {
+		t.Errorf("MigrateTriggerPrefix() = %d, trigger %q; want 1, %q", migrated, sig.Trigger, "sig")
+	}
+
+	// Switching the prefix needs no snippet changes
+	settings := *vault.GetSettings()
+	settings.Prefix = ";;"
+	if err := vault.SaveSettings(&settings); err != nil {
+		t.Fatal(err)
+	}
+	if got := vault.FindSnippetByTrigger(";;sig"); got == nil || got.ID != "snp_sig" {
+		t.Errorf("FindSnippetByTrigger(;;sig) = %v, want snp_sig", got)
+	}
+	if got := vault.FindSnippetByTrigger(":sig"); got != nil {
+		t.Errorf("FindSnippetByTrigger(:sig) = %v, want nil", got.ID)
+	}
+}
+
+func TestVaultTriggerResolutionOrder(t *testing.T) {
+	vault := NewVault()
+	if err := vault.Load(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	groups := []*types.Group{
+		{ID: "work", Name: "Work", Order: 20, Enabled: true},
+		{ID: "personal", Name: "Personal", Order: 10, Enabled: true},
+		{ID: "shared", Name: "Shared", Order: 20, Enabled: true},
+	}
+	for _, group := range groups {
+		if err := vault.UpsertGroup(group); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snippets := []*types.Snippet{
+		{ID: "snp_work", Name: "Work", Trigger: "sig", Template: "Work", GroupID: "work"},
+		{ID: "snp_shared", Name: "Shared", Trigger: "sig", Template: "Shared", GroupID: "shared", Priority: 5},
+		{ID: "snp_personal", Name: "Personal", Trigger: "sig", Template: "Personal", GroupID: "personal"},
+		{ID: "snp_other", Name: "Other", Trigger: "other", Template: "Other", GroupID: "work"},
+	}
+	for _, snippet := range snippets {
+		if err := vault.UpsertSnippet(snippet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := func(found []*types.Snippet) []string {
+		result := make([]string, len(found))
+		for i, snippet := range found {
+			result[i] = snippet.ID
+		}
+		return result
+	}
+
+	want := []string{"snp_personal", "snp_shared", "snp_work"}
+	for i := 0; i < 20; i++ {
+		if got := ids(vault.FindSnippetsByTrigger(":sig", types.ListOptions{})); !reflect.DeepEqual(got, want) {
+			t.Fatalf("FindSnippetsByTrigger(:sig) = %v, want %v", got, want)
+		}
+	}
+
+	// Reordering groups re-resolves the trigger
+	groups[1].Order = 30
+	if err := vault.UpsertGroup(groups[1]); err != nil {
+		t.Fatal(err)
+	}
+	if got := vault.FindSnippetByTrigger(":sig"); got == nil || got.ID != "snp_shared" {
+		t.Errorf("FindSnippetByTrigger(:sig) = %v, want snp_shared", got)
+	}
+
+	// Deleting a snippet removes it from the index
+	if err := vault.DeleteSnippet("snp_shared"); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(vault.FindSnippetsByTrigger(":sig", types.ListOptions{})); !reflect.DeepEqual(got, []string{"snp_work", "snp_personal"}) {
+		t.Errorf("FindSnippetsByTrigger(:sig) after delete = %v", got)
+	}
+
+	// Reloading from disk gives the same order
+	reloaded := NewVault()
+	if err := reloaded.Load(vault.Path()); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(reloaded.FindSnippetsByTrigger(":sig", types.ListOptions{})); !reflect.DeepEqual(got, []string{"snp_work", "snp_personal"}) {
+		t.Errorf("FindSnippetsByTrigger(:sig) after reload = %v", got)
+	}
+}
+
+func TestVaultDisabledGroups(t *testing.T) {
+	vaultDir := t.TempDir()
+	vault := NewVault()
+	if err := vault.Load(vaultDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vault.UpsertGroup(&types.Group{ID: "on", Name: "On", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.UpsertGroup(&types.Group{ID: "off", Name: "Off", Enabled: false}); err != nil {
+		t.Fatal(err)
+	}
+	for _, snippet := range []*types.Snippet{
+		{ID: "snp_on", Name: "Sig on", Trigger: "sig", Template: "On", GroupID: "on"},
+		{ID: "snp_off", Name: "Sig off", Trigger: "sig", Template: "Off", GroupID: "off"},
+		{ID: "snp_only_off", Name: "Only off", Trigger: "only", Template: "Off", GroupID: "off"},
+	} {
+		if err := vault.UpsertSnippet(snippet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all := types.ListOptions{IncludeDisabled: true}
+	if got := len(vault.ListGroups(types.ListOptions{})); got != 1 {
+		t.Errorf("ListGroups() = %d groups, want 1", got)
+	}
+	if got := len(vault.ListGroups(all)); got != 2 {
+		t.Errorf("ListGroups(all) = %d groups, want 2", got)
+	}
+	if got := len(vault.ListSnippets("off", types.ListOptions{})); got != 0 {
+		t.Errorf("ListSnippets(off) = %d snippets, want 0", got)
+	}
+	if got := len(vault.ListSnippets("off", all)); got != 2 {
+		t.Errorf("ListSnippets(off, all) = %d snippets, want 2", got)
+	}
+	if got := len(vault.ListAllSnippets(types.ListOptions{})); got != 1 {
+		t.Errorf("ListAllSnippets() = %d snippets, want 1", got)
+	}
+	if got := len(vault.SearchSnippets("sig", types.ListOptions{})); got != 1 {
+		t.Errorf("SearchSnippets(sig) = %d results, want 1", got)
+	}
+	if got := len(vault.SearchSnippets("sig", all)); got != 2 {
+		t.Errorf("SearchSnippets(sig, all) = %d results, want 2", got)
+	}
+	if got := vault.FindSnippetByTrigger(":only"); got != nil {
+		t.Errorf("FindSnippetByTrigger(:only) = %s, want nil", got.ID)
+	}
+	if got := vault.FindSnippetsByTrigger(":only", all); len(got) != 1 {
+		t.Errorf("FindSnippetsByTrigger(:only, all) = %d snippets, want 1", len(got))
+	}
+
+	// group.yaml without an enabled key loads as enabled
+	groupPath := filepath.Join(vaultDir, "groups", "legacy", "group.yaml")
+	if err := os.MkdirAll(filepath.Dir(groupPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(groupPath, []byte("id: legacy\nname: Legacy\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := NewVault()
+	if err := reloaded.Load(vaultDir); err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded.IsGroupEnabled("legacy") {
+		t.Error("group without an enabled key should load as enabled")
+	}
+	if reloaded.IsGroupEnabled("off") {
+		t.Error("disabled group should stay disabled after reload")
+	}
+}
+
+func TestVaultReadsDuringEdits(t *testing.T) {
+	vault := NewVault()
+	if err := vault.Load(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.UpsertGroup(&types.Group{ID: "g", Name: "G", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.UpsertSnippet(&types.Snippet{ID: "snp_ty", Name: "Thanks", Trigger: "ty", Template: "Thanks", GroupID: "g"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Lookups use the published snapshot while an edit holds the write lock
+	vault.writeMu.Lock()
+	done := make(chan *types.Snippet)
+	go func() { done <- vault.FindSnippetByTrigger(":ty") }()
+	if got := <-done; got == nil || got.ID != "snp_ty" {
+		t.Errorf("FindSnippetByTrigger(:ty) during an edit = %v", got)
+	}
+	vault.writeMu.Unlock()
+
+	// A snapshot is unaffected by later edits
+	before := vault.snapshot()
+	if err := vault.DeleteSnippet("snp_ty"); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := before.snippets["snp_ty"]; !exists || len(before.index["ty"]) != 1 {
+		t.Error("DeleteSnippet() modified an earlier snapshot")
+	}
+	if got := vault.FindSnippetByTrigger(":ty"); got != nil {
+		t.Errorf("FindSnippetByTrigger(:ty) after delete = %v", got)
+	}
+}
+
+func TestVaultHistoryAppends(t *testing.T) {
+	vault := NewVault()
+	if err := vault.Load(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.SaveSettings(&types.Settings{Prefix: ":", HistoryEnabled: true, HistoryLimit: 3}); err != nil {
+		t.Fatal(err)
+	}
+	historyPath := filepath.Join(vault.Path(), "history.jsonl")
+	add := func(id string) {
+		t.Helper()
+		if err := vault.AddHistoryEntry(&types.HistoryEntry{SnippetID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids := func(history []*types.HistoryEntry) []string {
+		var result []string
+		for _, entry := range history {
+			result = append(result, entry.SnippetID)
+		}
+		return result
+	}
+
+	// Entries are appended past the limit, and a line cut short by a
+	// crash does not swallow the next one
+	add("a")
+	add("b")
+	f, err := os.OpenFile(historyPath, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"snippetId":"c`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	add("d")
+	add("e")
+	if got := ids(vault.GetHistory()); !reflect.DeepEqual(got, []string{"b", "d", "e"}) {
+		t.Errorf("GetHistory() = %v, want [b d e]", got)
+	}
+	history, err := readHistory(vault.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(history); !reflect.DeepEqual(got, []string{"a", "b", "d", "e"}) {
+		t.Errorf("history.jsonl = %v, want [a b d e]", got)
+	}
+
+	// Past twice the limit the file is trimmed, keeping what another
+	// process appended
+	add("f")
+	add("g")
+	other := NewVault()
+	if err := other.Load(vault.Path()); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.AddHistoryEntry(&types.HistoryEntry{SnippetID: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	add("h")
+	history, err = readHistory(vault.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(history); !reflect.DeepEqual(got, []string{"g", "x", "h"}) {
+		t.Errorf("history.jsonl after trimming = %v, want [g x h]", got)
+	}
+	if got := ids(vault.GetHistory()); !reflect.DeepEqual(got, []string{"g", "x", "h"}) {
+		t.Errorf("GetHistory() after trimming = %v, want [g x h]", got)
+	}
+}
+
+func TestVaultSwapCounters(t *testing.T) {
+	vault := NewVault()
+	if err := vault.Load(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	first := &types.Counter{Value: 1, Step: 1}
+	if err := vault.SwapCounters(map[string]*types.Counter{"inv": nil}, map[string]*types.Counter{"inv": first}); err != nil {
+		t.Fatalf("SwapCounters() on a new counter error = %v", err)
+	}
+
+	// A second update based on the same read loses
+	stale := &types.Counter{Value: 1, Step: 1}
+	if err := vault.SwapCounters(map[string]*types.Counter{"inv": nil}, map[string]*types.Counter{"inv": stale}); !errors.Is(err, ErrCounterConflict) {
+		t.Errorf("SwapCounters() from a stale read error = %v, want ErrCounterConflict", err)
+	}
+	if got := vault.GetCounter("inv"); got != first {
+		t.Errorf("GetCounter(inv) = %+v after a conflict, want the first update", got)
+	}
+
+	second := &types.Counter{Value: 2, Step: 1}
+	if err := vault.SwapCounters(map[string]*types.Counter{"inv": first}, map[string]*types.Counter{"inv": second}); err != nil {
+		t.Fatalf("SwapCounters() error = %v", err)
+	}
+
+	reloaded := NewVault()
+	if err := reloaded.Load(vault.Path()); err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.GetCounter("inv"); got == nil || got.Value != 2 {
+		t.Errorf("saved counter = %+v, want value 2", got)
+	}
+
+	// Another process sharing the vault advances the counter: the stale
+	// update loses and the counter read next is the other process's
+	third := &types.Counter{Value: 3, Step: 1}
+	if err := reloaded.SwapCounters(map[string]*types.Counter{"inv": reloaded.GetCounter("inv")}, map[string]*types.Counter{"inv": third}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.SwapCounters(map[string]*types.Counter{"inv": second}, map[string]*types.Counter{"inv": third}); !errors.Is(err, ErrCounterConflict) {
+		t.Errorf("SwapCounters() after another process's update error = %v, want ErrCounterConflict", err)
+	}
+	if got := vault.GetCounter("inv"); got == nil || got.Value != 3 {
+		t.Errorf("GetCounter(inv) after a conflict = %+v, want value 3", got)
+	}
+}
+
+func TestVaultLock(t *testing.T) {
+	original := lockOptions
+	lockOptions = lockfile.Options{Timeout: 50 * time.Millisecond, RetryInterval: time.Millisecond}
+	t.Cleanup(func() { lockOptions = original })
+
+	vault := NewVault()
+	if err := vault.Load(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.CreateGroup(&types.Group{ID: "g", Name: "G", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another live process on this host holds the lock
+	hostname, _ := os.Hostname()
+	data, err := json.Marshal(lockfile.Owner{PID: os.Getppid(), Hostname: hostname, Acquired: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockPath := filepath.Join(vault.Path(), LockFile)
+	if err := os.WriteFile(lockPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	snippet := &types.Snippet{ID: "snp_sig", GroupID: "g", Name: "Signature", Trigger: "sig", Template: "-- An"}
+	if err := vault.UpsertSnippet(snippet); !errors.Is(err, lockfile.ErrLocked) {
+		t.Errorf("UpsertSnippet() while locked error = %v, want ErrLocked", err)
+	}
+	if _, err := vault.GetSnippet("snp_sig"); err == nil {
+		t.Error("snippet added although the vault was locked")
+	}
+	if err := vault.UpdateCounter("inv", &types.Counter{Value: 1, Step: 1}); !errors.Is(err, lockfile.ErrLocked) {
+		t.Errorf("UpdateCounter() while locked error = %v, want ErrLocked", err)
+	}
+	if _, err := vault.Reload(); !errors.Is(err, lockfile.ErrLocked) {
+		t.Errorf("Reload() while locked error = %v, want ErrLocked", err)
+	}
+
+	if err := os.Remove(lockPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// A reader in another process lets reloads, which write nothing, go
+	// ahead, but holds up loads, which clean up after interrupted writes
+	readerPath := lockPath + ".r-1"
+	if err := os.WriteFile(readerPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(vault.Path(), "groups", "bare"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vault.Reload(); err != nil {
+		t.Fatalf("Reload() while read-locked error = %v", err)
+	}
+	if !vault.IsGroupEnabled("bare") {
+		t.Error("group directory without group.yaml not loaded")
+	}
+	if _, err := os.Stat(filepath.Join(vault.Path(), "groups", "bare", "group.yaml")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Reload() wrote group.yaml: %v", err)
+	}
+	if err := vault.Load(vault.Path()); !errors.Is(err, lockfile.ErrLocked) {
+		t.Errorf("Load() while read-locked error = %v, want ErrLocked", err)
+	}
+	if err := os.Remove(readerPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vault.UpsertSnippet(snippet); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(lockPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("lock file left after a write: %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+//go:build linux
+
+package watch
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ONLYDIR
+
+// inotify watches each included directory of the tree, adding watches
+// for directories as they are created
+type inotify struct {
+	w    *Watcher
+	fd   int
+	file *os.File
+
+	mu   sync.Mutex
+	dirs map[int32]string // watch descriptor -> directory relative to the root
+}
+
+func newNotifier(w *Watcher) (backend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	// A non-blocking descriptor is read through the runtime poller, so
+	// closing the file unblocks the reader
+	n := &inotify{
+		w:    w,
+		fd:   fd,
+		file: os.NewFile(uintptr(fd), "inotify"),
+		dirs: make(map[int32]string),
+	}
+	if err := n.addTree("."); err != nil {
+		n.file.Close()
+		return nil, err
+	}
+
+	w.wg.Add(1)
+	go n.run()
+	return n, nil
+}
+
+// addTree watches a directory and the included directories below it
+func (n *inotify) addTree(rel string) error {
+	return filepath.WalkDir(filepath.Join(n.w.root, filepath.FromSlash(rel)), func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		dirRel, err := filepath.Rel(n.w.root, file)
+		if err != nil {
+			return err
+		}
+		dirRel = filepath.ToSlash(dirRel)
+		if dirRel != "." && !n.w.opts.Include(dirRel) {
+			return filepath.SkipDir
+		}
+
+		wd, err := syscall.InotifyAddWatch(n.fd, file, inotifyMask)
+		if err != nil {
+			if errors.Is(err, syscall.ENOENT) {
+				return nil
+			}
+			return os.NewSyscallError("inotify_add_watch", err)
+		}
+		n.mu.Lock()
+		n.dirs[int32(wd)] = dirRel
+		n.mu.Unlock()
+		return nil
+	})
+}
+
+func (n *inotify) run() {
+	defer n.w.wg.Done()
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		count, err := n.file.Read(buf)
+		if err != nil {
+			select {
+			case <-n.w.done:
+			default:
+				n.w.fail(err)
+			}
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= count; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+int(event.Len)]), "\x00")
+			offset = nameStart + int(event.Len)
+
+			n.handle(event.Wd, event.Mask, name)
+		}
+	}
+}
+
+func (n *inotify) handle(wd int32, mask uint32, name string) {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		n.w.send(".")
+		return
+	}
+
+	n.mu.Lock()
+	dir, known := n.dirs[wd]
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(n.dirs, wd)
+	}
+	n.mu.Unlock()
+	if !known || name == "" {
+		return
+	}
+
+	rel := path.Join(dir, name)
+	if !n.w.opts.Include(rel) {
+		return
+	}
+	if mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+		// Files created before the watch was added are covered by
+		// reporting the directory itself
+		if err := n.addTree(rel); err != nil {
+			n.w.fail(err)
+		}
+	}
+	n.w.send(rel)
+}
+
+func (n *inotify) close() error {
+	return n.file.Close()
+}
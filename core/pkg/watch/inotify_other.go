@@ -0,0 +1,7 @@
+//go:build !linux
+
+package watch
+
+func newNotifier(w *Watcher) (backend, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,115 @@
+package watch
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// fileState is what the poller compares between scans
+type fileState struct {
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+// poller scans the tree at a fixed interval and reports paths that
+// appeared, disappeared or changed size or modification time
+type poller struct {
+	w    *Watcher
+	last map[string]fileState
+}
+
+func newPoller(w *Watcher) (backend, error) {
+	p := &poller{w: w}
+	last, err := p.scan()
+	if err != nil {
+		return nil, err
+	}
+	p.last = last
+
+	w.wg.Add(1)
+	go p.run()
+	return p, nil
+}
+
+func (p *poller) run() {
+	defer p.w.wg.Done()
+
+	ticker := time.NewTicker(p.w.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			next, err := p.scan()
+			if err != nil {
+				p.w.fail(err)
+				continue
+			}
+			for rel, state := range next {
+				if previous, exists := p.last[rel]; !exists || previous != state {
+					p.w.send(rel)
+				}
+			}
+			for rel := range p.last {
+				if _, exists := next[rel]; !exists {
+					p.w.send(rel)
+				}
+			}
+			p.last = next
+		case <-p.w.done:
+			return
+		}
+	}
+}
+
+// scan records the state of every included path under the root
+func (p *poller) scan() (map[string]fileState, error) {
+	states := make(map[string]fileState)
+	err := filepath.WalkDir(p.w.root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// Removed while walking
+				return nil
+			}
+			return err
+		}
+
+		rel, err := filepath.Rel(p.w.root, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." && !p.w.opts.Include(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		state := fileState{dir: d.IsDir()}
+		if !state.dir {
+			// Directory sizes and times change with their entries, which
+			// are reported themselves
+			state.size, state.modTime = info.Size(), info.ModTime()
+		}
+		if rel != "." {
+			states[rel] = state
+		}
+		return nil
+	})
+	return states, err
+}
+
+func (p *poller) close() error {
+	return nil
+}
@@ -0,0 +1,177 @@
+// Package watch reports changes to the files under a directory. It uses
+// inotify on Linux and falls back to polling elsewhere, and delivers
+// changes in debounced batches so a burst of writes, such as an editor
+// saving or a git checkout, arrives as one batch.
+package watch
+
+import (
+	"errors"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default timings used for zero Options values
+const (
+	DefaultDebounce     = 200 * time.Millisecond
+	DefaultPollInterval = time.Second
+)
+
+// ErrUnsupported is returned by the native backend where the platform
+// has none; New then falls back to polling
+var ErrUnsupported = errors.New("native file notifications not supported")
+
+// Options configures a Watcher
+type Options struct {
+	Debounce     time.Duration // quiet period before a batch is delivered
+	PollInterval time.Duration // scan interval of the polling backend
+	Poll         bool          // poll even where native notifications work
+
+	// Include reports whether a path relative to the root, with forward
+	// slashes, is watched. Directories left out are not descended into.
+	// A nil Include watches everything.
+	Include func(rel string) bool
+}
+
+// Batch is a set of changed paths relative to the root, with forward
+// slashes, or an error from the backend. "." means anything may have
+// changed, for example after the kernel's event queue overflowed.
+type Batch struct {
+	Paths []string
+	Err   error
+}
+
+// Watcher watches a directory tree
+type Watcher struct {
+	root    string
+	opts    Options
+	backend backend
+	polling bool
+
+	changes chan string
+	errs    chan error
+	batches chan Batch
+	done    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+// backend is a source of changed paths. It sends them with w.send and
+// errors with w.fail until w.done is closed.
+type backend interface {
+	close() error
+}
+
+// New starts watching root
+func New(root string, opts Options) (*Watcher, error) {
+	if opts.Debounce <= 0 {
+		opts.Debounce = DefaultDebounce
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.Include == nil {
+		opts.Include = func(string) bool { return true }
+	}
+
+	w := &Watcher{
+		root:    root,
+		opts:    opts,
+		changes: make(chan string, 64),
+		errs:    make(chan error, 1),
+		batches: make(chan Batch, 1),
+		done:    make(chan struct{}),
+	}
+
+	var err error
+	if !opts.Poll {
+		w.backend, err = newNotifier(w)
+	}
+	if opts.Poll || err != nil {
+		w.polling = true
+		if w.backend, err = newPoller(w); err != nil {
+			return nil, err
+		}
+	}
+
+	w.wg.Add(1)
+	go w.debounce()
+	return w, nil
+}
+
+// Batches returns the channel of changes. It is closed by Close.
+func (w *Watcher) Batches() <-chan Batch {
+	return w.batches
+}
+
+// Polling reports whether the watcher fell back to polling
+func (w *Watcher) Polling() bool {
+	return w.polling
+}
+
+// Close stops the watcher and closes its batch channel
+func (w *Watcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		err = w.backend.close()
+		w.wg.Wait()
+		close(w.batches)
+	})
+	return err
+}
+
+// send reports a changed path from a backend
+func (w *Watcher) send(rel string) {
+	select {
+	case w.changes <- rel:
+	case <-w.done:
+	}
+}
+
+// fail reports a backend error
+func (w *Watcher) fail(err error) {
+	select {
+	case w.errs <- err:
+	case <-w.done:
+	}
+}
+
+// debounce collects changed paths until none arrived for the debounce
+// period, then delivers them as one batch
+func (w *Watcher) debounce() {
+	defer w.wg.Done()
+
+	pending := make(map[string]bool)
+	timer := time.NewTimer(w.opts.Debounce)
+	timer.Stop()
+
+	for {
+		select {
+		case rel := <-w.changes:
+			pending[path.Clean(rel)] = true
+			timer.Reset(w.opts.Debounce)
+		case err := <-w.errs:
+			w.deliver(Batch{Err: err})
+		case <-timer.C:
+			paths := make([]string, 0, len(pending))
+			for rel := range pending {
+				paths = append(paths, rel)
+			}
+			sort.Strings(paths)
+			pending = make(map[string]bool)
+			w.deliver(Batch{Paths: paths})
+		case <-w.done:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+func (w *Watcher) deliver(batch Batch) {
+	select {
+	case w.batches <- batch:
+	case <-w.done:
+	}
+}
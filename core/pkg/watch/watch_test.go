@@ -0,0 +1,97 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		t.Run(map[bool]string{false: "native", true: "poll"}[poll], func(t *testing.T) {
+			root := t.TempDir()
+			write := func(rel, content string) {
+				t.Helper()
+				path := filepath.Join(root, filepath.FromSlash(rel))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			write("groups/a/one.yaml", "one")
+			write("ignored/file.txt", "x")
+
+			w, err := New(root, Options{
+				Debounce:     50 * time.Millisecond,
+				PollInterval: 20 * time.Millisecond,
+				Poll:         poll,
+				Include: func(rel string) bool {
+					return !strings.HasPrefix(rel, "ignored")
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+			if poll && !w.Polling() {
+				t.Fatal("Polling() = false with Options.Poll")
+			}
+
+			next := func() []string {
+				t.Helper()
+				select {
+				case batch := <-w.Batches():
+					if batch.Err != nil {
+						t.Fatal(batch.Err)
+					}
+					return batch.Paths
+				case <-time.After(5 * time.Second):
+					t.Fatal("no batch delivered")
+					return nil
+				}
+			}
+
+			// A burst of writes arrives as one batch
+			write("groups/a/one.yaml", "one, edited")
+			write("groups/a/two.yaml", "two")
+			write("ignored/file.txt", "y")
+			if got, want := next(), []string{"groups/a/one.yaml", "groups/a/two.yaml"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("batch = %v, want %v", got, want)
+			}
+
+			// Files in new directories are seen too
+			write("groups/b/three.yaml", "three")
+			if got := next(); !contains(got, "groups/b") {
+				t.Errorf("batch = %v, want groups/b", got)
+			}
+
+			if err := os.Remove(filepath.Join(root, "groups", "a", "two.yaml")); err != nil {
+				t.Fatal(err)
+			}
+			if got := next(); !reflect.DeepEqual(got, []string{"groups/a/two.yaml"}) {
+				t.Errorf("batch = %v, want [groups/a/two.yaml]", got)
+			}
+
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if _, open := <-w.Batches(); open {
+				t.Error("Batches() still open after Close")
+			}
+		})
+	}
+}
+
+func contains(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}